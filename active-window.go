@@ -2,11 +2,13 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"os"
@@ -42,6 +44,45 @@ type HyprlandWindow struct {
 	Fullscreen   int    `json:"fullscreen"`
 }
 
+// SessionContext is what is known about a session beyond the window class
+// and title
+type SessionContext struct {
+	Process   string `json:"process,omitempty"`   // foreground process of a terminal, e.g. nvim
+	Directory string `json:"directory,omitempty"` // working directory of the terminal's foreground process
+	Project   string `json:"project,omitempty"`   // from the editor title or the git repository of Directory
+	Repo      string `json:"repo,omitempty"`      // git repository worked in, by directory name
+	Branch    string `json:"branch,omitempty"`    // its checked out branch
+	Ticket    string `json:"ticket,omitempty"`    // issue key from the branch name, e.g. ABC-123
+
+	Workspace   string `json:"workspace,omitempty"`    // Hyprland workspace name, e.g. "5: dev"
+	WorkspaceID int    `json:"workspace_id,omitempty"` // negative for special workspaces
+	Monitor     int    `json:"monitor,omitempty"`      // ID of the monitor showing the window
+	Fullscreen  bool   `json:"fullscreen,omitempty"`
+	Xwayland    bool   `json:"xwayland,omitempty"`
+}
+
+// summaryKey is what sessions are aggregated by: the application, and the
+// project, branch and workspace when there are
+func summaryKey(session ActivitySession) string {
+	key := session.AppClass
+	switch {
+	case session.Project == "":
+	case session.Branch == "":
+		key += " [" + session.Project + "]"
+	default:
+		key += " [" + session.Project + "@" + session.Branch + "]"
+	}
+	if session.Workspace != "" {
+		key += " on " + session.Workspace
+	}
+	return key
+}
+
+// summaryKeyOf is summaryKey for a summary
+func summaryKeyOf(summary ActivitySummary) string {
+	return summaryKey(ActivitySession{AppClass: summary.AppClass, SessionContext: summary.SessionContext})
+}
+
 // ActivitySession represents a single continuous session with an application
 type ActivitySession struct {
 	StartTime   time.Time     `json:"start_time"`
@@ -49,7 +90,9 @@ type ActivitySession struct {
 	AppClass    string        `json:"app_class"`
 	WindowTitle string        `json:"window_title"`
 	Duration    time.Duration `json:"duration"`
-	Active      bool          `json:"active"` // true if session is currently ongoing
+	Active      bool          `json:"active"`          // true if session is currently ongoing
+	Focus       bool          `json:"focus,omitempty"` // true if tracked during a focus session
+	SessionContext
 }
 
 // ActivitySummary represents aggregated time spent in an application
@@ -60,6 +103,8 @@ type ActivitySummary struct {
 	SessionCount    int           `json:"session_count"`
 	FirstSeen       time.Time     `json:"first_seen"`
 	LastSeen        time.Time     `json:"last_seen"`
+	FocusDuration   time.Duration `json:"focus_duration,omitempty"` // part of TotalDuration spent in focus sessions
+	SessionContext                // of the most recent session, like ActivityDetails
 }
 
 // ActivityTracker manages tracking of application usage sessions
@@ -69,6 +114,8 @@ type ActivityTracker struct {
 	sessions       []ActivitySession
 	mergeThreshold time.Duration // merge sessions shorter than this threshold
 	minDuration    time.Duration // ignore sessions shorter than this
+	focus          bool          // new sessions are marked as focus time
+	clock          Clock
 }
 
 // RescueTimePayload represents the data structure for RescueTime API (legacy offline time API)
@@ -91,6 +138,7 @@ type UserClientEvent struct {
 	EndTime          string `json:"end_time"`          // RFC 3339 format: 2025-09-30T12:01:00Z
 	WindowTitle      string `json:"window_title"`      // window title
 	Application      string `json:"application"`       // application class (redundant with event_description)
+	ClientEventID    string `json:"client_event_id"`   // deterministic ID from application, start and end
 }
 
 // ActivationRequest represents the payload for the /activate endpoint
@@ -107,71 +155,6 @@ type ActivationResponse struct {
 	URL        string `json:"url"`
 }
 
-// activateWithRescueTime authenticates with RescueTime and retrieves account keys
-func activateWithRescueTime(email, password string) (*ActivationResponse, error) {
-	// Discovered through testing: endpoint uses form-encoded data with username/password fields
-	url := "https://api.rescuetime.com/activate"
-
-	// Create form-encoded payload
-	formData := fmt.Sprintf("username=%s&password=%s",
-		strings.ReplaceAll(email, "@", "%40"), // URL encode @ sign
-		password)
-
-	// Create request
-	req, err := http.NewRequest("POST", url, strings.NewReader(formData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "RescueTime/2.16.5.1 (Linux)")
-
-	// Send request
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
-	}
-
-	// Check for error in response
-	// Response format is YAML-like: "c:\n- 0\n- RT:ok\naccount_key: xxx\nkey: xxx"
-	bodyStr := string(body)
-	if strings.Contains(bodyStr, "RT:error") {
-		return nil, fmt.Errorf("activation failed: %s", bodyStr)
-	}
-
-	// Parse response to extract account_key
-	// TODO: The response only contains account_key, not data_key
-	// We need to discover how to obtain the data_key (separate endpoint? different auth flow?)
-	var accountKey string
-	for _, line := range strings.Split(bodyStr, "\n") {
-		if strings.HasPrefix(line, "account_key:") {
-			accountKey = strings.TrimSpace(strings.TrimPrefix(line, "account_key:"))
-			break
-		}
-	}
-
-	if accountKey == "" {
-		return nil, fmt.Errorf("no account_key in response: %s", bodyStr)
-	}
-
-	// Return response with account_key
-	// Note: data_key is empty - needs further investigation
-	return &ActivationResponse{
-		AccountKey: accountKey,
-		DataKey:    "", // TODO: Discover how to obtain data_key
-		ApiURL:     "api.rescuetime.com",
-		URL:        "www.rescuetime.com",
-	}, nil
-}
-
 // saveCredentialsToEnv saves the activation credentials to .env file
 func saveCredentialsToEnv(filepath string, response *ActivationResponse) error {
 	// Read existing .env file to preserve RESCUE_TIME_API_KEY if it exists
@@ -224,7 +207,7 @@ func saveCredentialsToEnv(filepath string, response *ActivationResponse) error {
 func loadEnvFile(filepath string) error {
 	file, err := os.Open(filepath)
 	if err != nil {
-		return fmt.Errorf("failed to open .env file: %v", err)
+		return fmt.Errorf("failed to open .env file: %w", err)
 	}
 	defer file.Close()
 
@@ -257,6 +240,16 @@ func loadEnvFile(filepath string) error {
 	return nil
 }
 
+// credential returns the RescueTime key named by env, logging when feature is
+// left off because it isn't set
+func credential(env, feature string) string {
+	value := os.Getenv(env)
+	if value == "" {
+		slog.Info("feature disabled, key not set in the environment or .env", "feature", feature, "key", env)
+	}
+	return value
+}
+
 // summaryToPayload converts an ActivitySummary to RescueTimePayload format (legacy)
 func summaryToPayload(summary ActivitySummary) RescueTimePayload {
 	// Convert duration to minutes (rounded up)
@@ -289,270 +282,42 @@ func summaryToUserClientEvent(summary ActivitySummary) UserClientEventPayload {
 			EndTime:          endTimeFormatted,
 			WindowTitle:      summary.ActivityDetails,
 			Application:      summary.AppClass, // Same as EventDescription
+			ClientEventID:    eventID(summary.AppClass, startTimeFormatted, endTimeFormatted),
 		},
 	}
 }
 
-// submitToRescueTime submits activity data to RescueTime API with retry logic (legacy offline time API)
-func submitToRescueTime(apiKey string, payload RescueTimePayload) error {
-	const maxRetries = 3
-	const baseDelay = 1 * time.Second
-
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s
-			delay := baseDelay * time.Duration(math.Pow(2, float64(attempt-1)))
-			fmt.Printf("Retrying in %v... (attempt %d/%d)\n", delay, attempt+1, maxRetries)
-			time.Sleep(delay)
-		}
-
-		// Convert payload to JSON
-		jsonData, err := json.Marshal(payload)
-		if err != nil {
-			return fmt.Errorf("failed to marshal payload: %v", err)
-		}
-
-		// Create request
-		url := fmt.Sprintf("https://www.rescuetime.com/anapi/offline_time_post?key=%s", apiKey)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			lastErr = fmt.Errorf("failed to create request: %v", err)
-			continue
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-
-		// Send request
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
-			continue
-		}
-
-		// Read response body
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		// Check response status
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			fmt.Printf("✓ Submitted to RescueTime: %s (%d min)\n", payload.ActivityName, payload.Duration)
-			return nil
-		}
-
-		lastErr = fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-
-		// Don't retry on client errors (4xx)
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return lastErr
-		}
-	}
-
-	return fmt.Errorf("failed after %d attempts: %v", maxRetries, lastErr)
-}
-
-// submitUserClientEvent submits activity data to native RescueTime user_client_events API
-func submitUserClientEvent(apiKey string, payload UserClientEventPayload) error {
-	const maxRetries = 3
-	const baseDelay = 1 * time.Second
-
-	var lastErr error
-	var tryBearerAuth bool
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s
-			delay := baseDelay * time.Duration(math.Pow(2, float64(attempt-1)))
-			fmt.Printf("Retrying in %v... (attempt %d/%d)\n", delay, attempt+1, maxRetries)
-			time.Sleep(delay)
-		}
-
-		// Convert payload to JSON
-		jsonData, err := json.Marshal(payload)
-		if err != nil {
-			return fmt.Errorf("failed to marshal payload: %v", err)
-		}
-
-		var req *http.Request
-
-		// Try Bearer token auth if query param auth failed with 401
-		if tryBearerAuth {
-			// Create request WITHOUT query parameter
-			url := "https://api.rescuetime.com/api/resource/user_client_events"
-			req, err = http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-			if err != nil {
-				lastErr = fmt.Errorf("failed to create request: %v", err)
-				continue
-			}
-			// Use Bearer token authentication with data_key
-			// The desktop app uses the data_key as the Bearer token
-			dataKey := os.Getenv("RESCUE_TIME_DATA_KEY")
-			if dataKey == "" {
-				dataKey = apiKey // Fallback to provided API key
-			}
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", dataKey))
-
-			// Also try adding account_key as a query parameter along with Bearer token
-			accountKey := os.Getenv("RESCUE_TIME_ACCOUNT_KEY")
-			if accountKey != "" {
-				req.URL.RawQuery = fmt.Sprintf("key=%s", accountKey)
-			}
-		} else {
-			// Try query parameter authentication first with account_key
-			authKey := os.Getenv("RESCUE_TIME_ACCOUNT_KEY")
-			if authKey == "" {
-				authKey = apiKey
-			}
-			url := fmt.Sprintf("https://api.rescuetime.com/api/resource/user_client_events?key=%s", authKey)
-			req, err = http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-			if err != nil {
-				lastErr = fmt.Errorf("failed to create request: %v", err)
-				continue
-			}
-		}
-
-		// Set headers matching the official app
-		req.Header.Set("Content-Type", "application/json; charset=utf-8")
-		req.Header.Set("User-Agent", "RescueTime/2.16.5.1 (Linux)")
-
-		// Send request
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("request failed: %v", err)
-			continue
-		}
-
-		// Read response body
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		// Check response status
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			authMethod := "query parameter"
-			if tryBearerAuth {
-				authMethod = "Bearer token"
-			}
-			fmt.Printf("✓ Submitted to RescueTime via %s: %s (%s to %s)\n",
-				authMethod,
-				payload.UserClientEvent.Application,
-				payload.UserClientEvent.StartTime,
-				payload.UserClientEvent.EndTime)
-			return nil
-		}
-
-		lastErr = fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-
-		// If we got 401 with query param auth, try Bearer token auth next
-		if resp.StatusCode == 401 && !tryBearerAuth {
-			fmt.Println("Query parameter auth failed (401), trying Bearer token authentication...")
-			tryBearerAuth = true
-			continue
-		}
-
-		// Don't retry on other client errors (4xx)
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return lastErr
-		}
-	}
-
-	return fmt.Errorf("failed after %d attempts: %v", maxRetries, lastErr)
-}
-
-// submitActivitiesToRescueTime submits all activity summaries to RescueTime
-// Attempts native user_client_events API first if credentials are available,
-// falls back to offline_time_post API if native fails or credentials are missing.
-func submitActivitiesToRescueTime(apiKey string, summaries map[string]ActivitySummary) {
-	if len(summaries) == 0 {
-		fmt.Println("No activities to submit.")
-		return
-	}
-
-	// Check if we have native API credentials
-	dataKey := os.Getenv("RESCUE_TIME_DATA_KEY")
-	accountKey := os.Getenv("RESCUE_TIME_ACCOUNT_KEY")
-	hasNativeCredentials := dataKey != "" || accountKey != ""
-
-	fmt.Printf("\n=== Submitting %d activities to RescueTime ===\n", len(summaries))
-	if hasNativeCredentials {
-		fmt.Println("[INFO] Native API credentials detected, will try native API first with legacy fallback")
-	} else {
-		fmt.Println("[INFO] Using legacy offline time API (no native credentials found)")
-	}
-
-	successCount := 0
-	failCount := 0
-	nativeSuccessCount := 0
-	legacyFallbackCount := 0
-
-	for _, summary := range summaries {
-		// Skip activities with a very short duration (< 1 minute)
-		if summary.TotalDuration < time.Minute {
-			continue
-		}
-
-		var err error
-		usedFallback := false
-
-		if hasNativeCredentials {
-			// Try native API first
-			fmt.Printf("[ATTEMPT] Trying native API for %s...\n", summary.AppClass)
-			payload := summaryToUserClientEvent(summary)
-			err = submitUserClientEvent(apiKey, payload)
-
-			if err != nil {
-				// Native API failed, log and try legacy fallback
-				fmt.Fprintf(os.Stderr, "[WARN] Native API failed for %s: %v\n", summary.AppClass, err)
-				fmt.Printf("[FALLBACK] Attempting legacy API for %s...\n", summary.AppClass)
-
-				legacyPayload := summaryToPayload(summary)
-				err = submitToRescueTime(apiKey, legacyPayload)
-				usedFallback = true
-			} else {
-				nativeSuccessCount++
-			}
-		} else {
-			// No native credentials, use legacy API directly
-			payload := summaryToPayload(summary)
-			err = submitToRescueTime(apiKey, payload)
-		}
-
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "✗ Failed to submit %s: %v\n", summary.AppClass, err)
-			failCount++
-		} else {
-			successCount++
-			if usedFallback {
-				legacyFallbackCount++
-			}
-		}
-	}
-
-	fmt.Printf("\n=== Submission Summary ===\n")
-	fmt.Printf("Total succeeded: %d, failed: %d\n", successCount, failCount)
-	if hasNativeCredentials {
-		fmt.Printf("Native API successes: %d\n", nativeSuccessCount)
-		fmt.Printf("Legacy fallback successes: %d\n", legacyFallbackCount)
-	}
+// shouldSubmit reports whether a summary is long enough to be sent to RescueTime
+func shouldSubmit(summary ActivitySummary) bool {
+	return summary.TotalDuration >= time.Minute
 }
 
 // NewActivityTracker creates a new activity tracker with default settings
 func NewActivityTracker() *ActivityTracker {
+	return NewActivityTrackerWithClock(realClock{})
+}
+
+// NewActivityTrackerWithClock creates a new activity tracker that reads time from clock
+func NewActivityTrackerWithClock(clock Clock) *ActivityTracker {
 	return &ActivityTracker{
 		sessions:       make([]ActivitySession, 0),
 		mergeThreshold: 30 * time.Second, // merge sessions if gap is less than 30s
 		minDuration:    10 * time.Second, // ignore sessions shorter than 10s
+		clock:          clock,
 	}
 }
 
 // StartSession begins tracking a new activity session
 func (at *ActivityTracker) StartSession(appClass, windowTitle string) {
+	at.StartSessionWith(appClass, windowTitle, SessionContext{})
+}
+
+// StartSessionWith begins tracking a new activity session with context
+func (at *ActivityTracker) StartSessionWith(appClass, windowTitle string, sessionContext SessionContext) {
 	at.mu.Lock()
 	defer at.mu.Unlock()
 
-	now := time.Now()
+	now := at.clock.Now()
 
 	// End the current session if one exists
 	if at.currentSession != nil && at.currentSession.Active {
@@ -561,13 +326,57 @@ func (at *ActivityTracker) StartSession(appClass, windowTitle string) {
 
 	// Start new session
 	at.currentSession = &ActivitySession{
-		StartTime:   now,
-		AppClass:    appClass,
-		WindowTitle: windowTitle,
-		Active:      true,
+		StartTime:      now,
+		AppClass:       appClass,
+		WindowTitle:    windowTitle,
+		Active:         true,
+		Focus:          at.focus,
+		SessionContext: sessionContext,
 	}
 }
 
+// SetFocus marks the time from now on as focus time or not. The current
+// session is split so each session is entirely inside or outside focus.
+func (at *ActivityTracker) SetFocus(focus bool) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if at.focus == focus {
+		return
+	}
+	at.focus = focus
+
+	if current := at.currentSession; current != nil && current.Active {
+		now := at.clock.Now()
+		at.endCurrentSessionUnsafe(now)
+		at.currentSession = &ActivitySession{
+			StartTime:      now,
+			AppClass:       current.AppClass,
+			WindowTitle:    current.WindowTitle,
+			Active:         true,
+			Focus:          focus,
+			SessionContext: current.SessionContext,
+		}
+	}
+}
+
+// Current returns the active session, if any
+func (at *ActivityTracker) Current() (ActivitySession, bool) {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	if at.currentSession == nil || !at.currentSession.Active {
+		return ActivitySession{}, false
+	}
+	return *at.currentSession, true
+}
+
+// Focus reports whether new sessions are marked as focus time
+func (at *ActivityTracker) Focus() bool {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return at.focus
+}
+
 // endCurrentSessionUnsafe ends the current session (must be called with lock held)
 func (at *ActivityTracker) endCurrentSessionUnsafe(endTime time.Time) {
 	if at.currentSession == nil || !at.currentSession.Active {
@@ -594,7 +403,7 @@ func (at *ActivityTracker) endCurrentSessionUnsafe(endTime time.Time) {
 func (at *ActivityTracker) EndCurrentSession() {
 	at.mu.Lock()
 	defer at.mu.Unlock()
-	at.endCurrentSessionUnsafe(time.Now())
+	at.endCurrentSessionUnsafe(at.clock.Now())
 }
 
 // shouldMergeWithLastSession checks if current session should be merged with the previous one
@@ -605,8 +414,8 @@ func (at *ActivityTracker) shouldMergeWithLastSession() bool {
 
 	lastSession := &at.sessions[len(at.sessions)-1]
 
-	// Can only merge sessions of the same application
-	if lastSession.AppClass != at.currentSession.AppClass {
+	// Can only merge sessions of the same application and project, on the same side of a focus change
+	if summaryKey(*lastSession) != summaryKey(*at.currentSession) || lastSession.Focus != at.currentSession.Focus {
 		return false
 	}
 
@@ -629,10 +438,17 @@ func (at *ActivityTracker) mergeWithLastSession() {
 
 	// Use the most recent window title
 	lastSession.WindowTitle = at.currentSession.WindowTitle
+	lastSession.SessionContext = at.currentSession.SessionContext
 }
 
-// GetActivitySummaries aggregates sessions by application class
+// GetActivitySummaries aggregates sessions by application class and project
 func (at *ActivityTracker) GetActivitySummaries() map[string]ActivitySummary {
+	return at.SummariesSince(time.Time{})
+}
+
+// SummariesSince is GetActivitySummaries for the time from since on, e.g.
+// today's part of sessions tracked since before midnight
+func (at *ActivityTracker) SummariesSince(since time.Time) map[string]ActivitySummary {
 	at.mu.RLock()
 	defer at.mu.RUnlock()
 
@@ -640,7 +456,14 @@ func (at *ActivityTracker) GetActivitySummaries() map[string]ActivitySummary {
 
 	// Process all completed sessions
 	for _, session := range at.sessions {
-		key := session.AppClass
+		if !session.EndTime.After(since) {
+			continue
+		}
+		if session.StartTime.Before(since) {
+			session.Duration = min(session.Duration, session.EndTime.Sub(since))
+			session.StartTime = since
+		}
+		key := summaryKey(session)
 		summary, exists := summaries[key]
 
 		if !exists {
@@ -649,12 +472,16 @@ func (at *ActivityTracker) GetActivitySummaries() map[string]ActivitySummary {
 				ActivityDetails: session.WindowTitle,
 				FirstSeen:       session.StartTime,
 				LastSeen:        session.EndTime,
+				SessionContext:  session.SessionContext,
 			}
 		}
 
 		// Update summary
 		summary.TotalDuration += session.Duration
 		summary.SessionCount++
+		if session.Focus {
+			summary.FocusDuration += session.Duration
+		}
 
 		// Update time boundaries
 		if session.StartTime.Before(summary.FirstSeen) {
@@ -664,6 +491,7 @@ func (at *ActivityTracker) GetActivitySummaries() map[string]ActivitySummary {
 			summary.LastSeen = session.EndTime
 			// Use the most recent window title as activity details
 			summary.ActivityDetails = session.WindowTitle
+			summary.SessionContext = session.SessionContext
 		}
 
 		summaries[key] = summary
@@ -671,26 +499,35 @@ func (at *ActivityTracker) GetActivitySummaries() map[string]ActivitySummary {
 
 	// Include current active session if exists
 	if at.currentSession != nil && at.currentSession.Active {
-		key := at.currentSession.AppClass
+		key := summaryKey(*at.currentSession)
 		summary, exists := summaries[key]
 
-		currentDuration := time.Since(at.currentSession.StartTime)
+		now := at.clock.Now()
+		start := at.currentSession.StartTime
+		if start.Before(since) {
+			start = since
+		}
+		currentDuration := now.Sub(start)
 
 		if !exists {
 			summary = ActivitySummary{
 				AppClass:        at.currentSession.AppClass,
 				ActivityDetails: at.currentSession.WindowTitle,
-				FirstSeen:       at.currentSession.StartTime,
-				LastSeen:        time.Now(),
+				FirstSeen:       start,
+				LastSeen:        now,
 			}
 		}
 
 		summary.TotalDuration += currentDuration
 		summary.SessionCount++
+		if at.currentSession.Focus {
+			summary.FocusDuration += currentDuration
+		}
 
 		// Update activity details to current window title
 		summary.ActivityDetails = at.currentSession.WindowTitle
-		summary.LastSeen = time.Now()
+		summary.SessionContext = at.currentSession.SessionContext
+		summary.LastSeen = now
 
 		summaries[key] = summary
 	}
@@ -698,13 +535,38 @@ func (at *ActivityTracker) GetActivitySummaries() map[string]ActivitySummary {
 	return summaries
 }
 
-// ClearCompletedSessions removes all completed sessions, keeping only the current active session
+// Sessions returns a copy of the completed sessions
+func (at *ActivityTracker) Sessions() []ActivitySession {
+	at.mu.RLock()
+	defer at.mu.RUnlock()
+	return append([]ActivitySession(nil), at.sessions...)
+}
+
+// ClearCompletedSessions removes all completed sessions, keeping only the current active session.
+// The active session is moved up to start now, since the time before this point
+// has already been included in the submitted summaries.
 func (at *ActivityTracker) ClearCompletedSessions() {
 	at.mu.Lock()
 	defer at.mu.Unlock()
 
 	// Clear all stored sessions but keep the current active one
 	at.sessions = make([]ActivitySession, 0)
+
+	if at.currentSession != nil && at.currentSession.Active {
+		at.currentSession.StartTime = at.clock.Now()
+	}
+}
+
+// WindowSource provides the currently focused window
+type WindowSource interface {
+	ActiveWindow() (*HyprlandWindow, error)
+}
+
+// hyprctlWindowSource reads the active window from Hyprland
+type hyprctlWindowSource struct{}
+
+func (hyprctlWindowSource) ActiveWindow() (*HyprlandWindow, error) {
+	return getActiveWindow()
 }
 
 func getActiveWindow() (*HyprlandWindow, error) {
@@ -748,12 +610,12 @@ func formatWindowOutput(windowName, windowClass string) string {
 }
 
 // printActivitySummary prints a summary of tracked activities
-func printActivitySummary(tracker *ActivityTracker) {
-	fmt.Println("\n=== Activity Summary ===")
+func printActivitySummary(w io.Writer, tracker *ActivityTracker) {
+	fmt.Fprintln(w, "\n=== Activity Summary ===")
 
 	summaries := tracker.GetActivitySummaries()
 	if len(summaries) == 0 {
-		fmt.Println("No activities tracked.")
+		fmt.Fprintln(w, "No activities tracked.")
 		return
 	}
 
@@ -762,16 +624,20 @@ func printActivitySummary(tracker *ActivityTracker) {
 		totalTime += summary.TotalDuration
 	}
 
-	fmt.Printf("Total tracking time: %v\n\n", totalTime.Round(time.Second))
+	fmt.Fprintf(w, "Total tracking time: %v\n\n", totalTime.Round(time.Second))
 
 	for appClass, summary := range summaries {
 		percentage := float64(summary.TotalDuration) / float64(totalTime) * 100
-		fmt.Printf("%s: %v (%.1f%%) - %d sessions\n",
+		fmt.Fprintf(w, "%s: %v (%.1f%%) - %d sessions",
 			appClass,
 			summary.TotalDuration.Round(time.Second),
 			percentage,
 			summary.SessionCount)
-		fmt.Printf("  └─ %s\n\n", summary.ActivityDetails)
+		if summary.FocusDuration > 0 {
+			fmt.Fprintf(w, ", %v in focus", summary.FocusDuration.Round(time.Second))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  └─ %s\n\n", summary.ActivityDetails)
 	}
 }
 
@@ -785,92 +651,337 @@ func getCurrentWindowInfo() (string, error) {
 	return formatWindowOutput(windowName, windowClass), nil
 }
 
-func monitorWindowChanges(interval time.Duration, submitToAPI bool, apiKey string, submissionInterval time.Duration) {
-	var lastAppClass, lastWindowTitle string
-
-	// Create activity tracker
-	tracker := NewActivityTracker()
+// trackerOptions configures the tracking daemon
+type trackerOptions struct {
+	Interval           time.Duration     // window polling interval
+	Submitter          *Submitter        // nil disables submission
+	SubmissionInterval time.Duration     // from flags or the default, see Config
+	ShutdownTimeout    time.Duration     // bound on the final delivery attempt
+	FocusPoll          time.Duration     // FocusTime feed polling interval, 0 disables
+	Recorder           *TraceRecorder    // optional trace of raw observations
+	Enforcer           *BlockEnforcer    // optional block list enforcement
+	Config             *ConfigSync       // server-driven settings merged over flags
+	Alerts             *AlertMonitor     // optional goal and Alerts Feed notifications
+	Productivity       *ProductivityMap  // classifies activity for alerts and status
+	Browser            *BrowserTabs      // active tabs from the native messaging host
+	Terminals          *TerminalResolver // nil tracks terminals by window only
+	Projects           *ProjectResolver  // nil disables project detection
+}
 
+func monitorWindowChanges(opts trackerOptions) {
 	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	submitter := opts.Submitter
+	monitor := &Monitor{
+		Source:       hyprctlWindowSource{},
+		Clock:        realClock{},
+		Tracker:      NewActivityTracker(),
+		Interval:     opts.Interval,
+		Out:          os.Stdout,
+		Recorder:     opts.Recorder,
+		Browser:      opts.Browser,
+		Terminals:    opts.Terminals,
+		Projects:     opts.Projects,
+		Productivity: opts.Productivity,
+	}
+
+	// Apply the server config: the cached one now, fetched changes while running
+	config := opts.Config
+	if config == nil {
+		config = &ConfigSync{Interval: opts.SubmissionInterval}
+	}
+	reconfigure := make(chan time.Duration, 1)
+	config.OnChange = func(effective EffectiveConfig) {
+		select {
+		case <-reconfigure: // replace a change not picked up yet
+		default:
+		}
+		reconfigure <- effective.SubmissionInterval
+	}
+	go config.Run(ctx)
+
+	if submitter != nil {
+		go submitter.Run(ctx)
+		monitor.SubmissionInterval = config.Effective().SubmissionInterval
+		monitor.Submit = submitter.Enqueue
+		monitor.Reconfigure = reconfigure
+	}
+
+	// Let commands such as preview query the running tracker
+	control := NewControlServer(controlSocketPath())
+	control.HandleFunc("GET /preview", func(w http.ResponseWriter, r *http.Request) {
+		if submitter == nil {
+			http.Error(w, "submission is not enabled (run with -submit or -dry-run)", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		submitter.Preview(r.Context(), monitor.Tracker.GetActivitySummaries(), w)
+	})
+	control.HandleFunc("POST /backfill", handleBackfill(submitter))
+	if opts.Browser != nil {
+		control.HandleFunc("POST /browser", handleBrowser(opts.Browser))
+	}
+
+	// Follow focus sessions from the focus command, and from the web or phone
+	// through the FocusTime feeds when an API key is available
+	focus := &FocusMonitor{Tracker: monitor.Tracker, Clock: monitor.Clock, PollInterval: time.Minute}
+	if opts.FocusPoll > 0 {
+		if apiKey := credential("RESCUE_TIME_API_KEY", "FocusTime feeds"); apiKey != "" {
+			focus.Client = NewRescueTimeClient(endpoints, apiKey, "", "")
+			focus.PollInterval = opts.FocusPoll
+		}
+	}
+	go focus.Run(ctx)
+	control.HandleFunc("/focus", handleFocus(focus))
+
+	if enforcer := opts.Enforcer; enforcer != nil {
+		enforcer.Focus = monitor.Tracker.Focus
+		monitor.Enforcer = enforcer
+		go enforcer.Run(ctx)
+	}
+
+	// Today's activity, for alerts and status: what was submitted plus what is still tracked
+	var store *ActivityStore
+	if submitter != nil {
+		store = submitter.Store
+	}
+	today := func() []ActivitySummary { return todaysActivity(store, monitor.Tracker, monitor.Clock.Now()) }
+	if alerts := opts.Alerts; alerts != nil {
+		alerts.Today = today
+		alerts.Productivity = opts.Productivity
+		go alerts.Run(ctx)
+	}
+	control.HandleFunc("GET /status", handleStatus(statusSources{
+		StartedAt:    time.Now(),
+		Tracker:      monitor.Tracker,
+		Focus:        focus,
+		Submitter:    submitter,
+		Config:       config,
+		Productivity: opts.Productivity,
+		Today:        today,
+	}))
+	go func() {
+		if err := control.Serve(ctx); err != nil {
+			slog.Warn("control socket unavailable", "error", err)
+		}
+	}()
+
+	monitor.Run(ctx)
+
+	if submitter != nil {
+		// Deliver the final summaries, but never hang shutdown on the network
+		submitter.Close(opts.ShutdownTimeout)
+	}
+}
+
+// Monitor polls a WindowSource, feeds focus changes into an ActivityTracker
+// and periodically hands summaries to Submit
+type Monitor struct {
+	Source             WindowSource
+	Clock              Clock
+	Tracker            *ActivityTracker                           // created from Clock if nil
+	Interval           time.Duration                              // polling interval
+	SubmissionInterval time.Duration                              // how often Submit is called
+	Submit             func(summaries map[string]ActivitySummary) // nil disables submission
+	Out                io.Writer                                  // window changes and the final summary
+	Recorder           *TraceRecorder                             // optional trace of raw observations
+	Enforcer           *BlockEnforcer                             // optional block list enforcement during focus
+	Reconfigure        <-chan time.Duration                       // optional new submission intervals
+	Browser            *BrowserTabs                               // optional active tabs, tracking browsers by domain
+	Terminals          *TerminalResolver                          // optional foreground process lookup for terminals
+	Projects           *ProjectResolver                           // optional project detection
+	Productivity       *ProductivityMap                           // optional rules marking windows as background
+
+	started         bool // whether the first window has been handled
+	background      bool // whether the focused window is in the background, untracked
+	lastAppClass    string
+	lastWindowTitle string
+	lastContext     SessionContext
+}
+
+// Run tracks window focus until ctx is cancelled, then ends the current
+// session, submits the remaining data and prints the summary
+func (m *Monitor) Run(ctx context.Context) {
+	// Create activity tracker
+	if m.Tracker == nil {
+		m.Tracker = NewActivityTrackerWithClock(m.Clock)
+	}
 
 	// Get initial window info and start the first session
-	window, err := getActiveWindow()
+	window, err := m.Source.ActiveWindow()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting initial window info: %v\n", err)
+		m.Recorder.Observe(m.Clock.Now(), nil, nil, err)
+		slog.Error("failed to get initial window info", "error", err)
 		return
 	}
+	m.HandleWindow(window)
 
-	// Start the initial session
-	tracker.StartSession(window.Class, window.Title)
-	lastAppClass = window.Class
-	lastWindowTitle = window.Title
-
-	// Print initial window
-	currentInfo := formatWindowOutput(window.Title, window.Class)
-	fmt.Printf("%s [%s]\n", currentInfo, time.Now().Format("15:04:05"))
-
-	pollTicker := time.NewTicker(interval)
+	pollTicker := m.Clock.NewTicker(m.Interval)
 	defer pollTicker.Stop()
 
-	var submitTicker *time.Ticker
+	// Tell systemd (Type=notify) that we are up; the watchdog is fed from the poll loop
+	watchdog := NewWatchdog()
+	if err := sdNotify("READY=1"); err != nil {
+		slog.Warn("failed to notify systemd readiness", "error", err)
+	}
+
+	var submitTicker Ticker
 	var submitChan <-chan time.Time
 
-	if submitToAPI {
-		submitTicker = time.NewTicker(submissionInterval)
-		defer submitTicker.Stop()
-		submitChan = submitTicker.C
-		fmt.Printf("API submission enabled: will submit every %v\n", submissionInterval)
+	if m.Submit != nil {
+		submitTicker = m.Clock.NewTicker(m.SubmissionInterval)
+		defer func() { submitTicker.Stop() }()
+		submitChan = submitTicker.C()
+		slog.Info("API submission enabled", "interval", m.SubmissionInterval)
 	}
 
 	for {
 		select {
-		case <-sigChan:
-			fmt.Println("\nShutting down window monitor...")
-
-			// End the current session
-			tracker.EndCurrentSession()
-
-			// Submit final data if API submission is enabled
-			if submitToAPI {
-				summaries := tracker.GetActivitySummaries()
-				submitActivitiesToRescueTime(apiKey, summaries)
-			}
-
-			// Print summary before exit
-			printActivitySummary(tracker)
+		case <-ctx.Done():
+			slog.Info("shutting down window monitor")
+			sdNotify("STOPPING=1")
+			m.Shutdown()
 			return
 
 		case <-submitChan:
-			// Time to submit data to RescueTime
-			summaries := tracker.GetActivitySummaries()
-			submitActivitiesToRescueTime(apiKey, summaries)
+			m.SubmitNow()
+
+		case interval := <-m.Reconfigure:
+			if submitTicker == nil || interval <= 0 || interval == m.SubmissionInterval {
+				continue
+			}
+			submitTicker.Stop()
+			submitTicker = m.Clock.NewTicker(interval)
+			submitChan = submitTicker.C()
+			slog.Info("submission interval changed", "interval", interval, "previous", m.SubmissionInterval)
+			m.SubmissionInterval = interval
 
-			// Clear completed sessions after successful submission
-			tracker.ClearCompletedSessions()
+		case <-pollTicker.C():
+			watchdog.Ping(m.Clock.Now())
 
-		case <-pollTicker.C:
-			window, err := getActiveWindow()
+			window, err := m.Source.ActiveWindow()
 			if err != nil {
+				m.Recorder.Observe(m.Clock.Now(), nil, nil, err)
 				// Don't spam errors, just skip this iteration
+				slog.Debug("failed to poll active window", "error", err)
 				continue
 			}
 
-			// Check if the application or window title changed
-			if window.Class != lastAppClass || window.Title != lastWindowTitle {
-				// Start a new session for the new window/app
-				tracker.StartSession(window.Class, window.Title)
+			m.HandleWindow(window)
+		}
+	}
+}
 
-				// Print the change
-				currentInfo := formatWindowOutput(window.Title, window.Class)
-				fmt.Printf("%s [%s]\n", currentInfo, time.Now().Format("15:04:05"))
+// HandleWindow processes one observation of the focused window, starting a
+// new session if the application or window title changed. The trace records
+// the window with what it resolved to, so replays don't need the resolvers.
+func (m *Monitor) HandleWindow(window *HyprlandWindow) {
+	resolved := m.resolve(window)
+	m.Recorder.Observe(m.Clock.Now(), window, &resolved, nil)
+	m.track(resolved)
+}
 
-				// Update tracking variables
-				lastAppClass = window.Class
-				lastWindowTitle = window.Title
-			}
+// resolve works out the application, title and context window is tracked
+// under, and enforces the block list on it
+func (m *Monitor) resolve(window *HyprlandWindow) Resolution {
+	window = m.Browser.Enrich(window)
+	m.Enforcer.Check(window)
+
+	// Terminals are tracked by their foreground process, e.g. "kitty › nvim"
+	sessionContext := withPlacement(m.Terminals.Context(window), window)
+	sessionContext = m.Projects.Context(window, sessionContext)
+	appClass := window.Class
+	if sessionContext.Process != "" {
+		appClass += subActivitySeparator + sessionContext.Process
+	}
+	return Resolution{
+		AppClass:   appClass,
+		Title:      window.Title,
+		Context:    sessionContext,
+		Background: m.Productivity.Background(appClass, window.Title, sessionContext),
+	}
+}
+
+// track starts a new session if the application, title or context changed
+func (m *Monitor) track(resolved Resolution) {
+	if m.Tracker == nil {
+		m.Tracker = NewActivityTrackerWithClock(m.Clock)
+	}
+	appClass, title, sessionContext := resolved.AppClass, resolved.Title, resolved.Context
+
+	// Background windows, e.g. on a music workspace, aren't tracked at all
+	if resolved.Background {
+		if !m.background {
+			m.Tracker.EndCurrentSession()
+			fmt.Fprintf(m.Out, "%s (background, not tracked) [%s]\n", formatWindowOutput(title, appClass), m.Clock.Now().Format("15:04:05"))
+			m.background = true
+			m.started, m.lastAppClass, m.lastWindowTitle, m.lastContext = true, "", "", SessionContext{}
 		}
+		return
+	}
+	m.background = false
+
+	// Check if the application, window title or context changed
+	if m.started && appClass == m.lastAppClass && title == m.lastWindowTitle && sessionContext == m.lastContext {
+		return
+	}
+
+	// Start a new session for the new window/app
+	m.Tracker.StartSessionWith(appClass, title, sessionContext)
+
+	// Print the change
+	currentInfo := formatWindowOutput(title, appClass)
+	fmt.Fprintf(m.Out, "%s [%s]\n", currentInfo, m.Clock.Now().Format("15:04:05"))
+
+	if appClass != m.lastAppClass {
+		notifyStatus("Tracking %s", appClass)
+	}
+
+	// Update tracking variables
+	m.started = true
+	m.lastAppClass = appClass
+	m.lastWindowTitle = title
+	m.lastContext = sessionContext
+}
+
+// SubmitNow hands the current summaries to Submit and starts a new submission period
+func (m *Monitor) SubmitNow() {
+	m.Recorder.Mark(m.Clock.Now(), TraceSubmit)
+
+	// Time to submit data to RescueTime
+	summaries := m.Tracker.GetActivitySummaries()
+	m.Submit(summaries)
+
+	// Clear completed sessions after successful submission
+	m.Tracker.ClearCompletedSessions()
+	notifyStatus("Tracking %s, last submission at %s", m.lastAppClass, m.Clock.Now().Format("15:04:05"))
+}
+
+// Shutdown ends the current session, submits the remaining data if
+// submission is enabled and prints the activity summary
+func (m *Monitor) Shutdown() {
+	m.Recorder.Mark(m.Clock.Now(), TraceShutdown)
+
+	// End the current session
+	m.Tracker.EndCurrentSession()
+
+	// Submit final data if API submission is enabled
+	if m.Submit != nil {
+		summaries := m.Tracker.GetActivitySummaries()
+		m.Submit(summaries)
+	}
+
+	// Print summary before exit
+	printActivitySummary(m.Out, m.Tracker)
+}
+
+// runCommand runs a subcommand and exits non-zero if it fails
+func runCommand(run func(args []string) error, args []string) {
+	if err := run(args); err != nil {
+		slog.Error("command failed", "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -880,12 +991,111 @@ func main() {
 	track := flag.Bool("track", false, "Monitor and track time spent in applications")
 	submit := flag.Bool("submit", false, "Submit activity data to RescueTime API")
 	interval := flag.Duration("interval", 200*time.Millisecond, "Polling interval for monitoring mode (e.g., 100ms, 1s)")
-	submissionInterval := flag.Duration("submission-interval", 15*time.Minute, "Interval for submitting data to RescueTime (e.g., 15m, 1h)")
+	submissionInterval := flag.Duration("submission-interval", defaultSubmissionInterval, "Interval for submitting data to RescueTime (e.g., 15m, 1h; default follows the account plan from the server config)")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "auto", "Log format: auto, text, json or journald (auto uses journald under systemd)")
+	recordPath := flag.String("record", "", "Append every window observation to this JSON Lines trace file (for replay)")
+	apiURL := flag.String("api-url", "", "Base URL of the native RescueTime API (default $RESCUE_TIME_API_URL or "+defaultAPIBaseURL+")")
+	webURL := flag.String("web-url", "", "Base URL of the public RescueTime API (default $RESCUE_TIME_WEB_URL or "+defaultWebBaseURL+")")
+	outboxPath := flag.String("outbox", "", "File holding submissions that haven't been delivered yet (default $XDG_STATE_HOME/rescuetime-linux/outbox.json)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "How long to keep submitting on shutdown before leaving the rest in the outbox")
+	dryRun := flag.Bool("dry-run", false, "Run the submission pipeline but print the requests (keys masked) instead of sending them")
+	dryRunOutput := flag.String("dry-run-output", "", "Append dry-run requests to this file instead of stdout")
+	focusPoll := flag.Duration("focus-poll", time.Minute, "How often to poll the FocusTime feeds for sessions started elsewhere (0 disables)")
+	blockAction := flag.String("block-action", blockNotify, "What to do with blocked windows during focus time: notify, minimize, close or off")
+	blockListPath := flag.String("block-list", configPath("block-list"), "Local block list overrides, one pattern per line, !pattern unblocks")
+	alertsPath := flag.String("alerts", configPath("alerts"), "Goal rules for desktop alerts, one per line (e.g. \"over 1h discord reddit.com\" or \"focus 2h\")")
+	quietHours := flag.String("quiet-hours", "", "Daily window without alert notifications (e.g. 22:00-07:00)")
+	productivityPath := flag.String("productivity", configPath("productivity"), "Local productivity map, one pattern, level (-2 to 2) and category per line")
+	highlightsAt := flag.String("highlights-at", "", "Time of day to propose highlights from git commits and tickets (e.g. 17:30, default off)")
+	reposPath := flag.String("repos", configPath("repos"), "Git repositories to propose highlights from, one directory per line")
+	processes := flag.Bool("processes", true, "Track the foreground process of terminals as a sub-activity (e.g. kitty › nvim)")
+	projects := flag.Bool("projects", true, "Detect the project of editors and terminals from titles and git repositories")
+	projectDetails := flag.Bool("project-details", false, "Submit the project instead of the window title as activity details, so RescueTime groups by project")
+	browserFullURL := flag.Bool("browser-full-url", false, "Keep the path and query of browser tab URLs in window titles (default scheme and host only)")
 	flag.Parse()
 
+	if err := setupLogging(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Keys and endpoints may come from .env; every subsystem reads them from
+	// the environment
+	if err := loadEnvFile(".env"); errors.Is(err, os.ErrNotExist) {
+		slog.Debug("no .env file, using the environment only")
+	} else if err != nil {
+		slog.Warn("failed to load .env", "error", err)
+	}
+
+	endpoints = resolveEndpoints(*apiURL, *webURL)
+	if endpoints.API != defaultAPIBaseURL || endpoints.Web != defaultWebBaseURL {
+		slog.Info("using custom RescueTime endpoints", "api", endpoints.API, "web", endpoints.Web)
+	}
+
+	// Subcommands that don't need a graphical session
+	switch flag.Arg(0) {
+	case "":
+		// No command, fall through to window monitoring below
+	case "install-service":
+		runCommand(runInstallService, flag.Args()[1:])
+		return
+	case "replay":
+		runCommand(runReplay, flag.Args()[1:])
+		return
+	case "mock-server":
+		runCommand(runMockServer, flag.Args()[1:])
+		return
+	case "activate":
+		runCommand(runActivate, flag.Args()[1:])
+		return
+	case "preview":
+		runCommand(runPreview, flag.Args()[1:])
+		return
+	case "backfill":
+		runCommand(runBackfill, flag.Args()[1:])
+		return
+	case "fetch":
+		runCommand(runFetch, flag.Args()[1:])
+		return
+	case "report":
+		runCommand(runReport, flag.Args()[1:])
+		return
+	case "summary":
+		runCommand(runSummary, flag.Args()[1:])
+		return
+	case "focus":
+		runCommand(runFocus, flag.Args()[1:])
+		return
+	case "status":
+		runCommand(runStatus, flag.Args()[1:])
+		return
+	case "highlight":
+		runCommand(runHighlight, flag.Args()[1:])
+		return
+	case "productivity":
+		runCommand(runProductivity, flag.Args()[1:])
+		return
+	case "projects":
+		runCommand(runProjects, flag.Args()[1:])
+		return
+	case "export":
+		runCommand(runExport, flag.Args()[1:])
+		return
+	case "native-host":
+		runCommand(runNativeHost, flag.Args()[1:])
+		return
+	case "install-native-host":
+		runCommand(runInstallNativeHost, flag.Args()[1:])
+		return
+	default:
+		slog.Error("unknown command", "command", flag.Arg(0))
+		os.Exit(2)
+	}
+
 	// Check if we're running in a graphical environment (Wayland or X11)
 	if os.Getenv("WAYLAND_DISPLAY") == "" && os.Getenv("DISPLAY") == "" {
-		fmt.Fprintf(os.Stderr, "Error: No graphical display found. Make sure you're running this in a Wayland or X11 environment.\n")
+		slog.Error("no graphical display found, make sure you're running this in a Wayland or X11 environment")
 		os.Exit(1)
 	}
 
@@ -893,46 +1103,115 @@ func main() {
 	if os.Getenv("WAYLAND_DISPLAY") != "" {
 		_, err := exec.LookPath("hyprctl")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: hyprctl not found. This script requires Hyprland on Wayland.\n")
+			slog.Error("hyprctl not found, this program requires Hyprland on Wayland")
 			os.Exit(1)
 		}
 	}
 
 	if *monitor || *track {
 		if *track {
-			fmt.Printf("Tracking application usage (polling every %v). Press Ctrl+C to stop and see summary.\n", *interval)
+			slog.Info("tracking application usage, press Ctrl+C to stop and see summary", "interval", *interval)
 		} else {
-			fmt.Printf("Monitoring window changes (polling every %v). Press Ctrl+C to stop.\n", *interval)
+			slog.Info("monitoring window changes, press Ctrl+C to stop", "interval", *interval)
 		}
 
-		// Handle API submission setup
-		var apiKey string
-		if *submit {
-			// Load environment variables from .env file
-			err := loadEnvFile(".env")
+		// Optionally record raw observations for later replay
+		var recorder *TraceRecorder
+		if *recordPath != "" {
+			var err error
+			recorder, err = NewTraceRecorder(*recordPath)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error loading .env file: %v\n", err)
+				slog.Error("failed to start trace recording", "error", err)
 				os.Exit(1)
 			}
+			defer recorder.Close()
+			slog.Info("recording window trace", "path", *recordPath)
+		}
 
-			// Get API key from environment
-			apiKey = os.Getenv("RESCUE_TIME_API_KEY")
-			if apiKey == "" {
-				fmt.Fprintf(os.Stderr, "Error: RESCUE_TIME_API_KEY not found in .env file\n")
+		opts := trackerOptions{
+			Interval:           *interval,
+			SubmissionInterval: *submissionInterval,
+			ShutdownTimeout:    *shutdownTimeout,
+			FocusPoll:          *focusPoll,
+			Recorder:           recorder,
+			Browser:            &BrowserTabs{FullURL: *browserFullURL},
+		}
+		if *processes {
+			opts.Terminals = &TerminalResolver{}
+		}
+		if *projects {
+			opts.Projects = &ProjectResolver{}
+		}
+
+		// Handle API submission setup
+		if *submit || *dryRun {
+			var dryRunOut io.Writer
+			if *dryRun {
+				dryRunOut = os.Stdout
+				if *dryRunOutput != "" {
+					f, err := os.OpenFile(*dryRunOutput, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+					if err != nil {
+						slog.Error("failed to open dry-run output", "error", err)
+						os.Exit(1)
+					}
+					defer f.Close()
+					dryRunOut = f
+				}
+				slog.Info("dry run: requests are printed instead of sent", "output", *dryRunOutput)
+				opts.FocusPoll = 0 // nothing goes over the network in a dry run
+			}
+
+			submitter, err := setupSubmitter(*outboxPath, dryRunOut)
+			if err != nil {
+				slog.Error("failed to set up submission", "error", err)
 				os.Exit(1)
 			}
+			submitter.ProjectDetails = *projects && *projectDetails
+			opts.Submitter = submitter
+		}
 
-			// Call with API submission enabled
-			monitorWindowChanges(*interval, true, apiKey, *submissionInterval)
-		} else {
-			// Call without API submission
-			monitorWindowChanges(*interval, false, "", 0)
+		// Server-driven settings, unless -submission-interval was given
+		overridden := false
+		flag.Visit(func(f *flag.Flag) { overridden = overridden || f.Name == "submission-interval" })
+		var configClient *RescueTimeClient
+		if !*dryRun {
+			if dataKey := credential("RESCUE_TIME_DATA_KEY", "server config"); dataKey != "" {
+				configClient = NewRescueTimeClient(endpoints, "", os.Getenv("RESCUE_TIME_ACCOUNT_KEY"), dataKey)
+			}
+		}
+		config, err := OpenConfigSync(configClient, statePath("config.json"), *submissionInterval, overridden)
+		if err != nil {
+			slog.Error("failed to load client config", "error", err)
+			os.Exit(1)
+		}
+		opts.Config = config
+
+		opts.Enforcer, err = setupBlocking(*blockAction, *blockListPath, !*dryRun)
+		if err != nil {
+			slog.Error("failed to set up blocking", "error", err)
+			os.Exit(1)
 		}
+		opts.Alerts, err = setupAlerts(*alertsPath, *quietHours, !*dryRun)
+		if err != nil {
+			slog.Error("failed to set up alerts", "error", err)
+			os.Exit(1)
+		}
+		opts.Productivity, err = LoadProductivityMap(*productivityPath, statePath("productivity.json"))
+		if err != nil {
+			slog.Error("failed to load productivity map", "error", err)
+			os.Exit(1)
+		}
+		if err := setupHighlightReminder(opts.Alerts, *highlightsAt, *reposPath); err != nil {
+			slog.Error("failed to set up highlight proposals", "error", err)
+			os.Exit(1)
+		}
+
+		monitorWindowChanges(opts)
 	} else {
 		// Single execution mode
 		currentInfo, err := getCurrentWindowInfo()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting window info: %v\n", err)
+			slog.Error("failed to get window info", "error", err)
 			os.Exit(1)
 		}
 		fmt.Println(currentInfo)
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2025, 10, 2, 9, 0, 0, 0, time.UTC)
+
+// fakeWindowSource returns a settable window and signals every poll on polled
+type fakeWindowSource struct {
+	mu     sync.Mutex
+	window *HyprlandWindow
+	err    error
+	polled chan struct{}
+}
+
+func newFakeWindowSource(class, title string) *fakeWindowSource {
+	src := &fakeWindowSource{polled: make(chan struct{}, 16)}
+	src.Set(class, title)
+	return src
+}
+
+func (s *fakeWindowSource) Set(class, title string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window = &HyprlandWindow{Class: class, Title: title}
+	s.err = nil
+}
+
+func (s *fakeWindowSource) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *fakeWindowSource) ActiveWindow() (*HyprlandWindow, error) {
+	s.mu.Lock()
+	w, err := s.window, s.err
+	s.mu.Unlock()
+	defer func() { s.polled <- struct{}{} }()
+	if err != nil {
+		return nil, err
+	}
+	copied := *w
+	return &copied, nil
+}
+
+// monitorHarness runs a Monitor against a ManualClock and a fakeWindowSource
+type monitorHarness struct {
+	t       *testing.T
+	clock   *ManualClock
+	source  *fakeWindowSource
+	monitor *Monitor
+	out     *bytes.Buffer
+	submits chan map[string]ActivitySummary
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func startMonitor(t *testing.T, submissionInterval time.Duration) *monitorHarness {
+	t.Helper()
+	return startMonitorWith(t, submissionInterval, nil)
+}
+
+// startMonitorWith is startMonitor with a hook to adjust the Monitor before it runs
+func startMonitorWith(t *testing.T, submissionInterval time.Duration, configure func(m *Monitor)) *monitorHarness {
+	t.Helper()
+	h := &monitorHarness{
+		t:       t,
+		clock:   NewManualClock(testStart),
+		source:  newFakeWindowSource("firefox", "GitHub"),
+		out:     &bytes.Buffer{},
+		submits: make(chan map[string]ActivitySummary, 16),
+		done:    make(chan struct{}),
+	}
+	h.monitor = &Monitor{
+		Source:   h.source,
+		Clock:    h.clock,
+		Interval: time.Second,
+		Out:      h.out,
+	}
+	tickers := 1
+	if submissionInterval > 0 {
+		h.monitor.SubmissionInterval = submissionInterval
+		h.monitor.Submit = func(summaries map[string]ActivitySummary) {
+			h.submits <- summaries
+		}
+		tickers = 2
+	}
+	if configure != nil {
+		configure(h.monitor)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	go func() {
+		defer close(h.done)
+		h.monitor.Run(ctx)
+	}()
+
+	// Wait for the initial poll and for the loop to create its tickers
+	<-h.source.polled
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.clock.mu.Lock()
+		n := len(h.clock.tickers)
+		h.clock.mu.Unlock()
+		if n == tickers {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("monitor created %d tickers, want %d", n, tickers)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return h
+}
+
+// stay advances the clock by d one poll interval at a time, waiting for each poll
+func (h *monitorHarness) stay(d time.Duration) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += h.monitor.Interval {
+		h.clock.Advance(h.monitor.Interval)
+		<-h.source.polled
+	}
+}
+
+// focus switches the fake source to a new window and keeps it focused for d
+func (h *monitorHarness) focus(class, title string, d time.Duration) {
+	h.source.Set(class, title)
+	h.stay(d)
+}
+
+// nextSubmit waits for the next call to Submit
+func (h *monitorHarness) nextSubmit() map[string]ActivitySummary {
+	h.t.Helper()
+	select {
+	case s := <-h.submits:
+		return s
+	case <-time.After(2 * time.Second):
+		h.t.Fatal("timed out waiting for submission")
+		return nil
+	}
+}
+
+// shutdown cancels the monitor and waits for Run to return
+func (h *monitorHarness) shutdown() {
+	h.t.Helper()
+	h.cancel()
+	select {
+	case <-h.done:
+	case <-time.After(2 * time.Second):
+		h.t.Fatal("monitor did not shut down")
+	}
+}
+
+func TestTrackerIgnoresShortSessions(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(5 * time.Second)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(20 * time.Second)
+	tracker.EndCurrentSession()
+
+	if len(tracker.sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(tracker.sessions))
+	}
+	got := tracker.sessions[0]
+	if got.AppClass != "kitty" || got.Duration != 20*time.Second {
+		t.Errorf("got %s for %v, want kitty for 20s", got.AppClass, got.Duration)
+	}
+	if !got.StartTime.Equal(testStart.Add(5 * time.Second)) {
+		t.Errorf("start time = %v, want %v", got.StartTime, testStart.Add(5*time.Second))
+	}
+}
+
+func TestTrackerMinDurationIsInclusive(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(10 * time.Second)
+	tracker.EndCurrentSession()
+
+	if len(tracker.sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(tracker.sessions))
+	}
+}
+
+func TestTrackerMergesBriefInterruptions(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.StartSession("kitty", "~") // too short to keep, leaves a 5s gap
+	clock.Advance(5 * time.Second)
+	tracker.StartSession("firefox", "Pull Requests")
+	clock.Advance(time.Minute)
+	tracker.EndCurrentSession()
+
+	if len(tracker.sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1 merged session", len(tracker.sessions))
+	}
+	got := tracker.sessions[0]
+	if got.Duration != 2*time.Minute+5*time.Second {
+		t.Errorf("merged duration = %v, want 2m5s", got.Duration)
+	}
+	if got.WindowTitle != "Pull Requests" {
+		t.Errorf("merged title = %q, want most recent title", got.WindowTitle)
+	}
+}
+
+func TestTrackerMergeThresholdIsInclusive(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("slack", "general")
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("slack", "general")
+	clock.Advance(3 * time.Second) // 30s gap in total
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.EndCurrentSession()
+
+	if len(tracker.sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1 (a 30s gap still merges)", len(tracker.sessions))
+	}
+}
+
+func TestTrackerKeptSessionPreventsMerge(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(20 * time.Second)
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.EndCurrentSession()
+
+	// kitty (20s) is stored between the firefox sessions, so nothing merges
+	if len(tracker.sessions) != 3 {
+		t.Fatalf("got %d sessions, want 3", len(tracker.sessions))
+	}
+}
+
+func TestTrackerDoesNotMergeAfterLongGap(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.StartSession("kitty", "~") // four short sessions add up to a 36s gap
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("slack", "general")
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("slack", "general")
+	clock.Advance(9 * time.Second)
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.EndCurrentSession()
+
+	if len(tracker.sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2 (36s gap exceeds merge threshold)", len(tracker.sessions))
+	}
+}
+
+func TestTrackerDoesNotMergeDifferentApps(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(time.Minute)
+	tracker.EndCurrentSession()
+
+	if len(tracker.sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(tracker.sessions))
+	}
+}
+
+func TestGetActivitySummariesAggregatesSessions(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(2 * time.Minute)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(time.Minute)
+	tracker.StartSession("firefox", "Docs")
+	clock.Advance(3 * time.Minute)
+	tracker.StartSession("kitty", "vim")
+	clock.Advance(30 * time.Second) // still active
+
+	summaries := tracker.GetActivitySummaries()
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+
+	firefox := summaries["firefox"]
+	if firefox.TotalDuration != 5*time.Minute || firefox.SessionCount != 2 {
+		t.Errorf("firefox = %v over %d sessions, want 5m over 2", firefox.TotalDuration, firefox.SessionCount)
+	}
+	if firefox.ActivityDetails != "Docs" {
+		t.Errorf("firefox details = %q, want most recent title", firefox.ActivityDetails)
+	}
+	if !firefox.FirstSeen.Equal(testStart) || !firefox.LastSeen.Equal(testStart.Add(6*time.Minute)) {
+		t.Errorf("firefox seen %v to %v", firefox.FirstSeen, firefox.LastSeen)
+	}
+
+	kitty := summaries["kitty"]
+	if kitty.TotalDuration != time.Minute+30*time.Second || kitty.SessionCount != 2 {
+		t.Errorf("kitty = %v over %d sessions, want 1m30s over 2", kitty.TotalDuration, kitty.SessionCount)
+	}
+	if kitty.ActivityDetails != "vim" {
+		t.Errorf("kitty details = %q, want active window title", kitty.ActivityDetails)
+	}
+	if !kitty.LastSeen.Equal(clock.Now()) {
+		t.Errorf("kitty last seen = %v, want now", kitty.LastSeen)
+	}
+}
+
+func TestClearCompletedSessionsKeepsActiveSessionFromNow(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("firefox", "GitHub")
+	clock.Advance(time.Minute)
+	tracker.StartSession("kitty", "~")
+	clock.Advance(time.Minute)
+
+	tracker.ClearCompletedSessions()
+	clock.Advance(30 * time.Second)
+
+	summaries := tracker.GetActivitySummaries()
+	if _, ok := summaries["firefox"]; ok {
+		t.Error("completed firefox session survived clear")
+	}
+	if got := summaries["kitty"].TotalDuration; got != 30*time.Second {
+		t.Errorf("kitty = %v, want only the 30s since the clear", got)
+	}
+}
+
+func TestSummaryToPayload(t *testing.T) {
+	summary := ActivitySummary{
+		AppClass:        "jetbrains-phpstorm",
+		ActivityDetails: "can-eye-budget – README.md",
+		TotalDuration:   3*time.Minute + 30*time.Second,
+		FirstSeen:       testStart,
+	}
+
+	payload := summaryToPayload(summary)
+	want := RescueTimePayload{
+		StartTime:       "2025-10-02 09:00:00",
+		Duration:        4, // rounded up
+		ActivityName:    "jetbrains-phpstorm",
+		ActivityDetails: "can-eye-budget – README.md",
+	}
+	if payload != want {
+		t.Errorf("got %+v, want %+v", payload, want)
+	}
+}
+
+func TestSummaryToUserClientEvent(t *testing.T) {
+	local := time.FixedZone("AEST", 10*60*60)
+	summary := ActivitySummary{
+		AppClass:        "firefox",
+		ActivityDetails: "GitHub",
+		TotalDuration:   5 * time.Minute,
+		FirstSeen:       time.Date(2025, 10, 3, 0, 0, 0, 0, local),
+	}
+
+	event := summaryToUserClientEvent(summary).UserClientEvent
+	if event.StartTime != "2025-10-02T14:00:00Z" || event.EndTime != "2025-10-02T14:05:00Z" {
+		t.Errorf("got %s to %s, want UTC RFC 3339 times", event.StartTime, event.EndTime)
+	}
+	if event.Application != "firefox" || event.EventDescription != "firefox" || event.WindowTitle != "GitHub" {
+		t.Errorf("unexpected event fields: %+v", event)
+	}
+}
+
+func TestMonitorSubmitsOnInterval(t *testing.T) {
+	h := startMonitor(t, 15*time.Minute)
+
+	h.stay(10 * time.Minute)
+	h.focus("kitty", "~", 5*time.Minute)
+
+	// The switch to kitty is only observed on the next poll, one second later
+	first := h.nextSubmit()
+	if got := first["firefox"].TotalDuration; got != 10*time.Minute+time.Second {
+		t.Errorf("first submission firefox = %v, want 10m1s", got)
+	}
+	if got := first["kitty"].TotalDuration; got != 5*time.Minute-time.Second {
+		t.Errorf("first submission kitty = %v, want 4m59s", got)
+	}
+
+	h.stay(5 * time.Minute)
+	h.shutdown()
+
+	final := h.nextSubmit()
+	if _, ok := final["firefox"]; ok {
+		t.Error("firefox was submitted twice")
+	}
+	if got := final["kitty"].TotalDuration; got != 5*time.Minute {
+		t.Errorf("final submission kitty = %v, want only the 5m after the boundary", got)
+	}
+}
+
+func TestMonitorShutdownPrintsSummary(t *testing.T) {
+	h := startMonitor(t, 0)
+
+	h.stay(time.Minute)
+	h.focus("kitty", "~", 2*time.Minute)
+	h.shutdown()
+
+	out := h.out.String()
+	for _, want := range []string{
+		"Active Window: GitHub (firefox) [09:00:00]",
+		"Active Window: ~ (kitty) [09:01:01]",
+		"=== Activity Summary ===",
+		"Total tracking time: 3m0s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if h.monitor.Tracker.currentSession.Active {
+		t.Error("current session still active after shutdown")
+	}
+}
+
+func TestMonitorIgnoresPollErrors(t *testing.T) {
+	h := startMonitor(t, 0)
+
+	h.stay(time.Minute)
+	h.source.Fail(errors.New("hyprctl: connection refused"))
+	h.stay(10 * time.Second)
+	h.source.Set("firefox", "GitHub")
+	h.stay(time.Minute)
+	h.shutdown()
+
+	summaries := h.monitor.Tracker.GetActivitySummaries()
+	if len(summaries) != 1 || summaries["firefox"].TotalDuration != 2*time.Minute+10*time.Second {
+		t.Errorf("poll errors interrupted tracking: %+v", summaries)
+	}
+}
+
+func TestMonitorTitleChangeStartsNewSession(t *testing.T) {
+	h := startMonitor(t, 0)
+
+	h.stay(time.Minute)
+	h.focus("firefox", "Docs", time.Minute)
+	h.shutdown()
+
+	summaries := h.monitor.Tracker.GetActivitySummaries()
+	firefox := summaries["firefox"]
+	if firefox.SessionCount != 1 {
+		// the title change splits the session, and the two halves merge back
+		t.Errorf("firefox sessions = %d, want 1 merged session", firefox.SessionCount)
+	}
+	if firefox.ActivityDetails != "Docs" {
+		t.Errorf("firefox details = %q, want Docs", firefox.ActivityDetails)
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Kinds of local alert rules
+const (
+	alertOver       = "over"       // time in matching applications went over the threshold
+	alertFocus      = "focus"      // focus work reached the threshold
+	alertTotal      = "total"      // tracked time reached the threshold
+	alertProductive = "productive" // productive time reached the threshold
+)
+
+// Patterns of "over" rules that stand for productivity levels instead of
+// applications
+const (
+	alertPatternDistracting = "distracting" // distracting or very distracting
+	alertPatternProductive  = "productive"  // productive or very productive
+)
+
+const (
+	alertPoll             = time.Minute        // how often local rules are evaluated
+	alertFeedPoll         = 5 * time.Minute    // how often the Alerts Feed is fetched
+	alertServerMaxAge     = 6 * time.Hour      // older server alerts are not shown
+	alertHistoryRetention = 7 * 24 * time.Hour // how long sent alerts are remembered
+)
+
+// AlertRule is a local goal evaluated against today's tracked activity
+type AlertRule struct {
+	Kind      string // alertOver, alertFocus, alertTotal or alertProductive
+	Threshold time.Duration
+	Patterns  []string // applications, sites, workspaces or levels counted by alertOver, lower case
+}
+
+// String formats the rule as it is written in the rules file
+func (r AlertRule) String() string {
+	return strings.Join(append([]string{r.Kind, r.Threshold.String()}, r.Patterns...), " ")
+}
+
+// Evaluate returns the time counted by the rule and whether it triggers.
+// Productivity levels are looked up in classes.
+func (r AlertRule) Evaluate(summaries []ActivitySummary, classes *ProductivityMap) (time.Duration, bool) {
+	var total time.Duration
+	for _, summary := range summaries {
+		level := levelNeutral
+		if rule, ok := classes.ClassifyContext(summary.AppClass, summary.ActivityDetails, summary.SessionContext); ok {
+			level = rule.Level
+		}
+		switch r.Kind {
+		case alertOver:
+			window := &HyprlandWindow{Class: summary.AppClass, Title: summary.ActivityDetails}
+			for _, pattern := range r.Patterns {
+				matched := false
+				switch pattern {
+				case alertPatternDistracting:
+					matched = level < levelNeutral
+				case alertPatternProductive:
+					matched = level > levelNeutral
+				default:
+					if isPlacementPattern(pattern) {
+						matched = matchPlacement(pattern, summary.SessionContext)
+					} else {
+						matched = (BlockRule{Pattern: pattern}).Matches(window)
+					}
+				}
+				if matched {
+					total += summary.TotalDuration
+					break
+				}
+			}
+		case alertFocus:
+			total += summary.FocusDuration
+		case alertTotal:
+			total += summary.TotalDuration
+		case alertProductive:
+			if level > levelNeutral {
+				total += summary.TotalDuration
+			}
+		}
+	}
+	if r.Kind == alertOver {
+		return total, total > r.Threshold
+	}
+	return total, total >= r.Threshold
+}
+
+// Message describes a triggered rule
+func (r AlertRule) Message(counted time.Duration) string {
+	counted = counted.Round(time.Minute)
+	switch r.Kind {
+	case alertOver:
+		return fmt.Sprintf("More than %v in %s today (%v)", r.Threshold, strings.Join(r.Patterns, ", "), counted)
+	case alertFocus:
+		return fmt.Sprintf("%v of focus work today, goal of %v reached", counted, r.Threshold)
+	case alertProductive:
+		return fmt.Sprintf("%v of productive time today, goal of %v reached", counted, r.Threshold)
+	}
+	return fmt.Sprintf("%v tracked today, goal of %v reached", counted, r.Threshold)
+}
+
+// parseAlertRules reads rules, one per line: a kind, a duration and for
+// "over" the applications, sites, workspaces or levels ("distracting",
+// "productive") it counts. Blank lines and # comments are ignored.
+func parseAlertRules(r io.Reader) ([]AlertRule, error) {
+	var rules []AlertRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(strings.ToLower(text))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a kind and a duration", line)
+		}
+
+		rule := AlertRule{Kind: fields[0], Patterns: fields[2:]}
+		threshold, err := time.ParseDuration(fields[1])
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("line %d: invalid duration %q", line, fields[1])
+		}
+		rule.Threshold = threshold
+
+		switch rule.Kind {
+		case alertOver:
+			if len(rule.Patterns) == 0 {
+				return nil, fmt.Errorf("line %d: over needs at least one application or site", line)
+			}
+		case alertFocus, alertTotal, alertProductive:
+			if len(rule.Patterns) > 0 {
+				return nil, fmt.Errorf("line %d: %s takes no applications", line, rule.Kind)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown rule %q (want over, focus, total or productive)", line, rule.Kind)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %v", err)
+	}
+	return rules, nil
+}
+
+// loadAlertRules reads the rules file, returning no rules if it doesn't exist
+func loadAlertRules(path string) ([]AlertRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open alert rules: %v", err)
+	}
+	defer f.Close()
+
+	rules, err := parseAlertRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// ServerAlert is one triggered alert from the Alerts Feed
+type ServerAlert struct {
+	ID          int64  `json:"id"`
+	AlertID     int64  `json:"alert_id"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"` // in the user's time zone
+}
+
+// Time parses CreatedAt like a focus event time
+func (a ServerAlert) Time() (time.Time, error) {
+	return FocusEvent{CreatedAt: a.CreatedAt}.Time()
+}
+
+// AlertsFeed returns recently triggered alerts, newest first. Alerts are a
+// premium feature; the feed is always empty on RescueTime Lite.
+func (c *RescueTimeClient) AlertsFeed(ctx context.Context) ([]ServerAlert, error) {
+	params := url.Values{"key": {c.APIKey}, "op": {"status"}}
+	body, err := c.sendWithRetry(ctx, "alerts_feed", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.AlertsFeed()+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []ServerAlert
+	if err := json.Unmarshal(body, &alerts); err != nil {
+		return nil, fmt.Errorf("failed to parse alerts feed: %v", err)
+	}
+	return alerts, nil
+}
+
+// QuietHours is a daily window without notifications. It may wrap around
+// midnight; Start == End means no quiet hours.
+type QuietHours struct {
+	Start, End time.Duration // offsets from local midnight
+}
+
+// parseQuietHours parses "22:00-07:00"; "" means none
+func parseQuietHours(s string) (QuietHours, error) {
+	if s == "" {
+		return QuietHours{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("quiet hours must look like 22:00-07:00, got %q", s)
+	}
+	start, err := parseTimeOfDay(from)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	end, err := parseTimeOfDay(to)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	return QuietHours{Start: start, End: end}, nil
+}
+
+// parseTimeOfDay parses "15:04" into an offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (want HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// timeOfDay returns how far into its local day t is, to the minute
+func timeOfDay(t time.Time) time.Duration {
+	t = t.Local()
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// Contains reports whether t falls into the quiet hours
+func (q QuietHours) Contains(t time.Time) bool {
+	if q.Start == q.End {
+		return false
+	}
+	offset := timeOfDay(t)
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// Notifier shows desktop notifications
+type Notifier interface {
+	Notify(title, message string) error
+}
+
+// desktopNotifier calls org.freedesktop.Notifications on the session bus
+// through gdbus, falling back to notify-send
+type desktopNotifier struct{}
+
+func (desktopNotifier) Notify(title, message string) error {
+	err := exec.Command("gdbus", "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		gvariantString("RescueTime"), "0", gvariantString(""), gvariantString(title), gvariantString(message),
+		"[]", "{}", "10000").Run()
+	if err == nil {
+		return nil
+	}
+	if fallback := exec.Command("notify-send", "-a", "RescueTime", title, message).Run(); fallback != nil {
+		return fmt.Errorf("gdbus failed: %v; notify-send failed: %v", err, fallback)
+	}
+	return nil
+}
+
+// gvariantString quotes s as a GVariant text format string for gdbus
+func gvariantString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// Alert is a notification waiting to be shown
+type Alert struct {
+	Key     string // identifies the alert so it's only shown once
+	Title   string
+	Message string
+}
+
+// AlertMonitor evaluates local rules and merges in the Alerts Feed, showing
+// each alert once. Alerts due during quiet hours are held until they end;
+// local rules still apply then, server alerts only while recent.
+type AlertMonitor struct {
+	Rules        []AlertRule
+	Productivity *ProductivityMap         // classifies activity for level-based rules
+	Today        func() []ActivitySummary // today's tracked activity
+	Client       *RescueTimeClient        // fetches the Alerts Feed; nil for local rules only
+	Notifier     Notifier
+	Clock        Clock
+	Quiet        QuietHours
+	HistoryPath  string        // sent alerts ("" keeps them in memory only)
+	PollInterval time.Duration // how often rules are evaluated
+
+	// Propose suggests highlights from today's activity; from HighlightsAt
+	// on, a reminder to review them is shown once a day (nil disables)
+	Propose      func([]ActivitySummary) []Highlight
+	HighlightsAt time.Duration // time of day
+
+	sent      map[string]time.Time // alert keys already shown
+	server    []ServerAlert        // last fetched feed
+	lastFeed  time.Time
+	proposed  string // day Propose last ran, as it runs git for every repository
+	proposals int    // number of highlights it proposed
+}
+
+// setupAlerts loads the rules and the history of sent alerts. The Alerts
+// Feed is merged in when fetch is set and an API key is available.
+func setupAlerts(rulesPath, quietHours string, fetch bool) (*AlertMonitor, error) {
+	rules, err := loadAlertRules(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+	quiet, err := parseQuietHours(quietHours)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &AlertMonitor{
+		Rules:        rules,
+		Notifier:     desktopNotifier{},
+		Clock:        realClock{},
+		Quiet:        quiet,
+		HistoryPath:  statePath("alerts.json"),
+		PollInterval: alertPoll,
+	}
+	if fetch {
+		if apiKey := credential("RESCUE_TIME_API_KEY", "alerts feed"); apiKey != "" {
+			m.Client = NewRescueTimeClient(endpoints, apiKey, "", "")
+		}
+	}
+	if err := m.loadHistory(); err != nil {
+		return nil, err
+	}
+	if len(rules) > 0 || m.Client != nil {
+		slog.Info("alerts enabled", "rules", len(rules), "alerts_feed", m.Client != nil)
+	}
+	return m, nil
+}
+
+// loadHistory reads the alerts sent before a restart
+func (m *AlertMonitor) loadHistory() error {
+	m.sent = make(map[string]time.Time)
+	if m.HistoryPath == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(m.HistoryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read alert history: %v", err)
+	}
+	if err := json.Unmarshal(raw, &m.sent); err != nil {
+		return fmt.Errorf("failed to parse alert history %s: %v", m.HistoryPath, err)
+	}
+	return nil
+}
+
+// saveHistory forgets old alerts and writes the rest to disk
+func (m *AlertMonitor) saveHistory(now time.Time) {
+	for key, sentAt := range m.sent {
+		if now.Sub(sentAt) > alertHistoryRetention {
+			delete(m.sent, key)
+		}
+	}
+	if m.HistoryPath == "" {
+		return
+	}
+	raw, err := json.MarshalIndent(m.sent, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(m.HistoryPath, raw, 0600)
+	}
+	if err != nil {
+		slog.Warn("failed to save alert history", "path", m.HistoryPath, "error", err)
+	}
+}
+
+// Pending returns the alerts due now that haven't been shown yet. Highlights
+// are proposed once a day, from HighlightsAt on.
+func (m *AlertMonitor) Pending(now time.Time) []Alert {
+	var alerts []Alert
+	day := now.Local().Format(storeDateFormat)
+	highlightsKey := day + " highlights"
+	_, reminded := m.sent[highlightsKey]
+	remind := m.Propose != nil && !reminded && timeOfDay(now) >= m.HighlightsAt
+	if (len(m.Rules) > 0 || remind) && m.Today != nil {
+		summaries := m.Today()
+		for _, rule := range m.Rules {
+			if counted, ok := rule.Evaluate(summaries, m.Productivity); ok {
+				alerts = append(alerts, Alert{Key: day + " " + rule.String(), Title: "RescueTime goal", Message: rule.Message(counted)})
+			}
+		}
+		if remind && m.proposed != day {
+			m.proposed, m.proposals = day, len(m.Propose(summaries))
+		}
+		if remind && m.proposals > 0 {
+			alerts = append(alerts, Alert{
+				Key:     highlightsKey,
+				Title:   "Daily highlights",
+				Message: fmt.Sprintf("%d proposed from today's work, review with: active-window highlight propose", m.proposals),
+			})
+		}
+	}
+
+	for _, server := range m.server {
+		at, err := server.Time()
+		if err != nil || now.Sub(at) > alertServerMaxAge {
+			continue
+		}
+		alerts = append(alerts, Alert{Key: fmt.Sprintf("server %d", server.ID), Title: "RescueTime alert", Message: server.Description})
+	}
+
+	pending := alerts[:0]
+	for _, alert := range alerts {
+		if _, shown := m.sent[alert.Key]; !shown {
+			pending = append(pending, alert)
+		}
+	}
+	return pending
+}
+
+// Check fetches the feed when due and shows pending alerts outside quiet hours
+func (m *AlertMonitor) Check(ctx context.Context) {
+	now := m.Clock.Now()
+	if m.Client != nil && now.Sub(m.lastFeed) >= alertFeedPoll {
+		m.lastFeed = now
+		server, err := m.Client.AlertsFeed(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("failed to fetch alerts feed", "error", err)
+			}
+		} else {
+			m.server = server
+		}
+	}
+
+	pending := m.Pending(now)
+	if len(pending) == 0 {
+		return
+	}
+	if m.Quiet.Contains(now) {
+		slog.Debug("holding alerts during quiet hours", "count", len(pending))
+		return
+	}
+
+	for _, alert := range pending {
+		if err := m.Notifier.Notify(alert.Title, alert.Message); err != nil {
+			slog.Warn("failed to show alert, will retry", "alert", alert.Message, "error", err)
+			continue
+		}
+		slog.Info("alert shown", "alert", alert.Message)
+		m.sent[alert.Key] = now
+	}
+	m.saveHistory(now)
+}
+
+// Run checks for alerts every PollInterval until ctx is cancelled. A nil
+// monitor or one with nothing to evaluate returns right away.
+func (m *AlertMonitor) Run(ctx context.Context) {
+	if m == nil || (len(m.Rules) == 0 && m.Client == nil && m.Propose == nil) {
+		return
+	}
+	ticker := m.Clock.NewTicker(m.PollInterval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// todaysActivity combines what the store recorded today with today's part of
+// the tracker's summaries since the last submission. Without submission the
+// tracker holds everything since it started, days before midnight included.
+func todaysActivity(store *ActivityStore, tracker *ActivityTracker, now time.Time) []ActivitySummary {
+	local := now.Local()
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+
+	var summaries []ActivitySummary
+	if store != nil {
+		stored, err := store.Day(day)
+		if err != nil {
+			slog.Debug("failed to read today's activity", "error", err)
+		}
+		summaries = append(summaries, stored...)
+	}
+	for _, summary := range tracker.SummariesSince(day) {
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingNotifier records notifications instead of showing them
+type recordingNotifier struct {
+	shown []string
+}
+
+func (n *recordingNotifier) Notify(title, message string) error {
+	n.shown = append(n.shown, message)
+	return nil
+}
+
+func TestParseAlertRules(t *testing.T) {
+	rules, err := parseAlertRules(strings.NewReader(`
+# distractions
+over 1h Discord reddit.com
+focus 2h  # deep work
+total 8h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"over 1h0m0s discord reddit.com", "focus 2h0m0s", "total 8h0m0s"}
+	if len(rules) != len(want) {
+		t.Fatalf("rules = %v, want %v", rules, want)
+	}
+	for i := range want {
+		if rules[i].String() != want[i] {
+			t.Errorf("rule %d = %q, want %q", i, rules[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"over 1h", "focus 2h discord", "under 1h", "total soon", "focus"} {
+		if _, err := parseAlertRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("rule %q accepted", bad)
+		}
+	}
+}
+
+func TestAlertRuleEvaluate(t *testing.T) {
+	summaries := []ActivitySummary{
+		{AppClass: "discord", ActivityDetails: "#general", TotalDuration: 40 * time.Minute},
+		{AppClass: "firefox", ActivityDetails: "r/golang - Reddit", TotalDuration: 30 * time.Minute},
+		{AppClass: "kitty", ActivityDetails: "vim", TotalDuration: 3 * time.Hour, FocusDuration: 2 * time.Hour},
+	}
+	tests := []struct {
+		rule    AlertRule
+		counted time.Duration
+		fires   bool
+	}{
+		{AlertRule{Kind: alertOver, Threshold: time.Hour, Patterns: []string{"discord", "reddit.com"}}, 70 * time.Minute, true},
+		{AlertRule{Kind: alertOver, Threshold: time.Hour, Patterns: []string{"discord"}}, 40 * time.Minute, false},
+		{AlertRule{Kind: alertFocus, Threshold: 2 * time.Hour}, 2 * time.Hour, true},
+		{AlertRule{Kind: alertTotal, Threshold: 8 * time.Hour}, 250 * time.Minute, false},
+		{AlertRule{Kind: alertOver, Threshold: time.Hour, Patterns: []string{"distracting"}}, 70 * time.Minute, true},
+		{AlertRule{Kind: alertProductive, Threshold: 3 * time.Hour}, 3 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		counted, fires := tt.rule.Evaluate(summaries, nil)
+		if counted != tt.counted || fires != tt.fires {
+			t.Errorf("%s = %v, %v, want %v, %v", tt.rule, counted, fires, tt.counted, tt.fires)
+		}
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	night, err := parseQuietHours("22:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lunch, err := parseQuietHours("12:00-13:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time { return time.Date(2025, 10, 2, hour, minute, 0, 0, time.Local) }
+	tests := []struct {
+		quiet QuietHours
+		at    time.Time
+		want  bool
+	}{
+		{night, at(23, 0), true},
+		{night, at(6, 59), true},
+		{night, at(7, 0), false},
+		{night, at(12, 0), false},
+		{lunch, at(13, 0), true},
+		{lunch, at(13, 30), false},
+		{QuietHours{}, at(3, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.quiet.Contains(tt.at); got != tt.want {
+			t.Errorf("%+v contains %s = %v, want %v", tt.quiet, tt.at.Format("15:04"), got, tt.want)
+		}
+	}
+	if _, err := parseQuietHours("10pm"); err == nil {
+		t.Error("invalid quiet hours accepted")
+	}
+}
+
+func TestAlertMonitorMergesFeedWithDedupAndQuietHours(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "key"})
+	start := time.Date(2025, 10, 2, 21, 50, 0, 0, time.Local)
+	mock.AddAlert(1, "Over 2h on social networking", start.Add(-time.Hour))
+	mock.AddAlert(2, "Yesterday's alert", start.Add(-20*time.Hour))
+
+	clock := NewManualClock(start)
+	notifier := &recordingNotifier{}
+	history := filepath.Join(t.TempDir(), "alerts.json")
+	summaries := []ActivitySummary{{AppClass: "kitty", TotalDuration: 90 * time.Minute, FocusDuration: 90 * time.Minute}}
+	newMonitor := func() *AlertMonitor {
+		m := &AlertMonitor{
+			Rules:       []AlertRule{{Kind: alertFocus, Threshold: 2 * time.Hour}},
+			Today:       func() []ActivitySummary { return summaries },
+			Client:      testClient("key", "", ""),
+			Notifier:    notifier,
+			Clock:       clock,
+			Quiet:       QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour},
+			HistoryPath: history,
+		}
+		if err := m.loadHistory(); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	monitor := newMonitor()
+
+	monitor.Check(context.Background())
+	if len(notifier.shown) != 1 || notifier.shown[0] != "Over 2h on social networking" {
+		t.Fatalf("shown = %q, want only the recent server alert", notifier.shown)
+	}
+
+	// The goal is reached during quiet hours and held until they end
+	summaries[0].FocusDuration = 2 * time.Hour
+	clock.Advance(20 * time.Minute)
+	monitor.Check(context.Background())
+	if len(notifier.shown) != 1 {
+		t.Fatalf("shown during quiet hours: %q", notifier.shown[1:])
+	}
+
+	// The held goal is shown once quiet hours are over, and not again after a restart
+	clock.Set(time.Date(2025, 10, 2, 23, 59, 0, 0, time.Local))
+	monitor.Quiet = QuietHours{}
+	monitor.Check(context.Background())
+	if len(notifier.shown) != 2 || !strings.Contains(notifier.shown[1], "goal of 2h0m0s reached") {
+		t.Fatalf("shown = %q, want the focus goal second", notifier.shown)
+	}
+	restarted := newMonitor()
+	restarted.Quiet = QuietHours{}
+	restarted.Check(context.Background())
+	if len(notifier.shown) != 2 {
+		t.Errorf("shown again after restart: %q", notifier.shown[2:])
+	}
+
+	if requests := mock.RequestsTo("alerts_feed"); len(requests) != 4 {
+		t.Errorf("alerts feed fetched %d times, want 4 (every check is past the poll interval)", len(requests))
+	}
+}
+
+func TestHighlightsAreProposedOncePerDay(t *testing.T) {
+	clock := NewManualClock(time.Date(2025, 10, 2, 16, 0, 0, 0, time.Local))
+	notifier := &recordingNotifier{}
+	var runs int
+	var proposals []Highlight
+	monitor := &AlertMonitor{
+		Today:    func() []ActivitySummary { return nil },
+		Notifier: notifier,
+		Clock:    clock,
+		Propose: func([]ActivitySummary) []Highlight {
+			runs++
+			return proposals
+		},
+		HighlightsAt: 17 * time.Hour,
+	}
+	if err := monitor.loadHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		monitor.Check(context.Background())
+		clock.Advance(time.Hour)
+	}
+	if runs != 1 || len(notifier.shown) != 0 {
+		t.Errorf("after a day without proposals: %d runs, shown %q", runs, notifier.shown)
+	}
+
+	proposals = []Highlight{{Description: "widget: Fix login"}}
+	clock.Set(time.Date(2025, 10, 3, 17, 30, 0, 0, time.Local))
+	monitor.Check(context.Background())
+	clock.Advance(time.Minute)
+	monitor.Check(context.Background())
+	if runs != 2 || len(notifier.shown) != 1 || !strings.HasPrefix(notifier.shown[0], "1 proposed") {
+		t.Errorf("next day: %d runs, shown %q", runs, notifier.shown)
+	}
+}
+
+func TestTodaysActivityClipsAtMidnight(t *testing.T) {
+	clock := NewManualClock(time.Date(2025, 10, 1, 21, 0, 0, 0, time.Local))
+	tracker := NewActivityTrackerWithClock(clock)
+	tracker.StartSession("discord", "chat")
+	clock.Advance(time.Hour)
+	tracker.StartSession("firefox", "news")
+	clock.Advance(3 * time.Hour) // until 01:00
+	tracker.StartSession("kitty", "make")
+	clock.Advance(30 * time.Minute)
+
+	totals := make(map[string]time.Duration)
+	for _, summary := range todaysActivity(nil, tracker, clock.Now()) {
+		totals[summary.AppClass] += summary.TotalDuration
+	}
+	want := map[string]time.Duration{"firefox": time.Hour, "kitty": 30 * time.Minute}
+	if len(totals) != len(want) || totals["firefox"] != want["firefox"] || totals["kitty"] != want["kitty"] {
+		t.Errorf("today = %v, want %v", totals, want)
+	}
+}
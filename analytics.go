@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// analyticDateFormat is how the Analytic Data API formats interval rows
+const analyticDateFormat = "2006-01-02T15:04:05"
+
+// AnalyticQuery selects a report from the Analytic Data API
+type AnalyticQuery struct {
+	Perspective  string    // "rank" or "interval"
+	Resolution   string    // "month", "week", "day", "hour" or "minute" (interval perspective)
+	RestrictKind string    // "overview", "category", "activity", "productivity" or "efficiency"
+	Begin, End   time.Time // days, inclusive
+}
+
+// hourlyActivityQuery is the report the local comparison is built from: time
+// per activity per hour
+func hourlyActivityQuery(day time.Time) AnalyticQuery {
+	return AnalyticQuery{Perspective: "interval", Resolution: "hour", RestrictKind: "activity", Begin: day, End: day}
+}
+
+// AnalyticData is the JSON envelope of the Analytic Data API: each row is an
+// array described by RowHeaders
+type AnalyticData struct {
+	Notes      string   `json:"notes"`
+	RowHeaders []string `json:"row_headers"`
+	Rows       [][]any  `json:"rows"`
+}
+
+// AnalyticActivity is one row of the hourly activity report
+type AnalyticActivity struct {
+	Hour         time.Time
+	Activity     string
+	Category     string
+	Productivity int
+	Duration     time.Duration
+}
+
+// FetchAnalyticData queries the Analytic Data API with the public API key
+func (c *RescueTimeClient) FetchAnalyticData(ctx context.Context, query AnalyticQuery) (*AnalyticData, error) {
+	params := url.Values{
+		"key":            {c.APIKey},
+		"format":         {"json"},
+		"perspective":    {query.Perspective},
+		"restrict_kind":  {query.RestrictKind},
+		"restrict_begin": {query.Begin.Format(storeDateFormat)},
+		"restrict_end":   {query.End.Format(storeDateFormat)},
+	}
+	if query.Resolution != "" {
+		params.Set("resolution_time", query.Resolution)
+	}
+
+	body, err := c.sendWithRetry(ctx, "data", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.AnalyticData()+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var data AnalyticData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse analytic data: %v", err)
+	}
+	return &data, nil
+}
+
+// HourlyActivities decodes the rows of an hourly activity report, using the
+// row headers to find the columns
+func (d *AnalyticData) HourlyActivities() ([]AnalyticActivity, error) {
+	column := make(map[string]int)
+	for i, header := range d.RowHeaders {
+		column[header] = i
+	}
+	for _, required := range []string{"Date", "Time Spent (seconds)", "Activity"} {
+		if _, ok := column[required]; !ok {
+			return nil, fmt.Errorf("analytic data has no %q column", required)
+		}
+	}
+
+	activities := make([]AnalyticActivity, 0, len(d.Rows))
+	for i, row := range d.Rows {
+		field := func(name string) any {
+			if index, ok := column[name]; ok && index < len(row) {
+				return row[index]
+			}
+			return nil
+		}
+
+		date, _ := field("Date").(string)
+		hour, err := time.ParseInLocation(analyticDateFormat, date, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid date %q", i, date)
+		}
+		seconds, _ := field("Time Spent (seconds)").(float64)
+		activity := AnalyticActivity{Hour: hour, Duration: time.Duration(seconds) * time.Second}
+		activity.Activity, _ = field("Activity").(string)
+		activity.Category, _ = field("Category").(string)
+		productivity, _ := field("Productivity").(float64)
+		activity.Productivity = int(productivity)
+		activities = append(activities, activity)
+	}
+	return activities, nil
+}
+
+// analyticsSettleTime is how long after a day ends RescueTime is assumed to
+// have processed all of it, so a cached copy fetched later is final
+const analyticsSettleTime = 24 * time.Hour
+
+// cachedAnalytics is one day of the hourly activity report on disk
+type cachedAnalytics struct {
+	FetchedAt time.Time    `json:"fetched_at"`
+	Data      AnalyticData `json:"data"`
+}
+
+// AnalyticsCache keeps fetched reports, one JSON file per day
+type AnalyticsCache struct {
+	dir string
+}
+
+// NewAnalyticsCache creates a cache in dir
+func NewAnalyticsCache(dir string) *AnalyticsCache {
+	return &AnalyticsCache{dir: dir}
+}
+
+func (c *AnalyticsCache) path(day time.Time) string {
+	return filepath.Join(c.dir, day.Format(storeDateFormat)+".json")
+}
+
+// Load returns the cached report for day, or os.ErrNotExist if it wasn't fetched
+func (c *AnalyticsCache) Load(day time.Time) (*cachedAnalytics, error) {
+	raw, err := os.ReadFile(c.path(day))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read analytics cache: %v", err)
+	}
+	var cached cachedAnalytics
+	if err := json.Unmarshal(raw, &cached); err != nil {
+		return nil, fmt.Errorf("failed to parse analytics cache %s: %v", c.path(day), err)
+	}
+	return &cached, nil
+}
+
+// Save stores the report for day
+func (c *AnalyticsCache) Save(day, fetchedAt time.Time, data *AnalyticData) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create analytics cache: %v", err)
+	}
+	raw, err := json.MarshalIndent(cachedAnalytics{FetchedAt: fetchedAt, Data: *data}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode analytic data: %v", err)
+	}
+	return writeFileAtomic(c.path(day), raw, 0600)
+}
+
+// settled reports whether a cached report can no longer change
+func (c *cachedAnalytics) settled(day time.Time) bool {
+	return c.FetchedAt.After(day.AddDate(0, 0, 1).Add(analyticsSettleTime))
+}
+
+// fetchAnalytics downloads the hourly activity report for each day from
+// from to to (inclusive) into cache, skipping settled days unless refresh is set
+func fetchAnalytics(ctx context.Context, client *RescueTimeClient, cache *AnalyticsCache, from, to time.Time, refresh bool) error {
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		label := day.Format(storeDateFormat)
+		if cached, err := cache.Load(day); err == nil && cached.settled(day) && !refresh {
+			fmt.Printf("%s: cached\n", label)
+			continue
+		}
+
+		data, err := client.FetchAnalyticData(ctx, hourlyActivityQuery(day))
+		if err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+		if err := cache.Save(day, time.Now(), data); err != nil {
+			return err
+		}
+		fmt.Printf("%s: fetched %d rows\n", label, len(data.Rows))
+	}
+	return nil
+}
+
+// parseDateRange reads -from/-to flag values; to defaults to from
+func parseDateRange(fromValue, toValue string) (from, to time.Time, err error) {
+	if toValue == "" {
+		toValue = fromValue
+	}
+	from, err = time.ParseInLocation(storeDateFormat, fromValue, time.Local)
+	if err != nil {
+		return from, to, fmt.Errorf("invalid -from date: %v", err)
+	}
+	to, err = time.ParseInLocation(storeDateFormat, toValue, time.Local)
+	if err != nil {
+		return from, to, fmt.Errorf("invalid -to date: %v", err)
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("-to %s is before -from %s", toValue, fromValue)
+	}
+	return from, to, nil
+}
+
+// runFetch implements the fetch command: it caches what RescueTime recorded
+// for a date range so report can compare it with local tracking
+func runFetch(args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	today := time.Now().Format(storeDateFormat)
+	fromFlag := fs.String("from", today, "First day to fetch (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Last day to fetch (YYYY-MM-DD, default -from)")
+	refresh := fs.Bool("refresh", false, "Fetch days again even if the cached copy is final")
+	fs.Parse(args)
+
+	from, to, err := parseDateRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+
+	client, err := newPublicAPIClient()
+	if err != nil {
+		return err
+	}
+	dir := statePath("analytics")
+	if dir == "" {
+		return fmt.Errorf("no state directory for the analytics cache")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return fetchAnalytics(ctx, client, NewAnalyticsCache(dir), from, to, *refresh)
+}
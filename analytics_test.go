@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitByHour(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 40, 0, 0, time.Local)
+	got := make(map[int]time.Duration)
+	splitByHour(start, start.Add(90*time.Minute), func(hour time.Time, d time.Duration) {
+		got[hour.Hour()] += d
+	})
+	if len(got) != 3 || got[9] != 20*time.Minute || got[10] != time.Hour || got[11] != 10*time.Minute {
+		t.Errorf("split = %v, want 20m, 1h and 10m", got)
+	}
+}
+
+func TestFetchAndCompareWithRescueTime(t *testing.T) {
+	startMockServer(t, MockServerConfig{APIKey: "test-key"})
+	client := testClient("test-key", "", "")
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+
+	// RescueTime only got the first firefox session
+	if err := client.SubmitOfflineTime(context.Background(), summaryToPayload(ActivitySummary{
+		AppClass: "firefox", TotalDuration: 30 * time.Minute, FirstSeen: at(9, 45),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	cache := NewAnalyticsCache(filepath.Join(dir, "analytics"))
+	if err := fetchAnalytics(context.Background(), client, cache, day, day, false); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := cache.Load(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activities, err := cached.Data.HourlyActivities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 2 || activities[0].Duration != 15*time.Minute || !activities[1].Hour.Equal(at(10, 0)) {
+		t.Fatalf("activities = %+v, want 15m at 9:00 and 10:00", activities)
+	}
+
+	store := NewActivityStore(filepath.Join(dir, "activity"))
+	store.Record([]ActivitySummary{
+		{AppClass: "Firefox", TotalDuration: 30 * time.Minute, FirstSeen: at(9, 45)},
+		{AppClass: "kitty", TotalDuration: 20 * time.Minute, FirstSeen: at(11, 0)},
+	})
+	comparison, err := loadComparison(store, cache, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Discrepancy{{"Firefox", 30 * time.Minute, 30 * time.Minute}, {"kitty", 20 * time.Minute, 0}}
+	if len(comparison.Apps) != len(want) {
+		t.Fatalf("apps = %+v, want %+v", comparison.Apps, want)
+	}
+	for i := range want {
+		if comparison.Apps[i] != want[i] {
+			t.Errorf("apps[%d] = %+v, want %+v", i, comparison.Apps[i], want[i])
+		}
+	}
+	if len(comparison.Hours) != 3 || comparison.Hours[2].Diff() != -20*time.Minute {
+		t.Errorf("hours = %+v, want 11:00 missing 20m", comparison.Hours)
+	}
+
+	if _, err := loadComparison(store, cache, day, day.AddDate(0, 0, 1)); err == nil {
+		t.Error("comparison succeeded without fetched data for the second day")
+	}
+}
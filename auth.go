@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuthScheme is a way of authenticating submissions
+type AuthScheme string
+
+// Authentication schemes in the order they are negotiated
+const (
+	AuthBearer AuthScheme = "bearer" // native API, Bearer data_key (plus ?key=account_key)
+	AuthQuery  AuthScheme = "query"  // native API, ?key=account_key
+	AuthLegacy AuthScheme = "legacy" // offline_time_post, ?key=api_key
+)
+
+// Bulk event support, probed on the first batch
+const (
+	bulkUnknown     = ""
+	bulkSupported   = "supported"
+	bulkUnsupported = "unsupported"
+)
+
+// errCredentialsRevoked is returned once every available scheme was rejected
+// with 401. Submissions stay queued until the account is activated again.
+var errCredentialsRevoked = errors.New("credentials revoked — run activate")
+
+// authState is what the negotiator persists between runs
+type authState struct {
+	Scheme      AuthScheme `json:"scheme,omitempty"`
+	Revoked     bool       `json:"revoked,omitempty"`
+	BulkEvents  string     `json:"bulk_events,omitempty"` // whether user_client_events accepts arrays
+	MaxBatch    int        `json:"max_batch,omitempty"`   // largest batch accepted after a 413, 0 if unlimited
+	Credentials string     `json:"credentials"`           // fingerprint of the keys the state applies to
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// AuthNegotiator remembers which authentication scheme the API accepts, so
+// each submission costs one request instead of rediscovering it on a 401
+type AuthNegotiator struct {
+	mu          sync.Mutex
+	path        string // empty keeps the state in memory only
+	credentials string
+	state       authState
+}
+
+// credentialsFingerprint identifies a set of keys without storing them
+func credentialsFingerprint(apiKey, accountKey, dataKey string) string {
+	sum := sha256.Sum256([]byte(apiKey + "\x00" + accountKey + "\x00" + dataKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// OpenAuthNegotiator loads the cached scheme from path. State recorded for
+// different keys is discarded, so new credentials are negotiated from scratch.
+func OpenAuthNegotiator(path, apiKey, accountKey, dataKey string) (*AuthNegotiator, error) {
+	a := &AuthNegotiator{path: path, credentials: credentialsFingerprint(apiKey, accountKey, dataKey)}
+	if path == "" {
+		return a, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth state: %v", err)
+	}
+
+	var state authState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse auth state %s: %v", path, err)
+	}
+	if state.Credentials == a.credentials {
+		a.state = state
+	}
+	return a, nil
+}
+
+// Candidates returns the schemes to try: the cached one first, then the
+// remaining available ones in negotiation order
+func (a *AuthNegotiator) Candidates(available []AuthScheme) []AuthScheme {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	candidates := make([]AuthScheme, 0, len(available))
+	for _, scheme := range available {
+		if scheme == a.state.Scheme {
+			candidates = append(candidates, scheme)
+		}
+	}
+	for _, scheme := range available {
+		if scheme != a.state.Scheme {
+			candidates = append(candidates, scheme)
+		}
+	}
+	return candidates
+}
+
+// Scheme returns the cached working scheme, or "" if none is known
+func (a *AuthNegotiator) Scheme() AuthScheme {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.Scheme
+}
+
+// Revoked reports whether every scheme was rejected
+func (a *AuthNegotiator) Revoked() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.Revoked
+}
+
+// snapshot returns an in-memory copy that never writes to disk
+func (a *AuthNegotiator) snapshot() *AuthNegotiator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &AuthNegotiator{credentials: a.credentials, state: a.state}
+}
+
+// BulkEvents returns whether the native endpoint accepts batches of events
+func (a *AuthNegotiator) BulkEvents() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.BulkEvents
+}
+
+// LearnBulk records the outcome of a batch request
+func (a *AuthNegotiator) LearnBulk(supported bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	support := bulkUnsupported
+	if supported {
+		support = bulkSupported
+	}
+	if a.state.BulkEvents == support {
+		return
+	}
+	slog.Info("probed bulk event submission", "supported", supported)
+	a.state.BulkEvents = support
+	a.saveUnsafe()
+}
+
+// BatchSize returns how many events to send per bulk request: limit, or
+// fewer if larger batches were rejected as too large
+func (a *AuthNegotiator) BatchSize(limit int) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state.MaxBatch > 0 {
+		limit = min(limit, a.state.MaxBatch)
+	}
+	return max(limit, 1)
+}
+
+// LimitBatch records that batches of more than size events are too large
+func (a *AuthNegotiator) LimitBatch(size int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.state.MaxBatch = max(size, 1)
+	a.saveUnsafe()
+}
+
+// Learn records a scheme the API accepted
+func (a *AuthNegotiator) Learn(scheme AuthScheme) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.state.Scheme == scheme && !a.state.Revoked {
+		return
+	}
+	slog.Info("negotiated authentication", "scheme", scheme, "previous", a.state.Scheme)
+	a.state.Scheme = scheme
+	a.state.Revoked = false
+	a.saveUnsafe()
+}
+
+// Revoke records that no scheme is accepted any more
+func (a *AuthNegotiator) Revoke() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.state.Revoked {
+		return
+	}
+	slog.Error("all authentication schemes were rejected, run activate to restore submissions")
+	notifyStatus("Credentials revoked — run activate")
+	a.state.Scheme = ""
+	a.state.Revoked = true
+	a.saveUnsafe()
+}
+
+// saveUnsafe persists the state (caller must hold lock). Failures only cost a
+// renegotiation on the next start, so they are logged rather than returned.
+func (a *AuthNegotiator) saveUnsafe() {
+	if a.path == "" {
+		return
+	}
+	a.state.Credentials = a.credentials
+	a.state.UpdatedAt = time.Now()
+
+	raw, err := json.MarshalIndent(a.state, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(a.path, raw, 0600)
+	}
+	if err != nil {
+		slog.Warn("failed to save auth state", "path", a.path, "error", err)
+	}
+}
+
+// runActivate implements the activate command: it exchanges the account
+// email and password for native API keys and resets the negotiated scheme
+func runActivate(args []string) error {
+	fs := flag.NewFlagSet("activate", flag.ExitOnError)
+	email := fs.String("email", os.Getenv("RESCUE_TIME_EMAIL"), "RescueTime account email (default $RESCUE_TIME_EMAIL)")
+	password := fs.String("password", "", "RescueTime account password (default $RESCUE_TIME_PASSWORD)")
+	envFile := fs.String("env-file", ".env", "File the account keys are saved to")
+	fs.Parse(args)
+
+	if *password == "" {
+		*password = os.Getenv("RESCUE_TIME_PASSWORD")
+	}
+	if *email == "" || *password == "" {
+		return fmt.Errorf("activate needs -email and -password (or RESCUE_TIME_EMAIL and RESCUE_TIME_PASSWORD)")
+	}
+
+	// Keep existing keys (such as a manually configured data_key) unless activation replaces them
+	if _, err := os.Stat(*envFile); err == nil {
+		if err := loadEnvFile(*envFile); err != nil {
+			return err
+		}
+	}
+
+	client := NewRescueTimeClient(endpoints, "", "", "")
+	response, err := client.Activate(context.Background(), *email, *password)
+	if err != nil {
+		return err
+	}
+	if response.DataKey == "" {
+		response.DataKey = os.Getenv("RESCUE_TIME_DATA_KEY")
+	}
+
+	if err := saveCredentialsToEnv(*envFile, response); err != nil {
+		return err
+	}
+
+	// New keys invalidate whatever was negotiated, including a revoked status
+	if path := statePath("auth.json"); path != "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to reset auth state: %v", err)
+		}
+	}
+
+	slog.Info("activated RescueTime account", "email", *email, "env_file", *envFile, "data_key", response.DataKey != "")
+	return nil
+}
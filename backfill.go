@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// backfillDay is what backfill found for one day of the local store
+type backfillDay struct {
+	Day       time.Time
+	Tracked   int               // summaries long enough to submit
+	Submitted int               // already covered by the ledger
+	Missing   []ActivitySummary // to submit, trimmed to the uncovered time
+}
+
+// findMissing compares the local store against the ledger for each day from
+// from to to (inclusive)
+func findMissing(store *ActivityStore, ledger *Ledger, from, to time.Time) ([]backfillDay, error) {
+	var days []backfillDay
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		summaries, err := store.Day(day)
+		if err != nil {
+			return nil, err
+		}
+
+		result := backfillDay{Day: day}
+		for _, summary := range summaries {
+			if !shouldSubmit(summary) {
+				continue
+			}
+			result.Tracked++
+			missing, ok := ledger.Reconcile(summary)
+			if !ok {
+				result.Submitted++
+				continue
+			}
+			result.Missing = append(result.Missing, missing)
+		}
+		days = append(days, result)
+	}
+	return days, nil
+}
+
+// missingDuration is the total time of the missing summaries
+func (d backfillDay) missingDuration() time.Duration {
+	var total time.Duration
+	for _, summary := range d.Missing {
+		total += summary.TotalDuration
+	}
+	return total
+}
+
+// runBackfill implements the backfill command: it resubmits locally tracked
+// activity that the ledger shows never reached RescueTime
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromFlag := fs.String("from", "", "First day to backfill (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Last day to backfill (YYYY-MM-DD, default -from)")
+	outboxPath := fs.String("outbox", "", "Outbox to queue into when the tracker isn't running (default $XDG_STATE_HOME/rescuetime-linux/outbox.json)")
+	socket := fs.String("socket", controlSocketPath(), "Control socket of the running tracker")
+	dryRun := fs.Bool("dry-run", false, "Print the requests instead of sending them")
+	fs.Parse(args)
+
+	if *fromFlag == "" {
+		return fmt.Errorf("backfill needs -from YYYY-MM-DD")
+	}
+	from, to, err := parseDateRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+
+	storeDir, ledgerPath := statePath("activity"), statePath("ledger.json")
+	if storeDir == "" {
+		return fmt.Errorf("no local activity store")
+	}
+	ledger, err := OpenLedger(ledgerPath)
+	if err != nil {
+		return err
+	}
+	if from.Before(time.Now().Add(-ledgerRetention)) {
+		fmt.Printf("warning: the ledger only remembers %d days, older submissions can't be detected\n", int(ledgerRetention.Hours()/24))
+	}
+
+	days, err := findMissing(NewActivityStore(storeDir), ledger, from, to)
+	if err != nil {
+		return err
+	}
+
+	var missing []ActivitySummary
+	for _, day := range days {
+		fmt.Printf("%s: %d tracked, %d already submitted, %d missing (%v)\n",
+			day.Day.Format(storeDateFormat), day.Tracked, day.Submitted, len(day.Missing), day.missingDuration().Round(time.Minute))
+		missing = append(missing, day.Missing...)
+	}
+	if len(missing) == 0 {
+		fmt.Println("Nothing to backfill")
+		return nil
+	}
+
+	// The running tracker owns the outbox; hand the activities over to it
+	if !*dryRun {
+		body, err := json.Marshal(missing)
+		if err != nil {
+			return fmt.Errorf("failed to encode activities: %v", err)
+		}
+		_, err = controlPost(*socket, "/backfill", body)
+		if err == nil {
+			fmt.Printf("Queued %d activities with the running tracker; see progress with `active-window preview` or its log\n", len(missing))
+			return nil
+		}
+		if !errors.Is(err, errTrackerNotRunning) {
+			return err
+		}
+	}
+
+	var dryRunOut io.Writer
+	if *dryRun {
+		dryRunOut = os.Stdout
+	}
+	submitter, err := setupSubmitter(*outboxPath, dryRunOut)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	queued := submitter.Backfill(missing)
+	fmt.Printf("Submitting %d activities...\n", queued)
+	submitted, remaining := submitter.Flush(ctx)
+	fmt.Printf("Submitted %d activities", submitted)
+	if remaining > 0 {
+		fmt.Printf(", %d remain queued for the next run", remaining)
+	}
+	fmt.Println()
+	return nil
+}
+
+// handleBackfill is the control socket endpoint backfill hands activities to
+func handleBackfill(submitter *Submitter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if submitter == nil {
+			http.Error(w, "submission is not enabled (run with -submit)", http.StatusConflict)
+			return
+		}
+		var summaries []ActivitySummary
+		if err := json.NewDecoder(r.Body).Decode(&summaries); err != nil {
+			http.Error(w, fmt.Sprintf("invalid activities: %v", err), http.StatusBadRequest)
+			return
+		}
+		queued := submitter.Backfill(summaries)
+		fmt.Fprintf(w, "queued %d\n", queued)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestActivityStoreRoundTrip(t *testing.T) {
+	store := NewActivityStore(t.TempDir())
+	day := testStart.Local()
+	summaries := []ActivitySummary{
+		ledgerSummary("kitty", 20*time.Minute, 5*time.Minute),
+		ledgerSummary("firefox", 0, 10*time.Minute),
+		ledgerSummary("firefox", 24*time.Hour, 10*time.Minute),
+	}
+	if err := store.Record(summaries); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Day(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].AppClass != "firefox" || got[1].AppClass != "kitty" {
+		t.Fatalf("Day = %+v, want firefox then kitty", got)
+	}
+	if got, _ := store.Day(day.AddDate(0, 0, 2)); len(got) != 0 {
+		t.Errorf("empty day returned %+v", got)
+	}
+}
+
+func TestBackfillSubmitsOnlyMissingRanges(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{DataKey: "data"})
+	dir := t.TempDir()
+
+	store := NewActivityStore(filepath.Join(dir, "activity"))
+	store.Record([]ActivitySummary{
+		ledgerSummary("firefox", 0, 10*time.Minute),             // submitted
+		ledgerSummary("kitty", 10*time.Minute, 10*time.Minute),  // half submitted
+		ledgerSummary("firefox", 30*time.Minute, 5*time.Minute), // lost
+		ledgerSummary("foot", 40*time.Minute, 30*time.Second),   // too short
+	})
+	ledger, err := OpenLedger(filepath.Join(dir, "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.Record(testStart, ledgerSummary("firefox", 0, 10*time.Minute))
+	ledger.Record(testStart, ledgerSummary("kitty", 10*time.Minute, 5*time.Minute))
+
+	day := testStart.Local()
+	days, err := findMissing(store, ledger, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Tracked != 3 || days[0].Submitted != 1 || len(days[0].Missing) != 2 {
+		t.Fatalf("findMissing = %+v, want 3 tracked, 1 submitted, 2 missing", days)
+	}
+	if d := days[0].missingDuration(); d != 10*time.Minute {
+		t.Errorf("missing duration = %v, want 10m", d)
+	}
+
+	outbox, err := OpenOutbox(filepath.Join(dir, "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitter := NewSubmitter(testClient("", "", "data"), outbox, ledger, NewManualClock(testStart))
+	if n := submitter.Backfill(days[0].Missing); n != 2 {
+		t.Fatalf("queued %d, want 2", n)
+	}
+	if submitted, remaining := submitter.Flush(context.Background()); submitted != 2 || remaining != 0 {
+		t.Errorf("Flush = %d submitted, %d remaining, want 2 and 0", submitted, remaining)
+	}
+	if mock.Accepted() != 2 {
+		t.Errorf("mock stored %d events, want 2", mock.Accepted())
+	}
+
+	// A second backfill finds nothing left to do
+	days, err = findMissing(store, ledger, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days[0].Missing) != 0 {
+		t.Errorf("second backfill found %+v missing", days[0].Missing)
+	}
+}
+
+func TestBackfillSkipsQueuedActivity(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{DataKey: "data"})
+	dir := t.TempDir()
+	ledger, err := OpenLedger(filepath.Join(dir, "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := OpenOutbox(filepath.Join(dir, "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitter := NewSubmitter(testClient("", "", "data"), outbox, ledger, NewManualClock(testStart))
+	submitter.Store = NewActivityStore(filepath.Join(dir, "activity"))
+
+	// Queued during an outage, so still missing from the ledger
+	submitter.Enqueue(map[string]ActivitySummary{"firefox": ledgerSummary("firefox", 0, 10*time.Minute)})
+	day := testStart.Local()
+	days, err := findMissing(submitter.Store, ledger, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := submitter.Backfill(days[0].Missing); n != 0 || outbox.Len() != 1 {
+		t.Fatalf("backfill queued %d, outbox holds %d, want 0 and 1", n, outbox.Len())
+	}
+	if submitted, _ := submitter.Flush(context.Background()); submitted != 1 || mock.Accepted() != 1 {
+		t.Errorf("submitted %d, mock stored %d events, want 1 each", submitted, mock.Accepted())
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// UserClientEventsPayload is the bulk form of UserClientEventPayload
+type UserClientEventsPayload struct {
+	UserClientEvents []UserClientEvent `json:"user_client_events"`
+}
+
+// bulkResponse reports the outcome of each event of a bulk request, in order
+type bulkResponse struct {
+	Results []struct {
+		Status int    `json:"status"`
+		ID     int64  `json:"id,omitempty"`
+		Error  string `json:"error,omitempty"`
+	} `json:"results"`
+}
+
+// errBulkUnconfirmed is returned for a bulk request the endpoint answered
+// without a result per event. It may have stored only some of them, e.g. an
+// endpoint that takes single events and ignored the array.
+var errBulkUnconfirmed = errors.New("bulk response has no result for every event")
+
+// SubmitResult is the outcome of submitting one summary
+type SubmitResult struct {
+	UsedFallback bool // submitted through offline_time_post after the native API failed
+	Err          error
+}
+
+// isStopping reports whether err means no further requests should be made in this flush
+func isStopping(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, errSubmissionsPaused) || errors.Is(err, errCredentialsRevoked)
+}
+
+// SubmitSummaries submits summaries in as few requests as the API allows.
+// With a native scheme negotiated, events are sent in batches of up to
+// MaxBatch; if the endpoint turns out not to accept batches, or the scheme
+// needs renegotiating, summaries are posted individually with up to
+// Concurrency requests in flight. Results are in the order of summaries.
+func (c *RescueTimeClient) SubmitSummaries(ctx context.Context, summaries []ActivitySummary) []SubmitResult {
+	results := make([]SubmitResult, len(summaries))
+	pending := make([]int, len(summaries))
+	for i := range pending {
+		pending[i] = i
+	}
+	if len(pending) == 0 {
+		return results
+	}
+
+	// Without a negotiated scheme the first summary settles it
+	if c.Auth.Scheme() == "" {
+		usedFallback, err := c.SubmitSummary(ctx, summaries[0])
+		results[0] = SubmitResult{UsedFallback: usedFallback, Err: err}
+		pending = pending[1:]
+		if isStopping(ctx, err) {
+			for _, i := range pending {
+				results[i].Err = err
+			}
+			return results
+		}
+	}
+
+	scheme := c.Auth.Scheme()
+	if (scheme == AuthBearer || scheme == AuthQuery) && c.Auth.BulkEvents() != bulkUnsupported {
+		pending = c.submitBatches(ctx, scheme, summaries, pending, results)
+	}
+	c.submitConcurrently(ctx, summaries, pending, results)
+	return results
+}
+
+// submitBatches sends pending summaries in bulk requests, filling in results,
+// and returns the indices that still have to be posted individually
+func (c *RescueTimeClient) submitBatches(ctx context.Context, scheme AuthScheme, summaries []ActivitySummary, pending []int, results []SubmitResult) []int {
+	for len(pending) > 0 {
+		size := min(len(pending), c.Auth.BatchSize(c.MaxBatch))
+		chunk := pending[:size]
+
+		events := make([]UserClientEvent, len(chunk))
+		for i, index := range chunk {
+			events[i] = summaryToUserClientEvent(summaries[index]).UserClientEvent
+		}
+
+		eventErrs, err := c.SubmitUserClientEvents(ctx, scheme, events)
+		if err == nil {
+			for i, index := range chunk {
+				results[index].Err = eventErrs[i]
+			}
+			pending = pending[size:]
+			continue
+		}
+
+		if isStopping(ctx, err) {
+			for _, index := range pending {
+				results[index].Err = err
+			}
+			return nil
+		}
+
+		var apiErr *APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusRequestEntityTooLarge && size > 1:
+			// Retry the same events in smaller batches
+			c.Auth.LimitBatch(size / 2)
+			slog.Info("batch too large, reducing batch size", "max_batch", size/2)
+			continue
+		case errors.Is(err, errBulkUnconfirmed):
+			// Treating it as accepted could lose events, posting them again
+			// at worst duplicates the ones the server kept
+			if c.Auth.BulkEvents() == bulkUnknown {
+				c.Auth.LearnBulk(false)
+			}
+			slog.Warn("batch not confirmed, posting events individually", "count", len(pending), "error", err)
+		case errors.As(err, &apiErr) && !apiErr.retryable() && apiErr.StatusCode != http.StatusUnauthorized &&
+			c.Auth.BulkEvents() == bulkUnknown:
+			// The probe was rejected: the endpoint only takes single events
+			c.Auth.LearnBulk(false)
+		default:
+			slog.Warn("batch submission failed, posting events individually", "count", len(pending), "error", err)
+		}
+		return pending
+	}
+	return nil
+}
+
+// SubmitUserClientEvents submits several events in one request. It returns
+// an error for the whole request, or one entry per event (nil if accepted).
+func (c *RescueTimeClient) SubmitUserClientEvents(ctx context.Context, scheme AuthScheme, events []UserClientEvent) ([]error, error) {
+	jsonData, err := json.Marshal(UserClientEventsPayload{UserClientEvents: events})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	body, err := c.sendWithRetry(ctx, "user_client_events", func() (*http.Request, error) {
+		return c.newEventRequest(ctx, scheme, jsonData)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response bulkResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse bulk response: %v", err)
+	}
+	if len(response.Results) != len(events) {
+		return nil, fmt.Errorf("%w: %d results for %d events", errBulkUnconfirmed, len(response.Results), len(events))
+	}
+	c.Auth.LearnBulk(true)
+
+	eventErrs := make([]error, len(events))
+	failed := 0
+	for i, result := range response.Results {
+		if result.Status < 200 || result.Status >= 300 {
+			eventErrs[i] = &APIError{StatusCode: result.Status, Body: result.Error}
+			failed++
+		}
+	}
+
+	slog.Info("submitted activities", "api", "user_client_events", "auth", scheme, "batch", len(events), "rejected", failed)
+	return eventErrs, nil
+}
+
+// submitConcurrently posts the summaries at indices one by one with at most
+// Concurrency requests in flight. Once a request reports that submissions are
+// paused or revoked, the remaining summaries aren't attempted.
+func (c *RescueTimeClient) submitConcurrently(ctx context.Context, summaries []ActivitySummary, indices []int, results []SubmitResult) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopErr error
+	)
+	slots := make(chan struct{}, max(c.Concurrency, 1))
+
+	for _, index := range indices {
+		slots <- struct{}{}
+
+		mu.Lock()
+		err := stopErr
+		mu.Unlock()
+		if err != nil {
+			results[index].Err = err
+			<-slots
+			continue
+		}
+
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			usedFallback, err := c.SubmitSummary(ctx, summaries[index])
+			results[index] = SubmitResult{UsedFallback: usedFallback, Err: err}
+			if isStopping(ctx, err) {
+				mu.Lock()
+				if stopErr == nil {
+					stopErr = err
+				}
+				mu.Unlock()
+			}
+		}(index)
+	}
+	wg.Wait()
+}
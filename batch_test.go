@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func batchSummaries(n int) []ActivitySummary {
+	summaries := make([]ActivitySummary, n)
+	for i := range summaries {
+		summaries[i] = ActivitySummary{
+			AppClass:      fmt.Sprintf("app-%d", i),
+			TotalDuration: 5 * time.Minute,
+			FirstSeen:     testStart.Add(time.Duration(i) * 5 * time.Minute),
+		}
+	}
+	return summaries
+}
+
+func TestSubmitSummariesBatchesNativeEvents(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{DataKey: "data", BulkEvents: true})
+	client := testClient("", "", "data")
+	client.MaxBatch = 4
+
+	results := client.SubmitSummaries(context.Background(), batchSummaries(9))
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("summary %d failed: %v", i, result.Err)
+		}
+	}
+
+	// One single post negotiates the scheme, then 8 events in two batches
+	if n := len(mock.RequestsTo("user_client_events")); n != 3 {
+		t.Errorf("mock received %d requests, want 3", n)
+	}
+	if support := client.Auth.BulkEvents(); support != bulkSupported {
+		t.Errorf("bulk support = %q, want supported", support)
+	}
+}
+
+func TestSubmitSummariesRequeuesOnlyFailedEvents(t *testing.T) {
+	startMockServer(t, MockServerConfig{DataKey: "data", BulkEvents: true})
+	client := testClient("", "", "data")
+	client.Auth.Learn(AuthBearer)
+
+	summaries := batchSummaries(3)
+	summaries[1].AppClass = "" // rejected by the API
+
+	results := client.SubmitSummaries(context.Background(), summaries)
+	if results[0].Err != nil || results[2].Err != nil {
+		t.Errorf("valid events failed: %+v", results)
+	}
+	var apiErr *APIError
+	if !errors.As(results[1].Err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid event result = %v, want a 400", results[1].Err)
+	}
+}
+
+func TestSubmitSummariesFallsBackWithoutBulkSupport(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{DataKey: "data"})
+	client := testClient("", "", "data")
+	client.Auth.Learn(AuthBearer)
+
+	results := client.SubmitSummaries(context.Background(), batchSummaries(6))
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("summary %d failed: %v", i, result.Err)
+		}
+	}
+
+	requests := mock.RequestsTo("user_client_events")
+	if len(requests) != 7 || requests[0].Status != http.StatusBadRequest {
+		t.Errorf("want a rejected probe and 6 single posts, got %d requests", len(requests))
+	}
+	if support := client.Auth.BulkEvents(); support != bulkUnsupported {
+		t.Errorf("bulk support = %q, want unsupported", support)
+	}
+
+	// The probe isn't repeated
+	client.SubmitSummaries(context.Background(), batchSummaries(2))
+	if n := len(mock.RequestsTo("user_client_events")); n != 9 {
+		t.Errorf("mock received %d requests, want 9", n)
+	}
+}
+
+func TestSubmitSummariesDoesNotTrustUnconfirmedBatches(t *testing.T) {
+	// An endpoint that takes single events answers a batch like one of them
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(server.Close)
+	previous := endpoints
+	endpoints = Endpoints{API: server.URL, Web: server.URL}
+	t.Cleanup(func() { endpoints = previous })
+
+	client := testClient("", "", "data")
+	client.Auth.Learn(AuthBearer)
+	results := client.SubmitSummaries(context.Background(), batchSummaries(3))
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("summary %d failed: %v", i, result.Err)
+		}
+	}
+	if n := requests.Load(); n != 4 {
+		t.Errorf("server received %d requests, want the probe and 3 single posts", n)
+	}
+	if support := client.Auth.BulkEvents(); support != bulkUnsupported {
+		t.Errorf("bulk support = %q, want unsupported", support)
+	}
+}
+
+func TestBatchSizeIsLearnedFromTooLargeResponses(t *testing.T) {
+	auth := &AuthNegotiator{}
+	if size := auth.BatchSize(50); size != 50 {
+		t.Errorf("BatchSize = %d, want 50", size)
+	}
+	auth.LimitBatch(12)
+	if size := auth.BatchSize(50); size != 12 {
+		t.Errorf("BatchSize after a 413 = %d, want 12", size)
+	}
+	if size := auth.snapshot().BatchSize(4); size != 4 {
+		t.Errorf("snapshot BatchSize = %d, want 4", size)
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What the daemon does with a window on the block list during focus time
+const (
+	blockNotify   = "notify"   // only show a notification
+	blockMinimize = "minimize" // move the window to blockWorkspace
+	blockClose    = "close"    // close the window
+)
+
+// blockWorkspace is the special workspace blocked windows are moved to
+const blockWorkspace = "special:rescuetime-blocked"
+
+// blockListRefresh is how often the daemon refetches the block list and
+// rereads the local override file
+const blockListRefresh = time.Hour
+
+// BlockRule is one entry of the block list
+type BlockRule struct {
+	Pattern string `json:"pattern"` // application class or site domain, lower case
+	Source  string `json:"source"`  // "rescuetime" or "local"
+}
+
+// Matches reports whether window belongs to the rule. Application patterns
+// match the class. Site patterns like reddit.com only match browsers: a tab
+// tracked by domain, or a browser window whose title contains the domain or
+// the site name as a word (browsers rarely show the domain). Editors and
+// terminals with a site in their title, e.g. "reddit-scraper – main.go",
+// are left alone.
+func (r BlockRule) Matches(window *HyprlandWindow) bool {
+	class, title := strings.ToLower(window.Class), strings.ToLower(window.Title)
+	if !strings.Contains(r.Pattern, ".") {
+		return strings.Contains(class, r.Pattern)
+	}
+
+	domain := strings.TrimPrefix(r.Pattern, "www.")
+	if class == domain || strings.HasSuffix(class, "."+domain) {
+		return true // keyed by domain through the browser extension
+	}
+	if browserFamily(class) == "" {
+		return false
+	}
+	if strings.Contains(title, r.Pattern) {
+		return true
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) != 2 || labels[0] == "" {
+		return false
+	}
+	for _, word := range strings.FieldsFunc(title, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-')
+	}) {
+		if word == labels[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockList merges the block list fetched from RescueTime with a local
+// override file, in which each line adds a pattern and a line starting with
+// "!" removes one fetched from RescueTime
+type BlockList struct {
+	mu     sync.RWMutex
+	remote []string
+	local  []string
+	allow  map[string]bool
+}
+
+// normalizePattern makes patterns from either source comparable
+func normalizePattern(pattern string) string {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "https://"), "http://")
+	return strings.TrimSuffix(pattern, "/")
+}
+
+// SetRemote replaces the entries fetched from RescueTime
+func (b *BlockList) SetRemote(patterns []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remote = b.remote[:0]
+	for _, pattern := range patterns {
+		if pattern = normalizePattern(pattern); pattern != "" {
+			b.remote = append(b.remote, pattern)
+		}
+	}
+}
+
+// LoadLocal reads the override file at path. A missing file means no overrides.
+func (b *BlockList) LoadLocal(path string) error {
+	var local []string
+	allow := make(map[string]bool)
+
+	f, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to open block list: %v", err)
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if pattern, ok := strings.CutPrefix(line, "!"); ok {
+				allow[normalizePattern(pattern)] = true
+			} else {
+				local = append(local, normalizePattern(line))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading block list: %v", err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.local, b.allow = local, allow
+	return nil
+}
+
+// Rules returns the effective block list
+func (b *BlockList) Rules() []BlockRule {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var rules []BlockRule
+	for _, pattern := range b.remote {
+		if !b.allow[pattern] {
+			rules = append(rules, BlockRule{Pattern: pattern, Source: "rescuetime"})
+		}
+	}
+	for _, pattern := range b.local {
+		rules = append(rules, BlockRule{Pattern: pattern, Source: "local"})
+	}
+	return rules
+}
+
+// Match returns the first rule window matches
+func (b *BlockList) Match(window *HyprlandWindow) (BlockRule, bool) {
+	for _, rule := range b.Rules() {
+		if rule.Matches(window) {
+			return rule, true
+		}
+	}
+	return BlockRule{}, false
+}
+
+// parseBlockList reads the patterns from a block_list response. The format
+// isn't documented, so both a plain array and an object wrapping one are
+// accepted, with entries given as strings or objects naming a site or app.
+func parseBlockList(body []byte) ([]string, error) {
+	var raw any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse block list: %v", err)
+	}
+
+	if object, ok := raw.(map[string]any); ok {
+		raw = nil
+		for _, key := range []string{"block_list", "blocked", "items", "sites"} {
+			if list, ok := object[key]; ok {
+				raw = list
+				break
+			}
+		}
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("block list response has no list of entries")
+	}
+
+	var patterns []string
+	for _, item := range items {
+		switch entry := item.(type) {
+		case string:
+			patterns = append(patterns, entry)
+		case map[string]any:
+			for _, key := range []string{"url", "domain", "name", "pattern", "application"} {
+				if value, ok := entry[key].(string); ok && value != "" {
+					patterns = append(patterns, value)
+					break
+				}
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// FetchBlockList returns the patterns blocked during FocusTime, with native
+// Bearer auth
+func (c *RescueTimeClient) FetchBlockList(ctx context.Context) ([]string, error) {
+	body, err := c.sendWithRetry(ctx, "block_list", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.BlockList(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+c.DataKey)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseBlockList(body)
+}
+
+// cachedBlockList is the last fetched block list on disk
+type cachedBlockList struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Patterns  []string  `json:"patterns"`
+}
+
+// WindowActuator carries out interventions on the compositor
+type WindowActuator interface {
+	Close(window *HyprlandWindow) error
+	Minimize(window *HyprlandWindow) error
+	Notify(message string) error
+}
+
+// newWindowActuator picks the actuator for the running compositor
+func newWindowActuator() WindowActuator {
+	if os.Getenv("SWAYSOCK") != "" {
+		return swayActuator{}
+	}
+	return hyprctlActuator{}
+}
+
+// hyprctlActuator acts on windows through hyprctl dispatch
+type hyprctlActuator struct{}
+
+func (hyprctlActuator) dispatch(args ...string) error {
+	output, err := exec.Command("hyprctl", append([]string{"dispatch"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hyprctl dispatch %s failed: %v", args[0], err)
+	}
+	if reply := strings.TrimSpace(string(output)); reply != "ok" {
+		return fmt.Errorf("hyprctl dispatch %s: %s", args[0], reply)
+	}
+	return nil
+}
+
+func (a hyprctlActuator) Close(window *HyprlandWindow) error {
+	return a.dispatch("closewindow", "address:"+window.Address)
+}
+
+func (a hyprctlActuator) Minimize(window *HyprlandWindow) error {
+	return a.dispatch("movetoworkspacesilent", blockWorkspace+",address:"+window.Address)
+}
+
+func (hyprctlActuator) Notify(message string) error {
+	// Icon 0 is the warning icon, shown for 5 seconds
+	if err := exec.Command("hyprctl", "notify", "0", "5000", "0", message).Run(); err != nil {
+		return fmt.Errorf("hyprctl notify failed: %v", err)
+	}
+	return nil
+}
+
+// swayActuator acts on the focused window through swaymsg
+type swayActuator struct{}
+
+func (swayActuator) Close(*HyprlandWindow) error {
+	return swaymsg("[con_id=__focused__] kill")
+}
+
+func (swayActuator) Minimize(*HyprlandWindow) error {
+	return swaymsg("[con_id=__focused__] move scratchpad")
+}
+
+func (swayActuator) Notify(message string) error {
+	if err := exec.Command("notify-send", "-u", "normal", "-a", "RescueTime", "Focus time", message).Run(); err != nil {
+		return fmt.Errorf("notify-send failed: %v", err)
+	}
+	return nil
+}
+
+func swaymsg(command string) error {
+	if output, err := exec.Command("swaymsg", command).CombinedOutput(); err != nil {
+		return fmt.Errorf("swaymsg %q failed: %v: %s", command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// BlockEnforcer reacts to windows on the block list while a focus session is
+// active. Each window is checked once when it gains focus, so a notification
+// isn't repeated on every poll. A nil enforcer does nothing.
+type BlockEnforcer struct {
+	List      *BlockList
+	Action    string // blockNotify, blockMinimize or blockClose
+	Actuator  WindowActuator
+	Focus     func() bool       // whether a focus session is active
+	Client    *RescueTimeClient // fetches the block list; nil uses the cache only
+	CachePath string            // last fetched block list ("" disables caching)
+	LocalPath string            // local override file ("" for none)
+
+	lastKey string // window acted on last, reset when focus ends
+}
+
+// Check handles one observation of the focused window
+func (e *BlockEnforcer) Check(window *HyprlandWindow) {
+	if e == nil || window == nil {
+		return
+	}
+	if !e.Focus() {
+		e.lastKey = ""
+		return
+	}
+	key := window.Address + "\x00" + window.Class + "\x00" + window.Title
+	if key == e.lastKey {
+		return
+	}
+	e.lastKey = key
+
+	rule, ok := e.List.Match(window)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch e.Action {
+	case blockClose:
+		err = e.Actuator.Close(window)
+	case blockMinimize:
+		err = e.Actuator.Minimize(window)
+	}
+	if err != nil {
+		slog.Error("failed to block window", "action", e.Action, "app", window.Class, "title", window.Title, "error", err)
+		return
+	}
+	slog.Info("blocked window during focus", "action", e.Action, "app", window.Class, "title", window.Title, "rule", rule.Pattern, "source", rule.Source)
+
+	message := fmt.Sprintf("%s is blocked during focus time", window.Class)
+	switch e.Action {
+	case blockClose:
+		message = fmt.Sprintf("Closed %s, it is blocked during focus time", window.Class)
+	case blockMinimize:
+		message = fmt.Sprintf("Moved %s away, it is blocked during focus time", window.Class)
+	}
+	if err := e.Actuator.Notify(message); err != nil {
+		slog.Warn("failed to show block notification", "error", err)
+	}
+}
+
+// Refresh refetches the block list and rereads the local override file. The
+// cached list stays in effect when RescueTime can't be reached.
+func (e *BlockEnforcer) Refresh(ctx context.Context) {
+	if e.LocalPath != "" {
+		if err := e.List.LoadLocal(e.LocalPath); err != nil {
+			slog.Warn("failed to load local block list", "path", e.LocalPath, "error", err)
+		}
+	}
+	if e.Client == nil {
+		return
+	}
+
+	patterns, err := e.Client.FetchBlockList(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("failed to fetch block list, using the cached one", "error", err)
+		}
+		return
+	}
+	e.List.SetRemote(patterns)
+	slog.Info("fetched block list", "entries", len(patterns))
+
+	if e.CachePath != "" {
+		raw, err := json.MarshalIndent(cachedBlockList{FetchedAt: time.Now(), Patterns: patterns}, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(e.CachePath, raw, 0600)
+		}
+		if err != nil {
+			slog.Warn("failed to cache block list", "path", e.CachePath, "error", err)
+		}
+	}
+}
+
+// Run refreshes the block list every blockListRefresh until ctx is cancelled
+func (e *BlockEnforcer) Run(ctx context.Context) {
+	ticker := time.NewTicker(blockListRefresh)
+	defer ticker.Stop()
+	for {
+		e.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setupBlocking creates the enforcer for -block-action from the cached block
+// list and the local override file. With fetch set and a data key available,
+// the list is also kept up to date from RescueTime.
+func setupBlocking(action, localPath string, fetch bool) (*BlockEnforcer, error) {
+	switch action {
+	case "", "off":
+		return nil, nil
+	case blockNotify, blockMinimize, blockClose:
+	default:
+		return nil, fmt.Errorf("unknown -block-action %q, use notify, minimize, close or off", action)
+	}
+
+	enforcer := &BlockEnforcer{
+		List:      &BlockList{},
+		Action:    action,
+		Actuator:  newWindowActuator(),
+		CachePath: statePath("block_list.json"),
+		LocalPath: localPath,
+	}
+
+	if enforcer.CachePath != "" {
+		raw, err := os.ReadFile(enforcer.CachePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read block list cache: %v", err)
+		}
+		if err == nil {
+			var cached cachedBlockList
+			if err := json.Unmarshal(raw, &cached); err != nil {
+				return nil, fmt.Errorf("failed to parse block list cache %s: %v", enforcer.CachePath, err)
+			}
+			enforcer.List.SetRemote(cached.Patterns)
+		}
+	}
+
+	if fetch {
+		if dataKey := credential("RESCUE_TIME_DATA_KEY", "server block list"); dataKey != "" {
+			enforcer.Client = NewRescueTimeClient(endpoints, "", os.Getenv("RESCUE_TIME_ACCOUNT_KEY"), dataKey)
+		}
+	}
+	return enforcer, nil
+}
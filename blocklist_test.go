@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordingActuator records interventions instead of touching the compositor
+type recordingActuator struct {
+	actions []string
+}
+
+func (a *recordingActuator) Close(w *HyprlandWindow) error {
+	a.actions = append(a.actions, "close "+w.Class)
+	return nil
+}
+
+func (a *recordingActuator) Minimize(w *HyprlandWindow) error {
+	a.actions = append(a.actions, "minimize "+w.Class)
+	return nil
+}
+
+func (a *recordingActuator) Notify(message string) error {
+	a.actions = append(a.actions, "notify")
+	return nil
+}
+
+func TestBlockRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		window  HyprlandWindow
+		want    bool
+	}{
+		{"discord", HyprlandWindow{Class: "discord", Title: "#general"}, true},
+		{"reddit.com", HyprlandWindow{Class: "firefox", Title: "r/golang - Reddit - Mozilla Firefox"}, true},
+		{"reddit.com", HyprlandWindow{Class: "firefox", Title: "https://old.reddit.com/r/golang"}, true},
+		{"youtube.com", HyprlandWindow{Class: "firefox", Title: "YouTubers explained - Wikipedia"}, false},
+		{"docs.google.com", HyprlandWindow{Class: "firefox", Title: "Google Search"}, false},
+		{"reddit.com", HyprlandWindow{Class: "reddit.com", Title: "r/golang - old.reddit.com"}, true},
+		{"reddit.com", HyprlandWindow{Class: "old.reddit.com", Title: "r/golang"}, true},
+		{"reddit.com", HyprlandWindow{Class: "code", Title: "reddit-scraper – main.go"}, false},
+		{"reddit.com", HyprlandWindow{Class: "kitty", Title: "curl https://reddit.com/r/golang.json"}, false},
+		{"discord", HyprlandWindow{Class: "kitty", Title: "vim discord-bot.go"}, false},
+	}
+	for _, tt := range tests {
+		if got := (BlockRule{Pattern: tt.pattern}).Matches(&tt.window); got != tt.want {
+			t.Errorf("%s matches %q = %v, want %v", tt.pattern, tt.window.Title, got, tt.want)
+		}
+	}
+}
+
+func TestParseBlockList(t *testing.T) {
+	for _, body := range []string{
+		`["reddit.com", "discord"]`,
+		`{"block_list": [{"url": "reddit.com"}, {"name": "discord"}]}`,
+	} {
+		patterns, err := parseBlockList([]byte(body))
+		if err != nil || len(patterns) != 2 || patterns[0] != "reddit.com" || patterns[1] != "discord" {
+			t.Errorf("parseBlockList(%s) = %v, %v", body, patterns, err)
+		}
+	}
+	if _, err := parseBlockList([]byte(`{"error": "nope"}`)); err == nil {
+		t.Error("response without entries accepted")
+	}
+}
+
+func TestBlockEnforcerDuringFocus(t *testing.T) {
+	startMockServer(t, MockServerConfig{DataKey: "data", BlockList: []string{"reddit.com", "https://twitter.com/"}})
+	dir := t.TempDir()
+	local := filepath.Join(dir, "block-list")
+	if err := os.WriteFile(local, []byte("# overrides\ndiscord\n!twitter.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	focus := false
+	actuator := &recordingActuator{}
+	enforcer := &BlockEnforcer{
+		List:      &BlockList{},
+		Action:    blockMinimize,
+		Actuator:  actuator,
+		Focus:     func() bool { return focus },
+		Client:    testClient("", "", "data"),
+		CachePath: filepath.Join(dir, "block_list.json"),
+		LocalPath: local,
+	}
+	enforcer.Refresh(context.Background())
+
+	rules := enforcer.List.Rules()
+	if len(rules) != 2 || rules[0].Pattern != "reddit.com" || rules[1] != (BlockRule{Pattern: "discord", Source: "local"}) {
+		t.Fatalf("rules = %+v, want reddit.com from RescueTime and discord from the local list", rules)
+	}
+	if _, err := os.Stat(enforcer.CachePath); err != nil {
+		t.Errorf("block list not cached: %v", err)
+	}
+
+	reddit := &HyprlandWindow{Address: "0x1", Class: "firefox", Title: "Reddit - Mozilla Firefox"}
+	twitter := &HyprlandWindow{Address: "0x1", Class: "firefox", Title: "https://twitter.com/home"}
+	discord := &HyprlandWindow{Address: "0x2", Class: "discord", Title: "#general"}
+
+	enforcer.Check(reddit) // not in focus
+	focus = true
+	enforcer.Check(reddit)
+	enforcer.Check(reddit) // same window on the next poll
+	enforcer.Check(twitter)
+	enforcer.Check(discord)
+
+	want := []string{"minimize firefox", "notify", "minimize discord", "notify"}
+	if len(actuator.actions) != len(want) {
+		t.Fatalf("actions = %v, want %v", actuator.actions, want)
+	}
+	for i := range want {
+		if actuator.actions[i] != want[i] {
+			t.Errorf("actions = %v, want %v", actuator.actions, want)
+			break
+		}
+	}
+
+	// A fresh daemon starts from the cache when RescueTime is unreachable
+	t.Setenv("XDG_STATE_HOME", dir)
+	os.MkdirAll(filepath.Dir(statePath("block_list.json")), 0700)
+	if err := os.Rename(enforcer.CachePath, statePath("block_list.json")); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := setupBlocking(blockNotify, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := restarted.List.Rules(); len(rules) != 2 {
+		t.Errorf("rules from cache = %+v, want both RescueTime entries", rules)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// nativeHostName is the native messaging host the browser extension connects to
+const nativeHostName = "io.github.robwilde.rescuetime_linux"
+
+// firefoxExtensionID is the gecko id of the bundled extension in extension/
+const firefoxExtensionID = "rescuetime-linux@robwilde.github.io"
+
+// nativeMessageMaxSize bounds messages from the extension; tab updates are tiny
+const nativeMessageMaxSize = 1 << 20
+
+// Browser families, told apart by how the browser starts the native host
+const (
+	browserFirefox  = "firefox"
+	browserChromium = "chromium"
+)
+
+// Message types of the extension protocol
+const (
+	tabMessageActive = "tab"   // the active tab of the focused browser window
+	tabMessageClear  = "clear" // no browser window has focus any more
+)
+
+// TabMessage is what the extension sends on every tab or window focus change.
+// The native host forwards it unchanged to the tracker, adding Browser.
+type TabMessage struct {
+	Type      string `json:"type"`
+	URL       string `json:"url,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Incognito bool   `json:"incognito,omitempty"`
+	Browser   string `json:"browser,omitempty"`
+}
+
+// readNativeMessage reads one message framed as the browser sends it: a
+// 32-bit length in native byte order followed by that much JSON
+func readNativeMessage(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.NativeEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > nativeMessageMaxSize {
+		return nil, fmt.Errorf("native message of %d bytes is too large", length)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("truncated native message: %v", err)
+	}
+	return message, nil
+}
+
+// writeNativeMessage sends v to the browser with the same framing
+func writeNativeMessage(w io.Writer, v any) error {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.NativeEndian, uint32(len(message))); err != nil {
+		return err
+	}
+	_, err = w.Write(message)
+	return err
+}
+
+// browserFromArgs tells which browser started the native host: Chromium
+// passes the extension origin, Firefox the manifest path and extension id
+func browserFromArgs(args []string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "chrome-extension://") {
+			return browserChromium
+		}
+	}
+	return browserFirefox
+}
+
+// serveNativeHost forwards the extension's messages from in to the tracker
+// listening on socket and answers each on out. A tracker that isn't running
+// is reported to the extension, which keeps sending.
+func serveNativeHost(in io.Reader, out io.Writer, socket, browser string) error {
+	type reply struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error,omitempty"`
+	}
+	for {
+		raw, err := readNativeMessage(in)
+		if errors.Is(err, io.EOF) {
+			return nil // the browser closed the port
+		}
+		if err != nil {
+			return err
+		}
+
+		var message TabMessage
+		if err := json.Unmarshal(raw, &message); err != nil {
+			if err := writeNativeMessage(out, reply{Error: fmt.Sprintf("invalid message: %v", err)}); err != nil {
+				return err
+			}
+			continue
+		}
+		message.Browser = browser
+		body, _ := json.Marshal(message)
+		if _, err := controlPost(socket, "/browser", body); err != nil {
+			slog.Debug("failed to forward tab", "error", err)
+			if err := writeNativeMessage(out, reply{Error: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeNativeMessage(out, reply{OK: true}); err != nil {
+			return err
+		}
+	}
+}
+
+// runNativeHost implements the native-host command, started by the browser
+// through the wrapper that install-native-host writes
+func runNativeHost(args []string) error {
+	return serveNativeHost(os.Stdin, os.Stdout, controlSocketPath(), browserFromArgs(args))
+}
+
+// nativeHostManifest is the manifest browsers look up by nativeHostName
+type nativeHostManifest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Path              string   `json:"path"`
+	Type              string   `json:"type"`
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"` // Firefox
+	AllowedOrigins    []string `json:"allowed_origins,omitempty"`    // Chromium
+}
+
+// nativeHostDirs are where each browser looks for native host manifests,
+// relative to the home directory
+var nativeHostDirs = []struct {
+	Browser string
+	Dir     string
+}{
+	{browserFirefox, ".mozilla/native-messaging-hosts"},
+	{browserFirefox, ".librewolf/native-messaging-hosts"},
+	{browserChromium, ".config/chromium/NativeMessagingHosts"},
+	{browserChromium, ".config/google-chrome/NativeMessagingHosts"},
+	{browserChromium, ".config/BraveSoftware/Brave-Browser/NativeMessagingHosts"},
+	{browserChromium, ".config/vivaldi/NativeMessagingHosts"},
+}
+
+// runInstallNativeHost implements the install-native-host command: it writes
+// a wrapper that starts native-host and registers it with the browsers
+func runInstallNativeHost(args []string) error {
+	fs := flag.NewFlagSet("install-native-host", flag.ExitOnError)
+	chromeID := fs.String("chrome-extension-id", "", "Id of the unpacked extension in Chromium-based browsers (chrome://extensions), Chromium is skipped without it")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine executable path: %v", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to resolve executable path: %v", err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to determine home directory: %v", err)
+	}
+
+	// Manifests can't pass arguments, so the host is a script running the command
+	wrapper := configPath("native-host")
+	if wrapper == "" {
+		return fmt.Errorf("no config directory to install the native host in")
+	}
+	script := fmt.Sprintf("#!/bin/sh\nexec %s native-host \"$@\"\n", shellQuote(exe))
+	if err := writeFileAtomic(wrapper, []byte(script), 0700); err != nil {
+		return fmt.Errorf("failed to write native host: %v", err)
+	}
+
+	for _, target := range nativeHostDirs {
+		manifest := nativeHostManifest{
+			Name:        nativeHostName,
+			Description: "Active tab URLs for rescuetime-linux",
+			Path:        wrapper,
+			Type:        "stdio",
+		}
+		if target.Browser == browserFirefox {
+			manifest.AllowedExtensions = []string{firefoxExtensionID}
+		} else if *chromeID != "" {
+			manifest.AllowedOrigins = []string{"chrome-extension://" + *chromeID + "/"}
+		} else {
+			continue
+		}
+
+		// Only register with browsers that are installed
+		browserDir := filepath.Join(home, filepath.Dir(target.Dir))
+		if _, err := os.Stat(browserDir); err != nil {
+			continue
+		}
+		data, _ := json.MarshalIndent(manifest, "", "  ")
+		path := filepath.Join(home, target.Dir, nativeHostName+".json")
+		if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write manifest: %v", err)
+		}
+		fmt.Printf("Registered %s\n", path)
+	}
+	if *chromeID == "" {
+		fmt.Println("Skipped Chromium-based browsers, pass -chrome-extension-id after loading the extension")
+	}
+	return nil
+}
+
+// shellQuote quotes s for /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// browserFamily returns the family of a browser window class, or "" for
+// other applications
+func browserFamily(class string) string {
+	class = strings.ToLower(class)
+	for _, name := range []string{"firefox", "librewolf", "waterfox", "zen"} {
+		if strings.Contains(class, name) {
+			return browserFirefox
+		}
+	}
+	for _, name := range []string{"chromium", "chrome", "brave", "vivaldi", "edge"} {
+		if strings.Contains(class, name) {
+			return browserChromium
+		}
+	}
+	return ""
+}
+
+// pageURL returns the domain a page is reported under, without a leading
+// www., and the URL shown in titles: scheme and host only unless fullURL.
+// Pages that aren't on the web, such as about: or file: URLs, have no domain.
+func pageURL(raw string, fullURL bool) (domain, shown string, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", "", false
+	}
+	domain = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	u.Host = strings.ToLower(u.Host)
+	u.User = nil
+	u.Fragment = ""
+	if !fullURL {
+		u.Path = "/"
+		u.RawPath = ""
+		u.RawQuery = ""
+	}
+	return domain, u.String(), true
+}
+
+// BrowserTabs keeps the active tab of each browser so browser windows are
+// tracked by domain rather than by page title
+type BrowserTabs struct {
+	FullURL bool // keep path and query in enriched titles
+
+	mu   sync.Mutex
+	tabs map[string]TabMessage // latest active tab by browser family
+}
+
+// Update records a message from the extension
+func (b *BrowserTabs) Update(message TabMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tabs == nil {
+		b.tabs = make(map[string]TabMessage)
+	}
+	if message.Type == tabMessageClear {
+		delete(b.tabs, message.Browser)
+		return
+	}
+	b.tabs[message.Browser] = message
+}
+
+// Enrich returns window keyed by the domain of the active tab, with the URL
+// added to its title. Windows of other applications, private windows and
+// tabs whose title doesn't match the window, such as another browser window
+// without the extension, are returned unchanged.
+func (b *BrowserTabs) Enrich(window *HyprlandWindow) *HyprlandWindow {
+	if b == nil || window == nil {
+		return window
+	}
+	family := browserFamily(window.Class)
+	if family == "" {
+		return window
+	}
+	b.mu.Lock()
+	tab, ok := b.tabs[family]
+	b.mu.Unlock()
+	if !ok || tab.Incognito || tab.Title == "" || !strings.HasPrefix(window.Title, tab.Title) {
+		return window
+	}
+	domain, shown, ok := pageURL(tab.URL, b.FullURL)
+	if !ok {
+		return window
+	}
+
+	enriched := *window
+	enriched.Class = domain
+	enriched.Title = tab.Title + " - " + shown
+	return &enriched
+}
+
+// handleBrowser serves POST /browser for the native host
+func handleBrowser(tabs *BrowserTabs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var message TabMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			http.Error(w, fmt.Sprintf("invalid tab message: %v", err), http.StatusBadRequest)
+			return
+		}
+		if message.Type != tabMessageActive && message.Type != tabMessageClear {
+			http.Error(w, fmt.Sprintf("unknown message type %q", message.Type), http.StatusBadRequest)
+			return
+		}
+		tabs.Update(message)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}\n"))
+	}
+}
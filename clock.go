@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the passage of time so tracker and monitor logic can run
+// against a simulated clock in tests and trace replay
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of time.Ticker used by the monitor loop
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (rt realTicker) C() <-chan time.Time { return rt.t.C }
+func (rt realTicker) Stop()               { rt.t.Stop() }
+
+// ManualClock is a Clock that only moves when told to. Ticks are delivered
+// synchronously on unbuffered channels, so when Advance returns every tick
+// before the last one has been fully handled by the receiving loop.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+// NewManualClock creates a manual clock starting at the given time
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		ch:     make(chan time.Time),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Set moves the clock to t without firing tickers. It is meant for replaying
+// recorded observations, where only Now matters.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock forward by d, firing every ticker deadline passed
+// along the way in chronological order
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		due := c.dueTickersUnsafe(target)
+		if len(due) == 0 {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		t := due[0]
+		c.now = t.next
+		t.next = t.next.Add(t.period)
+		tick := c.now
+		c.mu.Unlock()
+
+		// Send without holding the lock so the receiver can call Now
+		t.ch <- tick
+	}
+}
+
+// dueTickersUnsafe returns active tickers with a deadline at or before target,
+// earliest first (must be called with lock held)
+func (c *ManualClock) dueTickersUnsafe(target time.Time) []*manualTicker {
+	var due []*manualTicker
+	for _, t := range c.tickers {
+		if !t.stopped && !t.next.After(target) {
+			due = append(due, t)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].next.Before(due[j].next) })
+	return due
+}
+
+type manualTicker struct {
+	clock   *ManualClock
+	period  time.Duration
+	next    time.Time
+	ch      chan time.Time
+	stopped bool
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.ch }
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Account plans and the sync interval the official client uses for each
+const (
+	planPremium = "premium"
+	planLite    = "lite"
+)
+
+var planSyncIntervals = map[string]time.Duration{
+	planPremium: 3 * time.Minute,
+	planLite:    30 * time.Minute,
+}
+
+// defaultSubmissionInterval applies when neither a flag nor the server config sets one
+const defaultSubmissionInterval = 15 * time.Minute
+
+// minSyncInterval is the shortest server sync interval applied. Numeric
+// intervals are read as seconds, so one given in minutes would otherwise
+// submit every few seconds.
+const minSyncInterval = time.Minute
+
+// configRefresh is how often the daemon refetches the server config
+const configRefresh = 6 * time.Hour
+
+// ServerConfig is what the tracker understands of GET /config. The response
+// isn't documented, so fields are looked up under the names the desktop
+// client is likely to use and the raw response is kept for status.
+type ServerConfig struct {
+	FetchedAt    time.Time       `json:"fetched_at"`
+	Plan         string          `json:"plan,omitempty"`          // planPremium, planLite or as reported
+	SyncInterval time.Duration   `json:"sync_interval,omitempty"` // explicit upload interval, if given
+	Features     map[string]bool `json:"features,omitempty"`
+	Raw          json.RawMessage `json:"raw,omitempty"`
+}
+
+// normalizePlan maps plan names onto planPremium and planLite
+func normalizePlan(plan string) string {
+	plan = strings.ToLower(strings.TrimSpace(plan))
+	switch {
+	case strings.Contains(plan, "premium"), strings.Contains(plan, "pro"), strings.Contains(plan, "team"):
+		return planPremium
+	case strings.Contains(plan, "lite"), strings.Contains(plan, "free"):
+		return planLite
+	}
+	return plan
+}
+
+// parseServerConfig reads the fields the tracker uses from a /config response
+func parseServerConfig(body []byte) (ServerConfig, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ServerConfig{}, fmt.Errorf("failed to parse config: %v", err)
+	}
+	config := ServerConfig{Raw: json.RawMessage(body)}
+
+	for _, key := range []string{"plan", "account_type", "subscription_type", "subscription"} {
+		if plan, ok := fields[key].(string); ok && plan != "" {
+			config.Plan = normalizePlan(plan)
+			break
+		}
+	}
+	if config.Plan == "" {
+		for _, key := range []string{"premium", "is_premium"} {
+			if premium, ok := fields[key].(bool); ok {
+				config.Plan = planLite
+				if premium {
+					config.Plan = planPremium
+				}
+				break
+			}
+		}
+	}
+
+	for _, key := range []string{"sync_interval", "upload_interval", "data_upload_interval", "sync_interval_seconds"} {
+		switch value := fields[key].(type) {
+		case float64:
+			config.SyncInterval = time.Duration(value) * time.Second
+		case string:
+			config.SyncInterval, _ = time.ParseDuration(value)
+		}
+		if config.SyncInterval > 0 {
+			break
+		}
+	}
+
+	switch features := fields["features"].(type) {
+	case map[string]any:
+		config.Features = make(map[string]bool)
+		for name, enabled := range features {
+			on, _ := enabled.(bool)
+			config.Features[name] = on
+		}
+	case []any:
+		config.Features = make(map[string]bool)
+		for _, name := range features {
+			if name, ok := name.(string); ok {
+				config.Features[name] = true
+			}
+		}
+	}
+	return config, nil
+}
+
+// FetchConfig returns the server-driven client settings, with native Bearer auth
+func (c *RescueTimeClient) FetchConfig(ctx context.Context) (ServerConfig, error) {
+	body, err := c.sendWithRetry(ctx, "config", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.Config(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+c.DataKey)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		return req, nil
+	})
+	if err != nil {
+		return ServerConfig{}, err
+	}
+	return parseServerConfig(body)
+}
+
+// EffectiveConfig is the configuration the tracker runs with: local flags
+// merged over the server config and the built-in defaults
+type EffectiveConfig struct {
+	SubmissionInterval       time.Duration   `json:"submission_interval"`
+	SubmissionIntervalSource string          `json:"submission_interval_source"` // flag, server, plan or default
+	Plan                     string          `json:"plan,omitempty"`
+	Features                 map[string]bool `json:"features,omitempty"`
+	ServerConfigFetchedAt    time.Time       `json:"server_config_fetched_at,omitzero"`
+}
+
+// mergeConfig applies the server config unless the submission interval was
+// set explicitly. An explicit sync interval wins over the plan-based one,
+// but never goes below minSyncInterval.
+func mergeConfig(server *ServerConfig, interval time.Duration, overridden bool) EffectiveConfig {
+	effective := EffectiveConfig{SubmissionInterval: interval, SubmissionIntervalSource: "default"}
+	if overridden {
+		effective.SubmissionIntervalSource = "flag"
+	}
+	if server == nil {
+		return effective
+	}
+
+	effective.Plan = server.Plan
+	effective.Features = server.Features
+	effective.ServerConfigFetchedAt = server.FetchedAt
+	if overridden {
+		return effective
+	}
+	if server.SyncInterval > 0 {
+		effective.SubmissionInterval = server.SyncInterval
+		effective.SubmissionIntervalSource = "server"
+		if server.SyncInterval < minSyncInterval {
+			slog.Warn("server sync interval looks wrong, using the minimum", "sync_interval", server.SyncInterval, "minimum", minSyncInterval)
+			effective.SubmissionInterval = minSyncInterval
+		}
+	} else if d, ok := planSyncIntervals[server.Plan]; ok {
+		effective.SubmissionInterval = d
+		effective.SubmissionIntervalSource = "plan"
+	}
+	return effective
+}
+
+// ConfigSync keeps the server config cached and up to date, and tells the
+// daemon when the effective config changes
+type ConfigSync struct {
+	Client     *RescueTimeClient // fetches /config; nil uses the cache only
+	CachePath  string            // "" disables caching
+	Interval   time.Duration     // submission interval from flags or the default
+	Overridden bool              // whether Interval was set explicitly
+	OnChange   func(EffectiveConfig)
+
+	mu     sync.Mutex
+	server *ServerConfig
+}
+
+// OpenConfigSync loads the cached server config, if any
+func OpenConfigSync(client *RescueTimeClient, cachePath string, interval time.Duration, overridden bool) (*ConfigSync, error) {
+	c := &ConfigSync{Client: client, CachePath: cachePath, Interval: interval, Overridden: overridden}
+	if cachePath == "" {
+		return c, nil
+	}
+
+	raw, err := os.ReadFile(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config cache: %v", err)
+	}
+	var server ServerConfig
+	if err := json.Unmarshal(raw, &server); err != nil {
+		return nil, fmt.Errorf("failed to parse config cache %s: %v", cachePath, err)
+	}
+	c.server = &server
+	return c, nil
+}
+
+// Effective returns the merged configuration
+func (c *ConfigSync) Effective() EffectiveConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return mergeConfig(c.server, c.Interval, c.Overridden)
+}
+
+// Refresh fetches the server config, caching it and reporting a changed
+// effective config. The cached config stays in effect on failure.
+func (c *ConfigSync) Refresh(ctx context.Context) {
+	if c.Client == nil {
+		return
+	}
+	server, err := c.Client.FetchConfig(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("failed to fetch client config, using the cached one", "error", err)
+		}
+		return
+	}
+	server.FetchedAt = time.Now()
+
+	before := c.Effective()
+	c.mu.Lock()
+	c.server = &server
+	c.mu.Unlock()
+	after := c.Effective()
+
+	if c.CachePath != "" {
+		raw, err := json.MarshalIndent(server, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(c.CachePath, raw, 0600)
+		}
+		if err != nil {
+			slog.Warn("failed to cache client config", "path", c.CachePath, "error", err)
+		}
+	}
+
+	slog.Info("fetched client config", "plan", after.Plan, "submission_interval", after.SubmissionInterval, "source", after.SubmissionIntervalSource)
+	if after.SubmissionInterval != before.SubmissionInterval && c.OnChange != nil {
+		c.OnChange(after)
+	}
+}
+
+// Run refreshes the config every configRefresh until ctx is cancelled
+func (c *ConfigSync) Run(ctx context.Context) {
+	ticker := time.NewTicker(configRefresh)
+	defer ticker.Stop()
+	for {
+		c.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enabledFeatures lists the features turned on, sorted
+func (e EffectiveConfig) enabledFeatures() []string {
+	var names []string
+	for name, on := range e.Features {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
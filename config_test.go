@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseServerConfig(t *testing.T) {
+	tests := []struct {
+		body     string
+		plan     string
+		interval time.Duration
+		features int
+	}{
+		{`{"plan": "Premium", "features": {"focustime": true, "offline_time": false}}`, planPremium, 0, 2},
+		{`{"account_type": "RescueTime Lite", "sync_interval": 1800}`, planLite, 30 * time.Minute, 0},
+		{`{"is_premium": true, "upload_interval": "5m", "features": ["focustime"]}`, planPremium, 5 * time.Minute, 1},
+		{`{"unrelated": 1}`, "", 0, 0},
+	}
+	for _, tt := range tests {
+		config, err := parseServerConfig([]byte(tt.body))
+		if err != nil {
+			t.Errorf("parseServerConfig(%s): %v", tt.body, err)
+			continue
+		}
+		if config.Plan != tt.plan || config.SyncInterval != tt.interval || len(config.Features) != tt.features {
+			t.Errorf("parseServerConfig(%s) = %+v, want plan %q, interval %v, %d features", tt.body, config, tt.plan, tt.interval, tt.features)
+		}
+	}
+	if _, err := parseServerConfig([]byte(`<html>`)); err == nil {
+		t.Error("non-JSON response accepted")
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	premium := &ServerConfig{Plan: planPremium}
+	explicit := &ServerConfig{Plan: planLite, SyncInterval: 10 * time.Minute}
+	tests := []struct {
+		name       string
+		server     *ServerConfig
+		overridden bool
+		want       time.Duration
+		source     string
+	}{
+		{"no server config", nil, false, defaultSubmissionInterval, "default"},
+		{"unknown plan", &ServerConfig{Plan: "enterprise"}, false, defaultSubmissionInterval, "default"},
+		{"premium plan", premium, false, 3 * time.Minute, "plan"},
+		{"server interval", explicit, false, 10 * time.Minute, "server"},
+		{"server interval in minutes", &ServerConfig{SyncInterval: 3 * time.Second}, false, minSyncInterval, "server"},
+		{"flag wins", premium, true, defaultSubmissionInterval, "flag"},
+	}
+	for _, tt := range tests {
+		got := mergeConfig(tt.server, defaultSubmissionInterval, tt.overridden)
+		if got.SubmissionInterval != tt.want || got.SubmissionIntervalSource != tt.source {
+			t.Errorf("%s: interval %v (%s), want %v (%s)", tt.name, got.SubmissionInterval, got.SubmissionIntervalSource, tt.want, tt.source)
+		}
+	}
+}
+
+func TestConfigSyncFetchesAndCaches(t *testing.T) {
+	startMockServer(t, MockServerConfig{DataKey: "data", Plan: "premium", Features: map[string]bool{"focustime": true}})
+	cache := filepath.Join(t.TempDir(), "config.json")
+
+	sync, err := OpenConfigSync(testClient("", "", "data"), cache, defaultSubmissionInterval, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []EffectiveConfig
+	sync.OnChange = func(config EffectiveConfig) { changed = append(changed, config) }
+
+	sync.Refresh(context.Background())
+	if len(changed) != 1 || changed[0].SubmissionInterval != 3*time.Minute {
+		t.Fatalf("OnChange calls = %+v, want one with the premium interval", changed)
+	}
+	sync.Refresh(context.Background())
+	if len(changed) != 1 {
+		t.Errorf("OnChange called again for an unchanged config")
+	}
+
+	// A restart without network starts from the cached config
+	offline, err := OpenConfigSync(nil, cache, defaultSubmissionInterval, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	effective := offline.Effective()
+	if effective.Plan != planPremium || effective.SubmissionInterval != 3*time.Minute || !effective.Features["focustime"] {
+		t.Errorf("cached config = %+v, want premium with focustime", effective)
+	}
+	if effective.ServerConfigFetchedAt.IsZero() {
+		t.Error("cached config lost its fetch time")
+	}
+
+	// Wrong credentials keep the previous config
+	sync.Client = testClient("", "", "wrong")
+	sync.Refresh(context.Background())
+	if got := sync.Effective().SubmissionInterval; got != 3*time.Minute {
+		t.Errorf("interval after failed fetch = %v, want the cached 3m", got)
+	}
+}
+
+func TestMonitorAppliesNewSubmissionInterval(t *testing.T) {
+	reconfigure := make(chan time.Duration, 1)
+	h := startMonitorWith(t, 15*time.Minute, func(m *Monitor) { m.Reconfigure = reconfigure })
+
+	reconfigure <- 3 * time.Minute
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.clock.mu.Lock()
+		n := len(h.clock.tickers)
+		h.clock.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("monitor did not replace the submission ticker")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	h.stay(3 * time.Minute)
+	if got := h.nextSubmit()["firefox"].TotalDuration; got != 3*time.Minute {
+		t.Errorf("submission after 3m = %v, want 3m", got)
+	}
+	h.shutdown()
+}
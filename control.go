@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// controlSocketName is the daemon's control socket in $XDG_RUNTIME_DIR
+const controlSocketName = "rescuetime-linux.sock"
+
+// controlSocketPath returns where the running tracker listens for commands.
+// Without $XDG_RUNTIME_DIR the socket goes in a per-user directory in the
+// temporary directory rather than directly in the shared one.
+func controlSocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("rescuetime-linux-%d", os.Getuid()))
+	}
+	return filepath.Join(dir, controlSocketName)
+}
+
+// privateSocketDir creates dir if needed and makes sure only the current user
+// can reach sockets in it, so none are reachable before they are chmodded
+func privateSocketDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("failed to create socket directory: %v", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check socket directory: %v", err)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(st.Uid) != os.Getuid() || info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("refusing to listen in %s: not a directory private to this user", dir)
+	}
+	return nil
+}
+
+// ControlServer serves HTTP on a unix socket so commands such as preview can
+// query the running tracker. Only the owning user can connect.
+type ControlServer struct {
+	Path string
+	mux  *http.ServeMux
+}
+
+// NewControlServer creates a control server listening at path once served
+func NewControlServer(path string) *ControlServer {
+	return &ControlServer{Path: path, mux: http.NewServeMux()}
+}
+
+// HandleFunc registers a handler, e.g. for "GET /preview"
+func (s *ControlServer) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Serve listens until ctx is cancelled, then removes the socket
+func (s *ControlServer) Serve(ctx context.Context) error {
+	if err := privateSocketDir(filepath.Dir(s.Path)); err != nil {
+		return err
+	}
+	// A socket left behind by a crashed instance refuses connections
+	if conn, err := net.Dial("unix", s.Path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another instance is listening on %s", s.Path)
+	}
+	os.Remove(s.Path)
+
+	listener, err := net.Listen("unix", s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %v", err)
+	}
+	defer os.Remove(s.Path)
+	if err := os.Chmod(s.Path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict control socket: %v", err)
+	}
+
+	server := &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Debug("control socket listening", "path", s.Path)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// controlGet requests endpoint from the tracker listening on socket
+func controlGet(socket, endpoint string) ([]byte, error) {
+	return controlRequest(socket, "GET", endpoint, nil)
+}
+
+// controlPost sends a JSON body to endpoint of the tracker listening on socket
+func controlPost(socket, endpoint string, body []byte) ([]byte, error) {
+	return controlRequest(socket, "POST", endpoint, body)
+}
+
+// errTrackerNotRunning is returned when nothing listens on the control socket
+var errTrackerNotRunning = errors.New("tracker not running")
+
+func controlRequest(socket, method, endpoint string, body []byte) ([]byte, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(method, "http://tracker"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: nothing listening on %s: %v", errTrackerNotRunning, socket, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tracker returned status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
+
+// runPreview implements the preview command: it prints the requests the
+// running tracker would make at its next submission
+func runPreview(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	socket := fs.String("socket", controlSocketPath(), "Control socket of the running tracker")
+	fs.Parse(args)
+
+	body, err := controlGet(*socket, "/preview")
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(body)
+	return nil
+}
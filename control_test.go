@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestControlSocketDirectory(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", tmp)
+
+	path := controlSocketPath()
+	dir := filepath.Dir(path)
+	if filepath.Dir(dir) != tmp || !strings.HasPrefix(filepath.Base(dir), "rescuetime-linux-") {
+		t.Fatalf("controlSocketPath() = %s, want a per-user directory in %s", path, tmp)
+	}
+	if err := privateSocketDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("socket directory = %v, %v", info.Mode(), err)
+	}
+	if err := privateSocketDir(dir); err != nil {
+		t.Errorf("existing private directory: %v", err)
+	}
+
+	shared := filepath.Join(tmp, "shared")
+	if err := os.Mkdir(shared, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 01777); err != nil {
+		t.Fatal(err)
+	}
+	err := NewControlServer(filepath.Join(shared, controlSocketName)).Serve(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("Serve in a shared directory: %v", err)
+	}
+
+	link := filepath.Join(tmp, "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := privateSocketDir(link); err == nil {
+		t.Error("symlinked directory accepted")
+	}
+}
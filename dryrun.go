@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dryRunTransport prints every request instead of sending it, with keys
+// masked, and answers as if the API accepted it. Swapped into a
+// RescueTimeClient it lets the whole submission pipeline run offline.
+type dryRunTransport struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		req.Body.Close()
+	}
+
+	t.mu.Lock()
+	writeMaskedRequest(t.out, req, body)
+	t.mu.Unlock()
+
+	status, response := dryRunResponse(req, body)
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+// dryRunResponse is what the API would answer on success
+func dryRunResponse(req *http.Request, body []byte) (int, []byte) {
+	if !strings.HasSuffix(req.URL.Path, "/user_client_events") {
+		return http.StatusOK, []byte(`{"success":true}`)
+	}
+
+	var bulk UserClientEventsPayload
+	if json.Unmarshal(body, &bulk) == nil && bulk.UserClientEvents != nil {
+		results := make([]map[string]int, len(bulk.UserClientEvents))
+		for i := range results {
+			results[i] = map[string]int{"status": http.StatusCreated}
+		}
+		response, _ := json.Marshal(map[string]any{"results": results})
+		return http.StatusOK, response
+	}
+	return http.StatusCreated, []byte(`{}`)
+}
+
+// writeMaskedRequest prints a request line, sorted headers and an indented
+// JSON body, masking keys in the URL and credentials in headers
+func writeMaskedRequest(w io.Writer, req *http.Request, body []byte) {
+	fmt.Fprintf(w, "%s %s\n", req.Method, redactString(req.URL.String()))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(req.Header[name], ", ")
+		if sensitiveKeys[strings.ToLower(name)] && !strings.HasPrefix(value, "Bearer ") {
+			value = redactedValue
+		}
+		fmt.Fprintf(w, "%s: %s\n", name, redactString(value))
+	}
+
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, body, "", "  ") == nil {
+		body = pretty.Bytes()
+	}
+	fmt.Fprintf(w, "\n%s\n\n", redactString(string(body)))
+}
+
+// dryRunClient returns a copy of c that prints requests to out instead of
+// sending them. Negotiated auth state is copied in memory so the dry run
+// follows the same path as a real submission without changing anything.
+func (c *RescueTimeClient) dryRunClient(out io.Writer) *RescueTimeClient {
+	dry := *c
+	dry.HTTP = &http.Client{Transport: &dryRunTransport{out: out}}
+	dry.Governor = NewGovernor(realClock{})
+	dry.Governor.Rate = 1000 // nothing is sent, don't pace
+	dry.Governor.Burst = 1000
+	dry.Auth = c.Auth.snapshot()
+	dry.Concurrency = 1 // keep the output in order
+	return &dry
+}
+
+// previewSubmission prints the requests that submitting summaries would
+// make, after reconciliation against the ledger, without sending anything
+func previewSubmission(ctx context.Context, client *RescueTimeClient, ledger *Ledger, summaries []ActivitySummary, out io.Writer) {
+	var pending []ActivitySummary
+	for _, summary := range summaries {
+		if !shouldSubmit(summary) {
+			continue
+		}
+		reconciled, ok := ledger.Reconcile(summary)
+		if !ok {
+			fmt.Fprintf(out, "# skipped, already submitted: %s %s (%v)\n", summary.AppClass, summary.FirstSeen.Format("15:04:05"), summary.TotalDuration)
+			continue
+		}
+		pending = append(pending, reconciled)
+	}
+
+	if len(pending) == 0 {
+		fmt.Fprintln(out, "# nothing to submit")
+		return
+	}
+	fmt.Fprintf(out, "# %d activities\n\n", len(pending))
+
+	var buf bytes.Buffer
+	client.dryRunClient(&buf).SubmitSummaries(ctx, pending)
+	out.Write(buf.Bytes())
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRunPrintsMaskedRequests(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{})
+	client := testClient("secret-api-key", "secret-account", "secret-data")
+	client.Auth.Learn(AuthBearer)
+	client.Auth.LearnBulk(true)
+
+	var out strings.Builder
+	results := client.dryRunClient(&out).SubmitSummaries(context.Background(), batchSummaries(3))
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("summary %d failed: %v", i, result.Err)
+		}
+	}
+
+	if n := len(mock.Requests()); n != 0 {
+		t.Errorf("dry run sent %d requests", n)
+	}
+	printed := out.String()
+	if strings.Contains(printed, "secret") {
+		t.Errorf("dry run output leaks keys:\n%s", printed)
+	}
+	for _, want := range []string{
+		"POST " + endpoints.UserClientEvents() + "?key=REDACTED",
+		"Authorization: Bearer REDACTED",
+		`"user_client_events": [`,
+		`"application": "app-2"`,
+	} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, printed)
+		}
+	}
+	if n := strings.Count(printed, "POST "); n != 1 {
+		t.Errorf("printed %d requests, want one batch", n)
+	}
+
+	// The real client's negotiated state is untouched
+	if client.Auth.Scheme() != AuthBearer {
+		t.Errorf("dry run changed the negotiated scheme")
+	}
+}
+
+func TestPreviewOverControlSocket(t *testing.T) {
+	startMockServer(t, MockServerConfig{})
+	outbox, err := OpenOutbox("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.Add(testStart, ActivitySummary{AppClass: "queued-app", TotalDuration: 3 * time.Minute, FirstSeen: testStart})
+	submitter := NewSubmitter(testClient("secret-api-key", "", ""), outbox, nil, NewManualClock(testStart))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	control := NewControlServer(filepath.Join(t.TempDir(), "run", "control.sock"))
+	control.HandleFunc("GET /preview", func(w http.ResponseWriter, r *http.Request) {
+		submitter.Preview(r.Context(), map[string]ActivitySummary{
+			"firefox": {AppClass: "firefox", TotalDuration: 5 * time.Minute, FirstSeen: testStart.Add(3 * time.Minute)},
+			"foot":    {AppClass: "foot", TotalDuration: 10 * time.Second, FirstSeen: testStart.Add(8 * time.Minute)},
+		}, w)
+	})
+	served := make(chan error, 1)
+	go func() { served <- control.Serve(ctx) }()
+
+	var body []byte
+	for i := 0; i < 100; i++ {
+		if body, err = controlGet(control.Path, "/preview"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preview := string(body)
+	for _, want := range []string{"# 1 queued", "# 2 activities", `"activity_name": "queued-app"`, `"activity_name": "firefox"`, "key=REDACTED"} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("preview missing %q:\n%s", want, preview)
+		}
+	}
+	if strings.Contains(preview, "foot") || strings.Contains(preview, "secret") {
+		t.Errorf("preview includes a short activity or a key:\n%s", preview)
+	}
+	if outbox.Len() != 1 {
+		t.Errorf("preview changed the outbox")
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("control server: %v", err)
+	}
+}
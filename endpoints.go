@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Default base URLs of the RescueTime services
+const (
+	defaultAPIBaseURL = "https://api.rescuetime.com" // native desktop client API
+	defaultWebBaseURL = "https://www.rescuetime.com" // public API (anapi)
+)
+
+// Endpoints holds the base URLs every RescueTime request is built from, so the
+// tracker can be pointed at the bundled mock server instead of production
+type Endpoints struct {
+	API string // native client API: activate, user_client_events, config...
+	Web string // public API: offline_time_post, data, daily_summary_feed...
+}
+
+// endpoints is the active configuration, set up by main from flags and environment
+var endpoints = Endpoints{API: defaultAPIBaseURL, Web: defaultWebBaseURL}
+
+// resolveEndpoints picks base URLs from flags, then RESCUE_TIME_API_URL /
+// RESCUE_TIME_WEB_URL, then the production defaults
+func resolveEndpoints(apiFlag, webFlag string) Endpoints {
+	pick := func(flagValue, envName, fallback string) string {
+		if flagValue != "" {
+			return strings.TrimRight(flagValue, "/")
+		}
+		if env := os.Getenv(envName); env != "" {
+			return strings.TrimRight(env, "/")
+		}
+		return fallback
+	}
+	return Endpoints{
+		API: pick(apiFlag, "RESCUE_TIME_API_URL", defaultAPIBaseURL),
+		Web: pick(webFlag, "RESCUE_TIME_WEB_URL", defaultWebBaseURL),
+	}
+}
+
+// Activate is the desktop client activation endpoint
+func (e Endpoints) Activate() string {
+	return e.API + "/activate"
+}
+
+// Config is the desktop client endpoint for server-driven settings
+func (e Endpoints) Config() string {
+	return e.API + "/config"
+}
+
+// UserClientEvents is the native event submission endpoint
+func (e Endpoints) UserClientEvents() string {
+	return e.API + "/api/resource/user_client_events"
+}
+
+// BlockList is the desktop client endpoint listing sites and applications
+// blocked during FocusTime
+func (e Endpoints) BlockList() string {
+	return e.API + "/api/block_list"
+}
+
+// OfflineTimePost is the legacy Offline Time POST API endpoint
+func (e Endpoints) OfflineTimePost() string {
+	return e.Web + "/anapi/offline_time_post"
+}
+
+// AnalyticData is the Analytic Data API endpoint
+func (e Endpoints) AnalyticData() string {
+	return e.Web + "/anapi/data"
+}
+
+// DailySummaryFeed is the Daily Summary Feed API endpoint
+func (e Endpoints) DailySummaryFeed() string {
+	return e.Web + "/anapi/daily_summary_feed"
+}
+
+// AlertsFeed is the Alerts Feed API endpoint
+func (e Endpoints) AlertsFeed() string {
+	return e.Web + "/anapi/alerts_feed"
+}
+
+// HighlightsFeed is the Daily Highlights Feed API endpoint
+func (e Endpoints) HighlightsFeed() string {
+	return e.Web + "/anapi/highlights_feed"
+}
+
+// HighlightsPost is the Daily Highlights POST API endpoint
+func (e Endpoints) HighlightsPost() string {
+	return e.Web + "/anapi/highlights_post"
+}
+
+// StartFocusTime is the FocusTime Trigger API endpoint that starts a session
+func (e Endpoints) StartFocusTime() string {
+	return e.Web + "/anapi/start_focustime"
+}
+
+// EndFocusTime is the FocusTime Trigger API endpoint that ends a session
+func (e Endpoints) EndFocusTime() string {
+	return e.Web + "/anapi/end_focustime"
+}
+
+// FocusTimeFeed is the FocusTime Feed API endpoint for started or ended sessions
+func (e Endpoints) FocusTimeFeed(started bool) string {
+	if started {
+		return e.Web + "/anapi/focustime_started_feed"
+	}
+	return e.Web + "/anapi/focustime_ended_feed"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exportColumns is the header of CSV exports
+var exportColumns = []string{
+	"date", "first_seen", "last_seen", "application", "details", "project", "repo", "branch", "ticket", "directory",
+	"workspace", "monitor", "seconds", "focus_seconds",
+}
+
+// writeExportCSV writes summaries as CSV, one row per summary
+func writeExportCSV(out io.Writer, summaries []ActivitySummary) error {
+	w := csv.NewWriter(out)
+	w.Write(exportColumns)
+	for _, s := range summaries {
+		monitor := ""
+		if s.Workspace != "" {
+			monitor = strconv.Itoa(s.Monitor) // without placement, 0 isn't a monitor
+		}
+		w.Write([]string{
+			s.FirstSeen.Local().Format(storeDateFormat),
+			s.FirstSeen.Format(time.RFC3339),
+			s.LastSeen.Format(time.RFC3339),
+			s.AppClass,
+			s.ActivityDetails,
+			s.Project,
+			s.Repo,
+			s.Branch,
+			s.Ticket,
+			s.Directory,
+			s.Workspace,
+			monitor,
+			strconv.Itoa(int(s.TotalDuration.Seconds())),
+			strconv.Itoa(int(s.FocusDuration.Seconds())),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeExportJSON writes summaries as JSON lines, as kept in the store
+func writeExportJSON(out io.Writer, summaries []ActivitySummary) error {
+	encoder := json.NewEncoder(out)
+	for _, summary := range summaries {
+		if err := encoder.Encode(summary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runExport implements the export command: the local activity store with
+// the project, repository, branch, ticket and workspace of each activity
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	today := time.Now().Format(storeDateFormat)
+	fromFlag := fs.String("from", today, "First day to export (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Last day to export (YYYY-MM-DD, default -from)")
+	format := fs.String("format", "csv", "Output format: csv or json (JSON lines)")
+	output := fs.String("output", "", "File to write (default stdout)")
+	fs.Parse(args)
+
+	write := map[string]func(io.Writer, []ActivitySummary) error{"csv": writeExportCSV, "json": writeExportJSON}[*format]
+	if write == nil {
+		return fmt.Errorf("invalid -format %q, use csv or json", *format)
+	}
+	from, to, err := parseDateRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+	summaries, err := storedSummaries(from, to)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return write(os.Stdout, summaries)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create export: %v", err)
+	}
+	if err := write(f, summaries); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write export: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,70 @@
+// Streams the active tab of the focused browser window to the native host
+// io.github.robwilde.rescuetime_linux, which forwards it to the tracker.
+// Messages are {"type": "tab", "url", "title", "incognito"} on every tab or
+// window focus change and {"type": "clear"} when the browser loses focus.
+
+const HOST = "io.github.robwilde.rescuetime_linux";
+
+let port = null;
+let last = "";
+let lastMessage = null;
+let retry = null;
+
+function connect() {
+  port = chrome.runtime.connectNative(HOST);
+  port.onMessage.addListener((reply) => {
+    if (reply.ok) {
+      return;
+    }
+    // The tracker didn't get it, e.g. while it restarts: send it again
+    last = "";
+    if (!retry) {
+      retry = setTimeout(() => {
+        retry = null;
+        if (lastMessage) {
+          send(lastMessage);
+        }
+      }, 5000);
+    }
+  });
+  port.onDisconnect.addListener(() => {
+    port = null;
+    last = "";
+    setTimeout(connect, 5000);
+  });
+}
+
+function send(message) {
+  const encoded = JSON.stringify(message);
+  if (!port || encoded === last) {
+    return;
+  }
+  last = encoded;
+  lastMessage = message;
+  port.postMessage(message);
+}
+
+async function report() {
+  const [tab] = await chrome.tabs.query({ active: true, lastFocusedWindow: true });
+  if (!tab) {
+    return;
+  }
+  send({ type: "tab", url: tab.url || "", title: tab.title || "", incognito: tab.incognito });
+}
+
+chrome.tabs.onActivated.addListener(report);
+chrome.tabs.onUpdated.addListener((tabId, change, tab) => {
+  if (tab.active && (change.url || change.title)) {
+    report();
+  }
+});
+chrome.windows.onFocusChanged.addListener((windowId) => {
+  if (windowId === chrome.windows.WINDOW_ID_NONE) {
+    send({ type: "clear" });
+  } else {
+    report();
+  }
+});
+
+connect();
+report();
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// focusUntilEndOfDay is the trigger API duration for a session that lasts
+// until midnight
+const focusUntilEndOfDay = -1
+
+// FocusEvent is an entry of the FocusTime feeds, newest first
+type FocusEvent struct {
+	ID        float64 `json:"id"`                 // UNIX timestamp, unique per event
+	Duration  int     `json:"duration,omitempty"` // minutes, or -1 until the end of the day (started feed only)
+	CreatedAt string  `json:"created_at"`         // in the account's time zone
+}
+
+// Time parses CreatedAt, which is assumed to be local time when it has no offset
+func (e FocusEvent) Time() (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, e.CreatedAt); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{analyticDateFormat, "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, e.CreatedAt, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid focus event time %q", e.CreatedAt)
+}
+
+// focusMinutes converts a session length to the trigger API duration
+func focusMinutes(d time.Duration) (int, error) {
+	if d <= 0 {
+		return focusUntilEndOfDay, nil
+	}
+	minutes := int(d / time.Minute)
+	if d%time.Minute != 0 || minutes%5 != 0 {
+		return 0, fmt.Errorf("focus duration must be a multiple of 5 minutes, got %v", d)
+	}
+	return minutes, nil
+}
+
+// focusEnd returns when a session started at start with the trigger API
+// duration minutes ends
+func focusEnd(start time.Time, minutes int) time.Time {
+	if minutes == focusUntilEndOfDay {
+		local := start.Local()
+		return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, time.Local)
+	}
+	return start.Add(time.Duration(minutes) * time.Minute)
+}
+
+// StartFocusTime asks RescueTime to start FocusTime on the account's devices
+// for minutes (a multiple of 5, or focusUntilEndOfDay)
+func (c *RescueTimeClient) StartFocusTime(ctx context.Context, minutes int) error {
+	return c.triggerFocusTime(ctx, c.Endpoints.StartFocusTime(), url.Values{
+		"key":      {c.APIKey},
+		"duration": {strconv.Itoa(minutes)},
+	})
+}
+
+// EndFocusTime asks RescueTime to end the running FocusTime session
+func (c *RescueTimeClient) EndFocusTime(ctx context.Context) error {
+	return c.triggerFocusTime(ctx, c.Endpoints.EndFocusTime(), url.Values{"key": {c.APIKey}})
+}
+
+func (c *RescueTimeClient) triggerFocusTime(ctx context.Context, endpoint string, params url.Values) error {
+	_, err := c.sendWithRetry(ctx, "focustime", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		return req, nil
+	})
+	return err
+}
+
+// FocusTimeFeed returns the recently started or ended FocusTime sessions
+func (c *RescueTimeClient) FocusTimeFeed(ctx context.Context, started bool) ([]FocusEvent, error) {
+	params := url.Values{"key": {c.APIKey}}
+	body, err := c.sendWithRetry(ctx, "focustime_feed", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.FocusTimeFeed(started)+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []FocusEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse focus feed: %v", err)
+	}
+	return events, nil
+}
+
+// FocusState is whether a focus session is running
+type FocusState struct {
+	Active bool      `json:"active"`
+	Until  time.Time `json:"until,omitempty"`
+	Source string    `json:"source,omitempty"` // "local" for the focus command, "feed" for sessions started elsewhere
+}
+
+// focusFromFeeds derives the focus state from the newest started and ended
+// events: a session is running if it started after the last one ended and
+// its duration hasn't run out
+func focusFromFeeds(started, ended []FocusEvent, now time.Time) FocusState {
+	latest := func(events []FocusEvent) (FocusEvent, time.Time, bool) {
+		var newest FocusEvent
+		var newestAt time.Time
+		for _, event := range events {
+			at, err := event.Time()
+			if err != nil {
+				slog.Warn("ignoring focus event", "error", err)
+				continue
+			}
+			if newestAt.IsZero() || at.After(newestAt) {
+				newest, newestAt = event, at
+			}
+		}
+		return newest, newestAt, !newestAt.IsZero()
+	}
+
+	start, startAt, ok := latest(started)
+	if !ok {
+		return FocusState{}
+	}
+	if _, endAt, ok := latest(ended); ok && !endAt.Before(startAt) {
+		return FocusState{}
+	}
+	until := focusEnd(startAt, start.Duration)
+	if !now.Before(until) {
+		return FocusState{}
+	}
+	return FocusState{Active: true, Until: until, Source: "feed"}
+}
+
+// FocusMonitor keeps the tracker's focus flag in step with focus sessions
+// started by the focus command or, through the FocusTime feeds, from the web
+// or phone. Sessions end on their own once their duration has run out.
+type FocusMonitor struct {
+	Tracker      *ActivityTracker
+	Clock        Clock
+	Client       *RescueTimeClient // polls the feeds; nil only follows the focus command
+	PollInterval time.Duration     // how often the feeds are polled and expiry is checked
+
+	mu       sync.Mutex
+	state    FocusState
+	lastFeed string // newest feed event IDs seen, so unchanged feeds don't override the focus command
+}
+
+// Set changes the focus state and marks the tracker accordingly
+func (f *FocusMonitor) Set(state FocusState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setUnsafe(state)
+}
+
+func (f *FocusMonitor) setUnsafe(state FocusState) {
+	if state == f.state {
+		return
+	}
+	f.state = state
+	f.Tracker.SetFocus(state.Active)
+
+	if state.Active {
+		slog.Info("focus session started", "until", state.Until, "source", state.Source)
+		notifyStatus("Focus until %s", state.Until.Format("15:04"))
+	} else {
+		slog.Info("focus session ended")
+		notifyStatus("Focus session ended")
+	}
+}
+
+// State returns the current focus state
+func (f *FocusMonitor) State() FocusState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// Run polls the feeds and expires sessions until ctx is cancelled
+func (f *FocusMonitor) Run(ctx context.Context) {
+	f.poll(ctx)
+
+	ticker := f.Clock.NewTicker(f.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			f.poll(ctx)
+		}
+	}
+}
+
+// poll ends an expired session and applies changes in the feeds
+func (f *FocusMonitor) poll(ctx context.Context) {
+	now := f.Clock.Now()
+
+	if f.Client != nil {
+		started, err := f.Client.FocusTimeFeed(ctx, true)
+		var ended []FocusEvent
+		if err == nil {
+			ended, err = f.Client.FocusTimeFeed(ctx, false)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("failed to poll focus feeds", "error", err)
+			}
+		} else {
+			f.applyFeeds(started, ended, now)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Active && !now.Before(f.state.Until) {
+		f.setUnsafe(FocusState{})
+	}
+}
+
+// applyFeeds takes the state from the feeds if they changed since the last poll
+func (f *FocusMonitor) applyFeeds(started, ended []FocusEvent, now time.Time) {
+	newest := func(events []FocusEvent) float64 {
+		var id float64
+		for _, event := range events {
+			id = max(id, event.ID)
+		}
+		return id
+	}
+	signature := fmt.Sprintf("%.0f/%.0f", newest(started), newest(ended))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if signature == f.lastFeed {
+		return
+	}
+	f.lastFeed = signature
+
+	state := focusFromFeeds(started, ended, now)
+	if state.Active == f.state.Active && state.Active {
+		return // already following this session, keep its source
+	}
+	f.setUnsafe(state)
+}
+
+// handleFocus serves the focus state of the running tracker (GET) and lets
+// the focus command change it (POST)
+func handleFocus(focus *FocusMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var state FocusState
+			if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+				http.Error(w, fmt.Sprintf("invalid focus state: %v", err), http.StatusBadRequest)
+				return
+			}
+			focus.Set(state)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(focus.State())
+	}
+}
+
+// runFocus implements the focus command: focus start, stop or status
+func runFocus(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: focus start [-duration 50m] | focus stop | focus status")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("focus "+action, flag.ExitOnError)
+	duration := fs.Duration("duration", 0, "Length of the focus session, a multiple of 5m (default until the end of the day)")
+	socket := fs.String("socket", controlSocketPath(), "Control socket of the running tracker")
+	fs.Parse(args[1:])
+
+	if action == "status" {
+		body, err := controlGet(*socket, "/focus")
+		if err != nil {
+			return err
+		}
+		var state FocusState
+		if err := json.Unmarshal(body, &state); err != nil {
+			return fmt.Errorf("invalid focus state: %v", err)
+		}
+		if !state.Active {
+			fmt.Println("Not in focus")
+		} else {
+			fmt.Printf("In focus until %s (%s)\n", state.Until.Format("15:04"), state.Source)
+		}
+		return nil
+	}
+
+	var state FocusState
+	switch action {
+	case "start":
+		minutes, err := focusMinutes(*duration)
+		if err != nil {
+			return err
+		}
+		state = FocusState{Active: true, Until: focusEnd(time.Now(), minutes), Source: "local"}
+
+		client, err := newPublicAPIClient()
+		if err != nil {
+			return err
+		}
+		if err := client.StartFocusTime(context.Background(), minutes); err != nil {
+			return fmt.Errorf("failed to start FocusTime: %w", err)
+		}
+	case "stop":
+		client, err := newPublicAPIClient()
+		if err != nil {
+			return err
+		}
+		if err := client.EndFocusTime(context.Background()); err != nil {
+			return fmt.Errorf("failed to end FocusTime: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown focus action %q, use start, stop or status", action)
+	}
+
+	body, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode focus state: %v", err)
+	}
+	if _, err := controlPost(*socket, "/focus", body); err != nil {
+		if !errors.Is(err, errTrackerNotRunning) {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Tracker not running, only RescueTime was updated")
+	}
+
+	if state.Active {
+		fmt.Printf("Focus until %s\n", state.Until.Format("15:04"))
+	} else {
+		fmt.Println("Focus ended")
+	}
+	return nil
+}
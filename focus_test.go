@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFocusSplitsSessions(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+
+	tracker.StartSession("kitty", "vim")
+	clock.Set(testStart.Add(10 * time.Minute))
+	tracker.SetFocus(true)
+	clock.Set(testStart.Add(35 * time.Minute))
+	tracker.SetFocus(false)
+	clock.Set(testStart.Add(40 * time.Minute))
+	tracker.EndCurrentSession()
+
+	sessions := tracker.Sessions()
+	if len(sessions) != 3 || sessions[0].Focus || !sessions[1].Focus || sessions[2].Focus {
+		t.Fatalf("sessions = %+v, want focus only in the middle one", sessions)
+	}
+	summary := tracker.GetActivitySummaries()["kitty"]
+	if summary.TotalDuration != 40*time.Minute || summary.FocusDuration != 25*time.Minute {
+		t.Errorf("summary = %v total, %v focus, want 40m and 25m", summary.TotalDuration, summary.FocusDuration)
+	}
+}
+
+func TestFocusFromFeeds(t *testing.T) {
+	now := time.Date(2026, 10, 1, 10, 0, 0, 0, time.Local)
+	event := func(ago time.Duration, duration int) FocusEvent {
+		at := now.Add(-ago)
+		return FocusEvent{ID: float64(at.Unix()), Duration: duration, CreatedAt: at.Format(analyticDateFormat)}
+	}
+
+	tests := []struct {
+		name    string
+		started []FocusEvent
+		ended   []FocusEvent
+		want    FocusState
+	}{
+		{"no sessions", nil, nil, FocusState{}},
+		{"running", []FocusEvent{event(10*time.Minute, 30)}, nil, FocusState{Active: true, Until: now.Add(20 * time.Minute), Source: "feed"}},
+		{"ended early", []FocusEvent{event(10*time.Minute, 30)}, []FocusEvent{event(5*time.Minute, 0)}, FocusState{}},
+		{"ended before restart", []FocusEvent{event(5*time.Minute, 30), event(time.Hour, 30)}, []FocusEvent{event(30*time.Minute, 0)}, FocusState{Active: true, Until: now.Add(25 * time.Minute), Source: "feed"}},
+		{"expired", []FocusEvent{event(time.Hour, 30)}, nil, FocusState{}},
+		{"until end of day", []FocusEvent{event(2*time.Hour, focusUntilEndOfDay)}, nil, FocusState{Active: true, Until: time.Date(2026, 10, 2, 0, 0, 0, 0, time.Local), Source: "feed"}},
+	}
+	for _, tt := range tests {
+		if got := focusFromFeeds(tt.started, tt.ended, now); !got.Until.Equal(tt.want.Until) || got.Active != tt.want.Active || got.Source != tt.want.Source {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFocusMonitorFollowsFeeds(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key"})
+	clock := NewManualClock(time.Now())
+	tracker := NewActivityTrackerWithClock(clock)
+	tracker.StartSession("kitty", "vim")
+	focus := &FocusMonitor{Tracker: tracker, Clock: clock, Client: testClient("test-key", "", ""), PollInterval: time.Minute}
+	ctx := context.Background()
+
+	// Started from the phone
+	mock.AddFocusEvent(true, clock.Now().Add(-5*time.Minute), 30)
+	focus.poll(ctx)
+	if state := focus.State(); !state.Active || state.Source != "feed" || !tracker.Focus() {
+		t.Fatalf("after the phone started focus: %+v, tracker focus %v", state, tracker.Focus())
+	}
+
+	// A local session isn't overridden while the feeds are unchanged
+	focus.Set(FocusState{})
+	focus.poll(ctx)
+	if focus.State().Active {
+		t.Error("unchanged feed restarted a session that was stopped locally")
+	}
+
+	// Started with the trigger API, then it runs out
+	if err := focus.Client.StartFocusTime(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	focus.poll(ctx)
+	if !focus.State().Active {
+		t.Fatal("session started through the trigger API not picked up")
+	}
+	clock.Set(clock.Now().Add(11 * time.Minute))
+	focus.poll(ctx)
+	if focus.State().Active || tracker.Focus() {
+		t.Error("session still active after its duration")
+	}
+}
+
+func TestFocusMinutes(t *testing.T) {
+	if m, err := focusMinutes(50 * time.Minute); err != nil || m != 50 {
+		t.Errorf("50m = %d, %v", m, err)
+	}
+	if m, err := focusMinutes(0); err != nil || m != focusUntilEndOfDay {
+		t.Errorf("0 = %d, %v, want until the end of the day", m, err)
+	}
+	if _, err := focusMinutes(12 * time.Minute); err == nil {
+		t.Error("12m accepted, want multiples of 5 minutes only")
+	}
+}
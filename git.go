@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitWorkTree is a working tree and the git directory holding its HEAD
+type gitWorkTree struct {
+	Root   string
+	GitDir string
+}
+
+// findGitWorkTree returns the repository of the nearest directory at or above
+// dir containing .git: a directory or, for worktrees and submodules, a file
+// pointing at the git directory
+func findGitWorkTree(dir string) (gitWorkTree, bool) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return gitWorkTree{Root: dir, GitDir: dotGit}, true
+			}
+			if raw, err := os.ReadFile(dotGit); err == nil {
+				if gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(raw)), "gitdir: "); ok {
+					if !filepath.IsAbs(gitDir) {
+						gitDir = filepath.Join(dir, gitDir)
+					}
+					return gitWorkTree{Root: dir, GitDir: filepath.Clean(gitDir)}, true
+				}
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return gitWorkTree{}, false
+		}
+		dir = parent
+	}
+}
+
+// Branch reads the checked out branch from HEAD, "" when HEAD is detached
+// or can't be read
+func (r gitWorkTree) Branch() string {
+	raw, err := os.ReadFile(filepath.Join(r.GitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	branch, _ := strings.CutPrefix(strings.TrimSpace(string(raw)), "ref: refs/heads/")
+	if branch == strings.TrimSpace(string(raw)) {
+		return "" // a commit hash, or a ref outside refs/heads
+	}
+	return branch
+}
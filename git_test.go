@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeCheckout creates a repository directory in dir/name with HEAD on branch
+func fakeCheckout(t *testing.T, dir, name, branch string) string {
+	t.Helper()
+	root := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	checkout(t, filepath.Join(root, ".git"), branch)
+	return root
+}
+
+// checkout points HEAD in gitDir at branch
+func checkout(t *testing.T, gitDir, branch string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/"+branch+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindGitWorkTree(t *testing.T) {
+	dir := t.TempDir()
+	root := fakeCheckout(t, dir, "widget", "feature/ABC-1")
+	sub := filepath.Join(root, "cmd", "server")
+	os.MkdirAll(sub, 0755)
+
+	repo, ok := findGitWorkTree(sub)
+	if !ok || repo.Root != root || repo.Branch() != "feature/ABC-1" {
+		t.Errorf("findGitWorkTree = %+v, %v, branch %q", repo, ok, repo.Branch())
+	}
+
+	// A worktree's .git is a file pointing at its git directory
+	gitDir := filepath.Join(root, ".git", "worktrees", "hotfix")
+	os.MkdirAll(gitDir, 0755)
+	checkout(t, gitDir, "hotfix/OPS-7")
+	worktree := filepath.Join(dir, "widget-hotfix")
+	os.MkdirAll(worktree, 0755)
+	os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644)
+	if repo, ok := findGitWorkTree(worktree); !ok || repo.GitDir != gitDir || repo.Branch() != "hotfix/OPS-7" {
+		t.Errorf("worktree = %+v, %v", repo, ok)
+	}
+
+	// Detached HEAD
+	os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"), 0644)
+	if branch := (gitWorkTree{GitDir: filepath.Join(root, ".git")}).Branch(); branch != "" {
+		t.Errorf("detached branch = %q", branch)
+	}
+	if _, ok := findGitWorkTree(t.TempDir()); ok {
+		t.Error("found a repository outside any")
+	}
+}
+
+func TestProjectResolverContext(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	widget := fakeCheckout(t, filepath.Join(home, "src"), "widget", "feature/abc-123-login")
+	fakeCheckout(t, filepath.Join(home, "src"), "billing", "main")
+
+	proc := newFakeProc(t)
+	proc.add(500, "code", 1, 500, 0, -1, 1000, widget)
+	resolver := &ProjectResolver{ProcRoot: proc.root}
+
+	tests := []struct {
+		window  *HyprlandWindow
+		context SessionContext
+		want    SessionContext
+	}{
+		{
+			&HyprlandWindow{Class: "kitty", Title: "make"}, SessionContext{Process: "make", Directory: widget},
+			SessionContext{Process: "make", Directory: widget, Project: "widget", Repo: "widget", Branch: "feature/abc-123-login", Ticket: "ABC-123"},
+		},
+		{
+			&HyprlandWindow{Class: "code", Title: "main.go - widget - Visual Studio Code", Pid: 500}, SessionContext{},
+			SessionContext{Project: "widget", Repo: "widget", Branch: "feature/abc-123-login", Ticket: "ABC-123"},
+		},
+		{
+			&HyprlandWindow{Class: "jetbrains-idea", Title: "billing [~/src/billing] – Main.kt"}, SessionContext{},
+			SessionContext{Project: "billing", Repo: "billing", Branch: "main"},
+		},
+		{
+			// The editor's working directory is another repository than the one open
+			&HyprlandWindow{Class: "code", Title: "api.go - billing - Visual Studio Code", Pid: 500}, SessionContext{},
+			SessionContext{Project: "billing"},
+		},
+	}
+	for _, tt := range tests {
+		if got := resolver.Context(tt.window, tt.context); got != tt.want {
+			t.Errorf("Context(%s, %q) = %+v, want %+v", tt.window.Class, tt.window.Title, got, tt.want)
+		}
+	}
+
+	// Switching branches is picked up on the next poll
+	checkout(t, filepath.Join(widget, ".git"), "OPS-9")
+	got := resolver.Context(&HyprlandWindow{Class: "kitty"}, SessionContext{Directory: widget})
+	if got.Branch != "OPS-9" || got.Ticket != "OPS-9" {
+		t.Errorf("after checkout = %+v", got)
+	}
+}
+
+func TestMonitorSplitsSessionsByBranch(t *testing.T) {
+	root := fakeCheckout(t, t.TempDir(), "widget", "feature/ABC-1-login")
+	proc := newFakeProc(t)
+	proc.add(100, "kitty", 1, 100, 0, -1, 1000, "/", 200)
+	proc.add(200, "nvim", 100, 200, 34816, 200, 2000, root)
+
+	clock := NewManualClock(testStart)
+	monitor := &Monitor{Clock: clock, Out: io.Discard, Terminals: &TerminalResolver{Root: proc.root}, Projects: &ProjectResolver{}}
+	window := &HyprlandWindow{Class: "kitty", Title: "nvim", Pid: 100}
+	monitor.HandleWindow(window)
+	clock.Advance(25 * time.Minute)
+	checkout(t, filepath.Join(root, ".git"), "feature/ABC-2-signup")
+	monitor.HandleWindow(window)
+	clock.Advance(15 * time.Minute)
+
+	summaries := monitor.Tracker.GetActivitySummaries()
+	first := summaries["kitty › nvim [widget@feature/ABC-1-login]"]
+	second := summaries["kitty › nvim [widget@feature/ABC-2-signup]"]
+	if first.TotalDuration != 25*time.Minute || first.Ticket != "ABC-1" || second.TotalDuration != 15*time.Minute || second.Ticket != "ABC-2" {
+		t.Errorf("summaries = %+v", summaries)
+	}
+
+	var all []ActivitySummary
+	for _, summary := range summaries {
+		all = append(all, summary)
+	}
+	tickets := groupSummaries(all, summaryGroups["ticket"])
+	if len(tickets) != 2 || tickets[0].Name != "ABC-1" || tickets[0].Total != 25*time.Minute {
+		t.Errorf("tickets = %+v", tickets)
+	}
+}
+
+func TestWriteExportCSV(t *testing.T) {
+	summaries := []ActivitySummary{{
+		AppClass: "kitty › nvim", ActivityDetails: "main.go, edited", FirstSeen: testStart, LastSeen: testStart.Add(time.Hour),
+		TotalDuration: 50 * time.Minute, FocusDuration: 20 * time.Minute,
+		SessionContext: SessionContext{Process: "nvim", Directory: "/src/widget", Project: "widget", Repo: "widget", Branch: "feature/ABC-1", Ticket: "ABC-1"},
+	}, {
+		AppClass: "firefox", FirstSeen: testStart, LastSeen: testStart.Add(time.Minute), TotalDuration: time.Minute,
+		SessionContext: SessionContext{Workspace: "1", WorkspaceID: 1, Monitor: 0},
+	}}
+	var out bytes.Buffer
+	if err := writeExportCSV(&out, summaries); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || len(rows[1]) != len(exportColumns) {
+		t.Fatalf("rows = %q", rows)
+	}
+	row, placed := make(map[string]string), make(map[string]string)
+	for i, column := range exportColumns {
+		row[column], placed[column] = rows[1][i], rows[2][i]
+	}
+	if row["details"] != "main.go, edited" || row["branch"] != "feature/ABC-1" || row["ticket"] != "ABC-1" || row["seconds"] != "3000" || row["focus_seconds"] != "1200" {
+		t.Errorf("row = %v", row)
+	}
+	// Monitor 0 only when the session has a placement
+	if row["monitor"] != "" || placed["monitor"] != "0" || placed["workspace"] != "1" {
+		t.Errorf("monitors = %q and %q", row["monitor"], placed["monitor"])
+	}
+}
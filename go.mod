@@ -0,0 +1,3 @@
+module github.com/robwilde/rescuetime-linux
+
+go 1.24
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errSubmissionsPaused is returned instead of sending a request while the
+// circuit breaker is open or the server asked us to back off for longer than
+// we are willing to block. Callers keep the data queued and try again later.
+var errSubmissionsPaused = errors.New("submissions paused")
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"    // requests flow normally
+	circuitOpen     = "open"      // requests are refused until the probe time
+	circuitHalfOpen = "half-open" // a single probe request is in flight
+)
+
+// Governor is shared by every request to RescueTime. It spaces requests with
+// a token bucket, honours Retry-After, computes jittered backoff delays and
+// trips a circuit breaker after repeated failures, so an outage costs one
+// probe per cooldown instead of three attempts per application.
+type Governor struct {
+	Rate             float64       // sustained requests per second
+	Burst            int           // requests allowed back to back
+	BaseDelay        time.Duration // first retry delay, doubled per attempt
+	MaxDelay         time.Duration // cap on a single retry delay
+	MaxWait          time.Duration // longest Retry-After pause to block for
+	FailureThreshold int           // consecutive failures that open the circuit
+	Cooldown         time.Duration // first probe delay, doubled per failed probe
+	MaxCooldown      time.Duration // cap on the probe delay
+
+	clock Clock
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu         sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+	pauseUntil time.Time // from Retry-After
+	failures   int       // consecutive failures
+	state      string
+	trips      int       // consecutive times the circuit opened
+	probeAt    time.Time // when an open circuit allows a probe
+}
+
+// NewGovernor creates a governor with limits suited to the RescueTime API
+func NewGovernor(clock Clock) *Governor {
+	return &Governor{
+		Rate:             2,
+		Burst:            5,
+		BaseDelay:        time.Second,
+		MaxDelay:         30 * time.Second,
+		MaxWait:          time.Minute,
+		FailureThreshold: 5,
+		Cooldown:         time.Minute,
+		MaxCooldown:      15 * time.Minute,
+		clock:            clock,
+		sleep:            sleepContext,
+		tokens:           5,
+		lastRefill:       clock.Now(),
+		state:            circuitClosed,
+	}
+}
+
+// Wait blocks until a request may be sent. It returns errSubmissionsPaused
+// without blocking when the circuit is open or a Retry-After pause is longer
+// than MaxWait, and ctx.Err() if ctx is cancelled while waiting.
+func (g *Governor) Wait(ctx context.Context) error {
+	for {
+		delay, err := g.reserve()
+		if err != nil || delay <= 0 {
+			return err
+		}
+		if err := g.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise returns how long to wait
+func (g *Governor) reserve() (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+
+	switch g.state {
+	case circuitHalfOpen:
+		return 0, fmt.Errorf("%w: waiting for probe result", errSubmissionsPaused)
+	case circuitOpen:
+		if now.Before(g.probeAt) {
+			return 0, fmt.Errorf("%w: circuit open until %s", errSubmissionsPaused, g.probeAt.Format(time.TimeOnly))
+		}
+		// Let exactly one request through to see whether the API recovered
+		g.state = circuitHalfOpen
+		slog.Info("probing RescueTime API", "circuit", circuitHalfOpen)
+		return 0, nil
+	}
+
+	if pause := g.pauseUntil.Sub(now); pause > 0 {
+		if pause > g.MaxWait {
+			return 0, fmt.Errorf("%w: server asked to retry after %s", errSubmissionsPaused, g.pauseUntil.Format(time.TimeOnly))
+		}
+		return pause, nil
+	}
+
+	// Refill the bucket
+	g.tokens = math.Min(float64(g.Burst), g.tokens+now.Sub(g.lastRefill).Seconds()*g.Rate)
+	g.lastRefill = now
+
+	if g.tokens >= 1 {
+		g.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - g.tokens) / g.Rate * float64(time.Second)), nil
+}
+
+// Backoff returns the jittered delay before retry attempt n (n >= 1): a random
+// duration between half and all of BaseDelay * 2^(n-1), capped at MaxDelay
+func (g *Governor) Backoff(attempt int) time.Duration {
+	delay := g.BaseDelay * time.Duration(math.Pow(2, float64(attempt-1)))
+	if delay > g.MaxDelay || delay <= 0 {
+		delay = g.MaxDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// RetryAfter pauses all requests for d, as requested by the server
+func (g *Governor) RetryAfter(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if until := g.clock.Now().Add(d); until.After(g.pauseUntil) {
+		g.pauseUntil = until
+	}
+}
+
+// Success records a request the server handled, closing the circuit
+func (g *Governor) Success() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.state != circuitClosed {
+		slog.Info("RescueTime API recovered", "circuit", circuitClosed)
+	}
+	g.failures = 0
+	g.trips = 0
+	g.state = circuitClosed
+}
+
+// Failure records a request that failed because the API is unavailable,
+// opening the circuit after FailureThreshold consecutive failures or a failed probe
+func (g *Governor) Failure() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.failures++
+	if g.state == circuitHalfOpen || (g.state == circuitClosed && g.failures >= g.FailureThreshold) {
+		g.trips++
+		cooldown := g.Cooldown * time.Duration(math.Pow(2, float64(g.trips-1)))
+		if cooldown > g.MaxCooldown || cooldown <= 0 {
+			cooldown = g.MaxCooldown
+		}
+		g.state = circuitOpen
+		g.probeAt = g.clock.Now().Add(cooldown)
+		slog.Warn("RescueTime API unavailable, pausing submissions",
+			"circuit", circuitOpen,
+			"consecutive_failures", g.failures,
+			"probe_in", cooldown)
+	}
+}
+
+// Abandon records a request that was cancelled before it completed. A
+// cancelled probe doesn't tell us anything, so the next request probes again.
+func (g *Governor) Abandon() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.state == circuitHalfOpen {
+		g.state = circuitOpen
+		g.probeAt = g.clock.Now()
+	}
+}
+
+// State returns the circuit state
+func (g *Governor) State() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state
+}
+
+// NextAttempt returns how long until requests may flow again: the probe time
+// of an open circuit or the end of a Retry-After pause (zero if neither applies)
+func (g *Governor) NextAttempt() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	var until time.Time
+	if g.state == circuitOpen {
+		until = g.probeAt
+	}
+	if g.pauseUntil.After(until) {
+		until = g.pauseUntil
+	}
+	if d := until.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
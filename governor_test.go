@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestGovernor returns a governor on a manual clock whose sleeps advance the clock
+func newTestGovernor() (*Governor, *ManualClock, *[]time.Duration) {
+	clock := NewManualClock(testStart)
+	g := NewGovernor(clock)
+	var slept []time.Duration
+	g.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		clock.Set(clock.Now().Add(d))
+		return nil
+	}
+	return g, clock, &slept
+}
+
+func TestGovernorTokenBucket(t *testing.T) {
+	g, _, slept := newTestGovernor()
+
+	for i := 0; i < g.Burst; i++ {
+		if err := g.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*slept) != 0 {
+		t.Fatalf("burst requests waited: %v", *slept)
+	}
+
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Duration{500 * time.Millisecond}; len(*slept) != 1 || (*slept)[0] != want[0] {
+		t.Errorf("waited %v, want %v at 2 requests per second", *slept, want)
+	}
+}
+
+func TestGovernorRetryAfter(t *testing.T) {
+	g, _, slept := newTestGovernor()
+
+	g.RetryAfter(20 * time.Second)
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 20*time.Second {
+		t.Errorf("waited %v, want the 20s Retry-After pause", *slept)
+	}
+
+	// Pauses longer than MaxWait are not waited out
+	g.RetryAfter(time.Hour)
+	if err := g.Wait(context.Background()); !errors.Is(err, errSubmissionsPaused) {
+		t.Errorf("got %v, want errSubmissionsPaused", err)
+	}
+	if d := g.NextAttempt(); d != time.Hour {
+		t.Errorf("next attempt in %v, want 1h", d)
+	}
+}
+
+func TestGovernorCircuitBreaker(t *testing.T) {
+	g, clock, _ := newTestGovernor()
+
+	for i := 0; i < g.FailureThreshold-1; i++ {
+		g.Failure()
+	}
+	if g.State() != circuitClosed {
+		t.Fatalf("circuit %s before reaching the threshold", g.State())
+	}
+	g.Failure()
+	if g.State() != circuitOpen {
+		t.Fatalf("circuit %s after %d failures, want open", g.State(), g.FailureThreshold)
+	}
+	if err := g.Wait(context.Background()); !errors.Is(err, errSubmissionsPaused) {
+		t.Fatalf("open circuit allowed a request: %v", err)
+	}
+
+	// After the cooldown exactly one probe is let through
+	clock.Set(testStart.Add(g.Cooldown))
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	if err := g.Wait(context.Background()); !errors.Is(err, errSubmissionsPaused) {
+		t.Fatalf("second request allowed during probe: %v", err)
+	}
+
+	// A failed probe doubles the cooldown
+	g.Failure()
+	if d := g.NextAttempt(); d != 2*g.Cooldown {
+		t.Errorf("next probe in %v, want %v", d, 2*g.Cooldown)
+	}
+
+	clock.Set(clock.Now().Add(2 * g.Cooldown))
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	g.Success()
+	if g.State() != circuitClosed {
+		t.Errorf("circuit %s after a successful probe, want closed", g.State())
+	}
+}
+
+func TestGovernorBackoffJitter(t *testing.T) {
+	g, _, _ := newTestGovernor()
+
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: g.MaxDelay} {
+		for i := 0; i < 20; i++ {
+			if d := g.Backoff(attempt); d < max/2 || d > max {
+				t.Errorf("attempt %d: backoff %v outside [%v, %v]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 10, 2, 9, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Thu, 02 Oct 2025 09:02:00 GMT": 2 * time.Minute,
+		"Thu, 02 Oct 2025 08:00:00 GMT": 0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
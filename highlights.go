@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// highlightMaxLength is the longest description the Highlights POST API accepts
+const highlightMaxLength = 255
+
+// Sources of proposed highlights, shown as labels in the RescueTime UI
+const (
+	highlightSourceGit     = "git"
+	highlightSourceTickets = "tickets"
+)
+
+// Highlight is a Daily Highlight, to post or as listed by the feed
+type Highlight struct {
+	ID          int64  `json:"id,omitempty"`
+	Description string `json:"description"`
+	Date        string `json:"date"` // the day it is for, YYYY-MM-DD
+	Source      string `json:"source,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+}
+
+// truncateHighlight shortens a description to highlightMaxLength bytes
+// without splitting a character
+func truncateHighlight(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= highlightMaxLength {
+		return s
+	}
+	s = s[:highlightMaxLength-len("…")]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "…"
+}
+
+// PostHighlight logs a highlight through the Highlights POST API
+func (c *RescueTimeClient) PostHighlight(ctx context.Context, highlight Highlight) error {
+	params := url.Values{
+		"key":            {c.APIKey},
+		"highlight_date": {highlight.Date},
+		"description":    {truncateHighlight(highlight.Description)},
+	}
+	if highlight.Source != "" {
+		params.Set("source", highlight.Source)
+	}
+	_, err := c.sendWithRetry(ctx, "highlights_post", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoints.HighlightsPost()+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		return req, nil
+	})
+	return err
+}
+
+// HighlightsFeed returns the recently entered highlights. Highlights are a
+// premium feature; the feed is always empty on RescueTime Lite.
+func (c *RescueTimeClient) HighlightsFeed(ctx context.Context) ([]Highlight, error) {
+	params := url.Values{"key": {c.APIKey}}
+	body, err := c.sendWithRetry(ctx, "highlights_feed", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.HighlightsFeed()+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var highlights []Highlight
+	if err := json.Unmarshal(body, &highlights); err != nil {
+		return nil, fmt.Errorf("failed to parse highlights feed: %v", err)
+	}
+	return highlights, nil
+}
+
+// loadRepoList reads the repositories to look for commits in, one
+// directory per line; blank lines and # comments are ignored
+func loadRepoList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository list: %v", err)
+	}
+
+	var repos []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		repos = append(repos, expandHome(line))
+	}
+	return repos, nil
+}
+
+// focusedRepos returns the repositories whose directory name appears in an
+// application or window title of the day's activity
+func focusedRepos(repos []string, summaries []ActivitySummary) []string {
+	var focused []string
+	for _, repo := range repos {
+		name := strings.ToLower(filepath.Base(repo))
+		for _, summary := range summaries {
+			if strings.Contains(strings.ToLower(summary.ActivityDetails), name) || strings.ToLower(summary.AppClass) == name {
+				focused = append(focused, repo)
+				break
+			}
+		}
+	}
+	return focused
+}
+
+// gitCommits returns the subjects of the commits the configured git user
+// made in repo on the local calendar day, oldest first
+func gitCommits(repo string, day time.Time) ([]string, error) {
+	const gitDateFormat = "2006-01-02 15:04:05 -0700"
+	args := []string{"-C", repo, "log", "--no-merges", "--reverse", "--format=%s",
+		"--since=" + day.Format(gitDateFormat), "--until=" + day.AddDate(0, 0, 1).Format(gitDateFormat)}
+	if email, err := exec.Command("git", "-C", repo, "config", "user.email").Output(); err == nil && len(strings.TrimSpace(string(email))) > 0 {
+		args = append(args, "--author="+strings.TrimSpace(string(email)))
+	}
+
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log in %s failed: %v", repo, err)
+	}
+	var subjects []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+// ticketsSeen returns the tickets of the day's branches, in order of first
+// appearance. Window titles are left out: the issue keys in them can't be
+// told apart from names such as UTF-8.
+func ticketsSeen(summaries []ActivitySummary) []string {
+	var tickets []string
+	seen := make(map[string]bool)
+	for _, summary := range summaries {
+		if summary.Ticket != "" && !seen[summary.Ticket] {
+			seen[summary.Ticket] = true
+			tickets = append(tickets, summary.Ticket)
+		}
+	}
+	return tickets
+}
+
+// proposeHighlights suggests highlights for day from the commits made in
+// repositories that were focused and the tickets of the branches worked on,
+// leaving out ones already posted
+func proposeHighlights(summaries []ActivitySummary, repos []string, day time.Time, posted []Highlight) []Highlight {
+	date := day.Format(storeDateFormat)
+	exists := make(map[string]bool)
+	for _, highlight := range posted {
+		if highlight.Date == date {
+			exists[strings.ToLower(highlight.Description)] = true
+		}
+	}
+
+	var proposals []Highlight
+	propose := func(description, source string) {
+		description = truncateHighlight(description)
+		if !exists[strings.ToLower(description)] {
+			exists[strings.ToLower(description)] = true
+			proposals = append(proposals, Highlight{Description: description, Date: date, Source: source})
+		}
+	}
+
+	for _, repo := range focusedRepos(repos, summaries) {
+		subjects, err := gitCommits(repo, day)
+		if err != nil {
+			slog.Warn("skipping repository", "repo", repo, "error", err)
+			continue
+		}
+		for _, subject := range subjects {
+			propose(filepath.Base(repo)+": "+subject, highlightSourceGit)
+		}
+	}
+	for _, ticket := range ticketsSeen(summaries) {
+		propose("Worked on "+ticket, highlightSourceTickets)
+	}
+	return proposals
+}
+
+// confirmHighlights asks about each proposal on out and returns the accepted
+// ones. Answering e replaces the text with the next line read.
+func confirmHighlights(in io.Reader, out io.Writer, proposals []Highlight) []Highlight {
+	reader := bufio.NewReader(in)
+	readLine := func() (string, bool) {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", false
+		}
+		return strings.TrimSpace(line), true
+	}
+
+	var accepted []Highlight
+	for _, proposal := range proposals {
+		fmt.Fprintf(out, "[%s] %s\nPost this highlight? [y/N/e(dit)] ", proposal.Source, proposal.Description)
+		answer, ok := readLine()
+		if !ok {
+			fmt.Fprintln(out)
+			break
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			accepted = append(accepted, proposal)
+		case "e", "edit":
+			fmt.Fprint(out, "Highlight: ")
+			if text, ok := readLine(); ok && text != "" {
+				proposal.Description = truncateHighlight(text)
+				accepted = append(accepted, proposal)
+			}
+		}
+	}
+	return accepted
+}
+
+// setupHighlightReminder makes alerts propose highlights from the
+// repositories in reposPath and remind about them daily at the time given
+func setupHighlightReminder(alerts *AlertMonitor, at, reposPath string) error {
+	if at == "" {
+		return nil
+	}
+	offset, err := parseTimeOfDay(at)
+	if err != nil {
+		return err
+	}
+	repos, err := loadRepoList(reposPath)
+	if err != nil {
+		return err
+	}
+	alerts.HighlightsAt = offset
+	alerts.Propose = func(summaries []ActivitySummary) []Highlight {
+		return proposeHighlights(summaries, repos, alerts.Clock.Now(), nil)
+	}
+	return nil
+}
+
+// runHighlight implements the highlight command
+func runHighlight(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(`usage: highlight add "Shipped X" | highlight propose | highlight list`)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("highlight "+action, flag.ExitOnError)
+	date := fs.String("date", time.Now().Format(storeDateFormat), "Day the highlight is for (YYYY-MM-DD)")
+	source := fs.String("source", "", "Label grouping the highlight in RescueTime (add only)")
+	reposPath := fs.String("repos", configPath("repos"), "Git repositories to propose commits from, one directory per line")
+	yes := fs.Bool("yes", false, "Post every proposal without asking (propose only)")
+	fs.Parse(args[1:])
+
+	day, err := time.ParseInLocation(storeDateFormat, *date, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid -date: %v", err)
+	}
+	client, err := newPublicAPIClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch action {
+	case "add":
+		description := strings.Join(fs.Args(), " ")
+		if strings.TrimSpace(description) == "" {
+			return fmt.Errorf(`usage: highlight add [-date YYYY-MM-DD] [-source label] "Shipped X"`)
+		}
+		if err := client.PostHighlight(ctx, Highlight{Description: description, Date: *date, Source: *source}); err != nil {
+			return fmt.Errorf("failed to post highlight: %w", err)
+		}
+		fmt.Printf("Posted highlight for %s\n", *date)
+		return nil
+
+	case "list":
+		highlights, err := client.HighlightsFeed(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch highlights: %w", err)
+		}
+		for _, highlight := range highlights {
+			if highlight.Date == *date {
+				fmt.Printf("- %s", highlight.Description)
+				if highlight.Source != "" {
+					fmt.Printf(" [%s]", highlight.Source)
+				}
+				fmt.Println()
+			}
+		}
+		return nil
+
+	case "propose":
+		repos, err := loadRepoList(*reposPath)
+		if err != nil {
+			return err
+		}
+		storeDir := statePath("activity")
+		if storeDir == "" {
+			return fmt.Errorf("no state directory to read tracked activity from")
+		}
+		summaries, err := NewActivityStore(storeDir).Day(day)
+		if err != nil {
+			slog.Warn("tracked activity is incomplete", "error", err)
+		}
+		posted, err := client.HighlightsFeed(ctx)
+		if err != nil {
+			slog.Warn("failed to fetch posted highlights, duplicates are not filtered", "error", err)
+		}
+
+		proposals := proposeHighlights(summaries, repos, day, posted)
+		if len(proposals) == 0 {
+			fmt.Printf("Nothing to propose for %s\n", *date)
+			return nil
+		}
+		if !*yes {
+			proposals = confirmHighlights(os.Stdin, os.Stdout, proposals)
+		}
+		for _, highlight := range proposals {
+			if err := client.PostHighlight(ctx, highlight); err != nil {
+				return fmt.Errorf("failed to post highlight %q: %w", highlight.Description, err)
+			}
+		}
+		fmt.Printf("Posted %d highlights for %s\n", len(proposals), *date)
+		return nil
+	}
+	return fmt.Errorf("unknown highlight action %q, use add, propose or list", action)
+}
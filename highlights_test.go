@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// gitRepo creates a repository in dir/name with a configured user
+func gitRepo(t *testing.T, dir, name string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := filepath.Join(dir, name)
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "config", "user.email", "me@example.com"},
+		{"-C", repo, "config", "user.name", "Me"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	return repo
+}
+
+// gitCommit commits an empty change at the given time, as author email
+func gitCommit(t *testing.T, repo, email, subject string, at time.Time) {
+	t.Helper()
+	cmd := exec.Command("git", "-C", repo, "commit", "-q", "--allow-empty", "-m", subject)
+	date := at.Format(time.RFC3339)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Someone", "GIT_AUTHOR_EMAIL="+email, "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v: %s", err, output)
+	}
+}
+
+func TestTruncateHighlight(t *testing.T) {
+	long := strings.Repeat("é", 200)
+	got := truncateHighlight(long)
+	if len(got) > highlightMaxLength || !utf8.ValidString(got) || !strings.HasSuffix(got, "…") {
+		t.Errorf("truncateHighlight = %d bytes, valid %v", len(got), utf8.ValidString(got))
+	}
+	if got := truncateHighlight("  Shipped X "); got != "Shipped X" {
+		t.Errorf("truncateHighlight = %q", got)
+	}
+}
+
+func TestProposeHighlights(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2025, 10, 2, 0, 0, 0, 0, time.Local)
+	widget := gitRepo(t, dir, "widget")
+	gitCommit(t, widget, "me@example.com", "Fix the flaky login test", day.Add(-2*time.Hour))
+	gitCommit(t, widget, "me@example.com", "Add retry to the sync job", day.Add(10*time.Hour))
+	gitCommit(t, widget, "other@example.com", "Someone else's work", day.Add(11*time.Hour))
+	gitCommit(t, widget, "me@example.com", "Ship the export page", day.Add(15*time.Hour))
+	unfocused := gitRepo(t, dir, "gadget")
+	gitCommit(t, unfocused, "me@example.com", "Not looked at today", day.Add(12*time.Hour))
+
+	summaries := []ActivitySummary{
+		{AppClass: "kitty", ActivityDetails: "nvim ~/src/widget/sync.go"},
+		{AppClass: "code", ActivityDetails: "export.go - widget", SessionContext: SessionContext{Repo: "widget", Branch: "feature/proj-12-export", Ticket: "PROJ-12"}},
+		{AppClass: "kitty", ActivityDetails: "make", SessionContext: SessionContext{Repo: "widget", Branch: "ops-7", Ticket: "OPS-7"}},
+		{AppClass: "code", ActivityDetails: "sync.go - widget", SessionContext: SessionContext{Repo: "widget", Branch: "feature/proj-12-export", Ticket: "PROJ-12"}},
+		// Issue keys in titles aren't tickets
+		{AppClass: "firefox", ActivityDetails: "UTF-8 encoding - SHA-256 - Wikipedia"},
+		{AppClass: "firefox", ActivityDetails: "[WEB-9] Hero image - Jira"},
+	}
+	posted := []Highlight{{Description: "widget: Ship the export page", Date: "2025-10-02"}}
+
+	proposals := proposeHighlights(summaries, []string{widget, unfocused}, day, posted)
+	want := []Highlight{
+		{Description: "widget: Add retry to the sync job", Date: "2025-10-02", Source: highlightSourceGit},
+		{Description: "Worked on PROJ-12", Date: "2025-10-02", Source: highlightSourceTickets},
+		{Description: "Worked on OPS-7", Date: "2025-10-02", Source: highlightSourceTickets},
+	}
+	if len(proposals) != len(want) {
+		t.Fatalf("proposals = %+v, want %+v", proposals, want)
+	}
+	for i := range want {
+		if proposals[i] != want[i] {
+			t.Errorf("proposal %d = %+v, want %+v", i, proposals[i], want[i])
+		}
+	}
+}
+
+func TestConfirmHighlights(t *testing.T) {
+	proposals := []Highlight{{Description: "one"}, {Description: "two"}, {Description: "three"}, {Description: "four"}}
+	var out bytes.Buffer
+	accepted := confirmHighlights(strings.NewReader("y\n\ne\nthree, reworded\n"), &out, proposals)
+	if len(accepted) != 2 || accepted[0].Description != "one" || accepted[1].Description != "three, reworded" {
+		t.Errorf("accepted = %+v", accepted)
+	}
+	if got := strings.Count(out.String(), "Post this highlight?"); got != 4 {
+		t.Errorf("asked %d times, want 4 (input ends at the last one)", got)
+	}
+}
+
+func TestPostHighlightAndFeed(t *testing.T) {
+	startMockServer(t, MockServerConfig{APIKey: "key"})
+	client := testClient("key", "", "")
+	ctx := context.Background()
+
+	if err := client.PostHighlight(ctx, Highlight{Description: "Shipped X", Date: "2025-10-02", Source: "cli"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PostHighlight(ctx, Highlight{Description: strings.Repeat("x", 300), Date: "2025-10-02"}); err != nil {
+		t.Errorf("long description was not truncated: %v", err)
+	}
+	if err := client.PostHighlight(ctx, Highlight{Description: "Shipped Y", Date: "yesterday"}); err == nil {
+		t.Error("invalid date accepted")
+	}
+
+	highlights, err := client.HighlightsFeed(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(highlights) != 2 || highlights[1].Description != "Shipped X" || highlights[1].Source != "cli" || highlights[1].Date != "2025-10-02" {
+		t.Errorf("feed = %+v", highlights)
+	}
+}
+
+func TestHighlightReminderShownOnceAfterTime(t *testing.T) {
+	clock := NewManualClock(time.Date(2025, 10, 2, 17, 0, 0, 0, time.Local))
+	notifier := &recordingNotifier{}
+	alerts := &AlertMonitor{
+		Today:    func() []ActivitySummary { return []ActivitySummary{{SessionContext: SessionContext{Ticket: "OPS-7"}}} },
+		Notifier: notifier,
+		Clock:    clock,
+		sent:     make(map[string]time.Time),
+	}
+	if err := setupHighlightReminder(alerts, "17:30", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts.Check(context.Background())
+	clock.Advance(45 * time.Minute)
+	alerts.Check(context.Background())
+	clock.Advance(time.Minute)
+	alerts.Check(context.Background())
+	if len(notifier.shown) != 1 || !strings.Contains(notifier.shown[0], "1 proposed") {
+		t.Errorf("shown = %q, want one reminder after 17:30", notifier.shown)
+	}
+}
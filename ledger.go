@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ledgerRetention is how long acknowledged events are remembered
+const ledgerRetention = 30 * 24 * time.Hour
+
+// eventID derives a stable ID for an event from its application and its
+// start and end as sent to the API (RFC 3339, second resolution)
+func eventID(application, start, end string) string {
+	sum := sha256.Sum256([]byte(application + "\x00" + start + "\x00" + end))
+	return hex.EncodeToString(sum[:12])
+}
+
+// summaryEventID is the ID of the event a summary is submitted as
+func summaryEventID(summary ActivitySummary) string {
+	return summaryToUserClientEvent(summary).UserClientEvent.ClientEventID
+}
+
+// LedgerEntry is an event RescueTime acknowledged
+type LedgerEntry struct {
+	ID              string    `json:"id"`
+	Key             string    `json:"key,omitempty"` // what the summary was aggregated by, see summaryKey
+	AppClass        string    `json:"app_class"`
+	ActivityDetails string    `json:"activity_details"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	AckedAt         time.Time `json:"acked_at"`
+}
+
+// Ledger is the persistent record of acknowledged events. It is consulted
+// before every submission so a restart never sends the same time twice.
+type Ledger struct {
+	mu      sync.Mutex
+	path    string // empty keeps the ledger in memory only
+	entries []LedgerEntry
+}
+
+// OpenLedger loads the ledger at path, starting empty if it doesn't exist
+func OpenLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path}
+	if path == "" {
+		return l, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %v", err)
+	}
+	if err := json.Unmarshal(raw, &l.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse ledger %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Record stores acknowledged summaries, forgets entries past retention and
+// writes the ledger once
+func (l *Ledger) Record(now time.Time, summaries ...ActivitySummary) error {
+	if l == nil || len(summaries) == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := l.entries[:0]
+	for _, entry := range l.entries {
+		if now.Sub(entry.End) < ledgerRetention {
+			kept = append(kept, entry)
+		}
+	}
+	for _, summary := range summaries {
+		kept = append(kept, LedgerEntry{
+			ID:              summaryEventID(summary),
+			Key:             summaryKeyOf(summary),
+			AppClass:        summary.AppClass,
+			ActivityDetails: summary.ActivityDetails,
+			Start:           summary.FirstSeen,
+			End:             summary.FirstSeen.Add(summary.TotalDuration),
+			AckedAt:         now,
+		})
+	}
+	l.entries = kept
+	return l.saveUnsafe()
+}
+
+// Acknowledged reports whether the event with id was accepted before
+func (l *Ledger) Acknowledged(id string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, entry := range l.entries {
+		if entry.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Reconcile checks a summary against the ledger before it is submitted. It
+// returns false if the summary was already submitted or its time range is
+// covered by acknowledged events of the same activity: the same application,
+// project, branch and workspace. Titles are ignored because they change
+// between submissions, and with -project-details they hold the project
+// name. Partial overlaps are trimmed: the start moves past an overlapping
+// event, or the end is cut at one, so time is never counted twice.
+func (l *Ledger) Reconcile(summary ActivitySummary) (ActivitySummary, bool) {
+	if l == nil {
+		return summary, true
+	}
+	if l.Acknowledged(summaryEventID(summary)) {
+		return summary, false
+	}
+
+	key := summaryKeyOf(summary)
+	l.mu.Lock()
+	var overlapping []LedgerEntry
+	for _, entry := range l.entries {
+		same := entry.Key == key
+		if entry.Key == "" {
+			// Recorded before keys were
+			same = entry.AppClass == summary.AppClass && entry.ActivityDetails == summary.ActivityDetails
+		}
+		if same {
+			overlapping = append(overlapping, entry)
+		}
+	}
+	l.mu.Unlock()
+	sort.Slice(overlapping, func(i, j int) bool { return overlapping[i].Start.Before(overlapping[j].Start) })
+
+	start, end := summary.FirstSeen, summary.FirstSeen.Add(summary.TotalDuration)
+	for _, entry := range overlapping {
+		if !entry.Start.Before(end) || !start.Before(entry.End) {
+			continue // no overlap
+		}
+		if !entry.Start.After(start) {
+			start = entry.End // trim the head
+		} else {
+			end = entry.Start // trim the tail
+		}
+		if !start.Before(end) {
+			return summary, false
+		}
+	}
+
+	if start.Equal(summary.FirstSeen) && end.Equal(summary.FirstSeen.Add(summary.TotalDuration)) {
+		return summary, true
+	}
+	trimmed := summary
+	trimmed.FirstSeen = start
+	trimmed.TotalDuration = end.Sub(start)
+	trimmed.FocusDuration = min(trimmed.FocusDuration, trimmed.TotalDuration)
+	return trimmed, shouldSubmit(trimmed)
+}
+
+// snapshot returns an in-memory copy that never writes to disk
+func (l *Ledger) snapshot() *Ledger {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &Ledger{entries: append([]LedgerEntry(nil), l.entries...)}
+}
+
+// Len returns the number of remembered events
+func (l *Ledger) Len() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+// saveUnsafe writes the ledger to disk (caller must hold lock)
+func (l *Ledger) saveUnsafe() error {
+	if l.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger: %v", err)
+	}
+	return writeFileAtomic(l.path, raw, 0600)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ledgerSummary(app string, start, duration time.Duration) ActivitySummary {
+	return ActivitySummary{AppClass: app, ActivityDetails: app, TotalDuration: duration, FirstSeen: testStart.Add(start)}
+}
+
+func TestEventIDIsDeterministic(t *testing.T) {
+	a := summaryEventID(ledgerSummary("firefox", 0, 5*time.Minute))
+	if a == "" || a != summaryEventID(ledgerSummary("firefox", 0, 5*time.Minute)) {
+		t.Fatalf("IDs differ for the same event")
+	}
+	for _, other := range []ActivitySummary{
+		ledgerSummary("kitty", 0, 5*time.Minute),
+		ledgerSummary("firefox", time.Minute, 4*time.Minute),
+		ledgerSummary("firefox", 0, 6*time.Minute),
+	} {
+		if summaryEventID(other) == a {
+			t.Errorf("%+v has the same ID as a different event", other)
+		}
+	}
+}
+
+func withDetails(summary ActivitySummary, details string) ActivitySummary {
+	summary.ActivityDetails = details
+	return summary
+}
+
+func withProject(summary ActivitySummary, project string) ActivitySummary {
+	summary.Project = project
+	return summary
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	ledger, err := OpenLedger("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.Record(testStart, ledgerSummary("firefox", 10*time.Minute, 10*time.Minute)) // 09:10-09:20
+
+	tests := []struct {
+		name      string
+		summary   ActivitySummary
+		wantOK    bool
+		wantStart time.Duration
+		wantDur   time.Duration
+	}{
+		{"already submitted", ledgerSummary("firefox", 10*time.Minute, 10*time.Minute), false, 0, 0},
+		{"covered", ledgerSummary("firefox", 12*time.Minute, 5*time.Minute), false, 0, 0},
+		{"other app", ledgerSummary("kitty", 10*time.Minute, 10*time.Minute), true, 10 * time.Minute, 10 * time.Minute},
+		{"disjoint", ledgerSummary("firefox", 20*time.Minute, 5*time.Minute), true, 20 * time.Minute, 5 * time.Minute},
+		{"head overlap", ledgerSummary("firefox", 15*time.Minute, 10*time.Minute), true, 20 * time.Minute, 5 * time.Minute},
+		{"tail overlap", ledgerSummary("firefox", 5*time.Minute, 10*time.Minute), true, 5 * time.Minute, 5 * time.Minute},
+		{"trimmed below minimum", ledgerSummary("firefox", 19*time.Minute, 90*time.Second), false, 0, 0},
+		{"other title", withDetails(ledgerSummary("firefox", 15*time.Minute, 10*time.Minute), "Docs"), true, 20 * time.Minute, 5 * time.Minute},
+		{"other project", withProject(ledgerSummary("firefox", 15*time.Minute, 10*time.Minute), "widget"), true, 15 * time.Minute, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, ok := ledger.Reconcile(tt.summary)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if ok && (!got.FirstSeen.Equal(testStart.Add(tt.wantStart)) || got.TotalDuration != tt.wantDur) {
+			t.Errorf("%s: got %s for %v, want %s for %v", tt.name,
+				got.FirstSeen.Format("15:04"), got.TotalDuration, testStart.Add(tt.wantStart).Format("15:04"), tt.wantDur)
+		}
+	}
+
+	// Entries recorded before keys still match by application and title
+	ledger.entries = append(ledger.entries, LedgerEntry{AppClass: "foot", ActivityDetails: "foot", Start: testStart, End: testStart.Add(10 * time.Minute)})
+	if got, ok := ledger.Reconcile(ledgerSummary("foot", 5*time.Minute, 10*time.Minute)); !ok || got.TotalDuration != 5*time.Minute {
+		t.Errorf("legacy entry: got %+v, %v, want 5m left", got, ok)
+	}
+}
+
+func TestLedgerRecordsBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	ledger, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := ledgerSummary("kitty", -ledgerRetention-time.Hour, 10*time.Minute)
+	if err := ledger.Record(testStart, old); err != nil {
+		t.Fatal(err)
+	}
+	batch := []ActivitySummary{ledgerSummary("firefox", 0, 10*time.Minute), ledgerSummary("code", 10*time.Minute, 5*time.Minute)}
+	if err := ledger.Record(testStart, batch...); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 2 || reopened.Acknowledged(summaryEventID(old)) {
+		t.Errorf("ledger has %d entries, old entry kept: %v", reopened.Len(), reopened.Acknowledged(summaryEventID(old)))
+	}
+	for _, summary := range batch {
+		if !reopened.Acknowledged(summaryEventID(summary)) {
+			t.Errorf("%s not recorded", summary.AppClass)
+		}
+	}
+}
+
+func TestLedgerPreventsResendAfterRestart(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{DataKey: "data"})
+	dir := t.TempDir()
+
+	run := func(summaries map[string]ActivitySummary) {
+		outbox, err := OpenOutbox(filepath.Join(dir, "outbox.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ledger, err := OpenLedger(filepath.Join(dir, "ledger.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		submitter := NewSubmitter(testClient("", "", "data"), outbox, ledger, NewManualClock(testStart))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		go submitter.Run(ctx)
+		if summaries != nil {
+			submitter.Enqueue(summaries)
+		}
+		submitter.Close(5 * time.Second)
+	}
+
+	run(map[string]ActivitySummary{"firefox": ledgerSummary("firefox", 0, 10*time.Minute)})
+
+	// Simulate dying after the ledger write but before the outbox was updated
+	outbox, err := OpenOutbox(filepath.Join(dir, "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.Add(testStart, ledgerSummary("firefox", 0, 10*time.Minute), ledgerSummary("firefox", 5*time.Minute, 10*time.Minute))
+	run(nil)
+
+	requests := mock.RequestsTo("user_client_events")
+	if len(requests) != 2 {
+		t.Fatalf("mock received %d requests, want the original and the trimmed overlap", len(requests))
+	}
+	if mock.Accepted() != 2 {
+		t.Errorf("mock stored %d events, want 2", mock.Accepted())
+	}
+	if want := `"start_time":"2025-10-02T09:10:00Z","end_time":"2025-10-02T09:15:00Z"`; !strings.Contains(requests[1].Body, want) {
+		t.Errorf("second request %s, want the 09:10-09:15 remainder", requests[1].Body)
+	}
+}
+
+func TestResendIsDeduplicatedByEventID(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{DataKey: "data"})
+	client := testClient("", "", "data")
+
+	// Without a ledger entry, e.g. the process died before recording the acknowledgement
+	summary := ledgerSummary("firefox", 0, 10*time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := client.SubmitSummary(context.Background(), summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mock.Accepted() != 1 {
+		t.Errorf("mock stored %d events, want the resend deduplicated", mock.Accepted())
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode"
+)
+
+// journaldSocket is the native journal protocol socket provided by systemd-journald
+const journaldSocket = "/run/systemd/journal/socket"
+
+// redactedValue replaces any secret removed from log output
+const redactedValue = "REDACTED"
+
+// sensitiveKeys lists attribute names whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"key":                     true,
+	"api_key":                 true,
+	"apikey":                  true,
+	"account_key":             true,
+	"data_key":                true,
+	"password":                true,
+	"token":                   true,
+	"authorization":           true,
+	"rescue_time_api_key":     true,
+	"rescue_time_account_key": true,
+	"rescue_time_data_key":    true,
+}
+
+// secretPatterns match secrets embedded in free-form strings such as request URLs and error messages
+var secretPatterns = []*regexp.Regexp{
+	// ?key=abc / &api_key=abc / password=abc in URLs and form bodies
+	regexp.MustCompile(`(?i)((?:^|[?&\s"'])(?:key|api_key|account_key|data_key|access_token|token|password)=)[^&\s"']+`),
+	// Authorization: Bearer abc
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`),
+	// RESCUE_TIME_API_KEY=abc as found in .env style dumps
+	regexp.MustCompile(`(?i)(RESCUE_TIME_[A-Z_]*KEY=)\S+`),
+	// "api_key":"abc" in JSON request and response bodies
+	regexp.MustCompile(`(?i)("(?:key|api_key|apikey|account_key|data_key|access_token|token|password|authorization)"\s*:\s*")(?:[^"\\]|\\.)*`),
+}
+
+// redactString masks any secrets found in s
+func redactString(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, "${1}"+redactedValue)
+	}
+	return s
+}
+
+// redactAttr masks sensitive attribute values, descending into groups
+func redactAttr(a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redactedValue)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactString(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			redacted[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		// Errors and Stringers frequently carry request URLs (e.g. *url.Error)
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, redactString(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, redactString(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// redactingHandler wraps another handler and guarantees secrets never reach its output.
+// Unlike HandlerOptions.ReplaceAttr it also covers the record message.
+type redactingHandler struct {
+	inner slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, clean)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactingHandler{inner: h.inner.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{inner: h.inner.WithGroup(name)}
+}
+
+// journaldHandler writes records to systemd-journald using the native protocol,
+// so levels become PRIORITY and attributes become indexed journal fields.
+type journaldHandler struct {
+	mu         *sync.Mutex
+	conn       net.Conn
+	level      slog.Leveler
+	identifier string
+	prefix     string      // group prefix applied to subsequent attribute names
+	attrs      []slog.Attr // attributes added via WithAttrs, already prefixed
+}
+
+// newJournaldHandler connects to the journald socket
+func newJournaldHandler(level slog.Leveler) (*journaldHandler, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %v", err)
+	}
+	return &journaldHandler{
+		mu:         &sync.Mutex{},
+		conn:       conn,
+		level:      level,
+		identifier: filepath.Base(os.Args[0]),
+	}, nil
+}
+
+func (h *journaldHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *journaldHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", r.Message)
+	writeJournalField(&buf, "PRIORITY", journalPriority(r.Level))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", h.identifier)
+	writeJournalField(&buf, "LEVEL", r.Level.String())
+
+	for _, a := range h.attrs {
+		writeJournalAttr(&buf, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeJournalAttr(&buf, h.prefix, a)
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.conn.Write(buf.Bytes())
+	return err
+}
+
+func (h *journaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &clone
+}
+
+func (h *journaldHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "_"
+	return &clone
+}
+
+// journalPriority maps slog levels onto syslog priorities
+func journalPriority(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "3"
+	case level >= slog.LevelWarn:
+		return "4"
+	case level >= slog.LevelInfo:
+		return "6"
+	default:
+		return "7"
+	}
+}
+
+// writeJournalAttr flattens an attribute (and any group) into journal fields
+func writeJournalAttr(buf *bytes.Buffer, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		for _, ga := range v.Group() {
+			writeJournalAttr(buf, prefix+a.Key+"_", ga)
+		}
+		return
+	}
+	name := journalFieldName(prefix + a.Key)
+	if name == "" {
+		return
+	}
+	writeJournalField(buf, name, v.String())
+}
+
+// journalFieldName converts an attribute key into a valid journal field name:
+// uppercase ASCII letters, digits and underscores, not starting with an underscore
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return unicode.ToUpper(r)
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// writeJournalField encodes one field using the native protocol. Values
+// containing newlines use the binary length-prefixed form.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// runningUnderJournald reports whether stderr is connected to the journal,
+// as signalled by systemd through JOURNAL_STREAM
+func runningUnderJournald() bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" {
+		return false
+	}
+	var dev, ino uint64
+	if _, err := fmt.Sscanf(stream, "%d:%d", &dev, &ino); err != nil {
+		return false
+	}
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && uint64(st.Dev) == dev && st.Ino == ino
+}
+
+// parseLogLevel converts a -log-level value into a slog level
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// setupLogging installs the default slog logger. format is one of
+// auto, text, json or journald; auto picks journald when running under systemd.
+func setupLogging(w io.Writer, format, levelName string) error {
+	level, err := parseLogLevel(levelName)
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case "auto":
+		if runningUnderJournald() {
+			if jh, err := newJournaldHandler(level); err == nil {
+				handler = jh
+				break
+			}
+		}
+		handler = slog.NewTextHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "journald":
+		jh, err := newJournaldHandler(level)
+		if err != nil {
+			return err
+		}
+		handler = jh
+	default:
+		return fmt.Errorf("invalid log format %q (use auto, text, json or journald)", format)
+	}
+
+	slog.SetDefault(slog.New(&redactingHandler{inner: handler}))
+	return nil
+}
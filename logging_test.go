@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testSecret = "s3cr3t-K3y"
+
+func TestRedactString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"GET https://www.rescuetime.com/anapi/data?key=" + testSecret + "&format=json", "GET https://www.rescuetime.com/anapi/data?key=REDACTED&format=json"},
+		{"api_key=" + testSecret, "api_key=REDACTED"},
+		{"posting with account_key=" + testSecret, "posting with account_key=REDACTED"},
+		{"Authorization: Bearer " + testSecret, "Authorization: Bearer REDACTED"},
+		{"RESCUE_TIME_DATA_KEY=" + testSecret, "RESCUE_TIME_DATA_KEY=REDACTED"},
+		{`{"api_key":"` + testSecret + `","format":"json"}`, `{"api_key":"REDACTED","format":"json"}`},
+		{`{"access_token": "` + testSecret + `"}`, `{"access_token": "REDACTED"}`},
+		{`{"password":"a\"` + testSecret + `"}`, `{"password":"REDACTED"}`},
+		{`{"keyboard":"us"}`, `{"keyboard":"us"}`},
+		{"monkey=business", "monkey=business"},
+		{"no secrets here", "no secrets here"},
+	}
+	for _, tt := range tests {
+		if got := redactString(tt.in); got != tt.want {
+			t.Errorf("redactString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+func TestRedactingHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(*slog.Logger)
+	}{
+		{"api_key attribute", func(l *slog.Logger) { l.Info("configured", "api_key", testSecret) }},
+		{"key attribute", func(l *slog.Logger) { l.Info("configured", "Key", testSecret) }},
+		{"authorization attribute", func(l *slog.Logger) { l.Info("request", "authorization", "Bearer "+testSecret) }},
+		{"sensitive non-string", func(l *slog.Logger) { l.Info("configured", "token", []byte(testSecret)) }},
+		{"message", func(l *slog.Logger) { l.Info("GET /anapi/data?key=" + testSecret) }},
+		{"error", func(l *slog.Logger) {
+			l.Error("request failed", "error", errors.New(`Get "https://www.rescuetime.com/anapi/data?key=`+testSecret+`": timeout`))
+		}},
+		{"stringer", func(l *slog.Logger) { l.Info("request", "url", testStringer("/anapi/data?key="+testSecret)) }},
+		{"JSON body", func(l *slog.Logger) { l.Debug("response", "body", `{"api_key":"`+testSecret+`"}`) }},
+		{"group", func(l *slog.Logger) { l.Info("config", slog.Group("rescuetime", "data_key", testSecret)) }},
+		{"WithAttrs", func(l *slog.Logger) { l.With("password", testSecret).Info("logged in") }},
+		{"WithGroup", func(l *slog.Logger) { l.WithGroup("request").Info("sent", "query", "key="+testSecret) }},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		logger := slog.New(&redactingHandler{inner: slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})})
+		tt.log(logger)
+		if strings.Contains(out.String(), testSecret) || !strings.Contains(out.String(), redactedValue) {
+			t.Errorf("%s: %s", tt.name, out.String())
+		}
+	}
+}
+
+func TestWriteJournalField(t *testing.T) {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", "started")
+	if buf.String() != "MESSAGE=started\n" {
+		t.Errorf("plain field = %q", buf.String())
+	}
+
+	buf.Reset()
+	writeJournalField(&buf, "ERROR", "line one\nline two")
+	want := []byte("ERROR\n")
+	want = binary.LittleEndian.AppendUint64(want, uint64(len("line one\nline two")))
+	want = append(want, "line one\nline two\n"...)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("multi-line field = %q, want %q", buf.Bytes(), want)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"app_class":             "APP_CLASS",
+		"request.url":           "REQUEST_URL",
+		"_private":              "PRIVATE",
+		"2fa":                   "FA",
+		"über":                  "BER",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	}
+	for key, want := range tests {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestJournaldHandler(t *testing.T) {
+	dir, err := os.MkdirTemp("", "journal") // socket paths are limited to 108 bytes
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "socket")
+	journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	handler := &journaldHandler{mu: &sync.Mutex{}, conn: conn, level: slog.LevelInfo, identifier: "active-window"}
+	logger := slog.New(&redactingHandler{inner: handler})
+	logger.Debug("not sent")
+	logger.With("app", "kitty").WithGroup("request").Warn("submit failed", "url", "/anapi?key="+testSecret, "body", "a\nb")
+
+	buf := make([]byte, 4096)
+	n, err := journal.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := string(buf[:n])
+	for _, field := range []string{
+		"MESSAGE=submit failed\n",
+		"PRIORITY=4\n",
+		"SYSLOG_IDENTIFIER=active-window\n",
+		"APP=kitty\n",
+		"REQUEST_URL=/anapi?key=REDACTED\n",
+		"REQUEST_BODY\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n",
+	} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("entry is missing %q:\n%q", field, entry)
+		}
+	}
+	if strings.Contains(entry, "not sent") || strings.Contains(entry, testSecret) {
+		t.Errorf("entry = %q", entry)
+	}
+}
@@ -0,0 +1,912 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Failure modes understood by the mock server
+const (
+	MockFailNone         = ""
+	MockFailUnauthorized = "401"
+	MockFailRateLimit    = "429"
+	MockFailServerError  = "500"
+	MockFailUnavailable  = "503"
+	MockFailTimeout      = "timeout"
+)
+
+// MockServerConfig controls how the mock RescueTime server behaves. It can be
+// changed at runtime by POSTing JSON to /_mock/config.
+type MockServerConfig struct {
+	APIKey     string `json:"api_key"`     // accepted by offline_time_post
+	AccountKey string `json:"account_key"` // returned by activate, accepted as ?key= when QueryAuth is set
+	DataKey    string `json:"data_key"`    // returned by activate, accepted as Bearer token
+	Email      string `json:"email"`       // required by activate if set
+	Password   string `json:"password"`    // required by activate if set
+
+	QueryAuth  bool         `json:"query_auth"`  // accept ?key=account_key on user_client_events (production answers 401)
+	BulkEvents bool         `json:"bulk_events"` // accept {"user_client_events": [...]} batches
+	Fail       string       `json:"fail"`        // failure mode applied to API endpoints
+	FailCount  int          `json:"fail_count"`  // fail only the first N API requests (0 = every request)
+	Delay      jsonDuration `json:"delay"`       // how long the timeout mode stalls
+	RetryAfter int          `json:"retry_after"` // Retry-After seconds sent with 429 and 503
+	BlockList  []string     `json:"block_list"`  // sites and apps served by /api/block_list
+
+	Plan         string          `json:"plan"`          // account plan reported by /config
+	SyncInterval int             `json:"sync_interval"` // upload interval in seconds reported by /config (0 = omitted)
+	Features     map[string]bool `json:"features"`      // feature flags reported by /config
+}
+
+// jsonDuration is a time.Duration that reads and writes JSON as "1m30s"
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// MockRequest is one request received by the mock server
+type MockRequest struct {
+	Time     time.Time         `json:"time"`
+	Endpoint string            `json:"endpoint"`
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Query    string            `json:"query,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Status   int               `json:"status"`
+}
+
+// MockServer emulates the RescueTime endpoints used by the tracker and records
+// everything it receives, for offline end-to-end testing
+type MockServer struct {
+	mu         sync.Mutex
+	config     MockServerConfig
+	failures   int // failures injected so far
+	requests   []MockRequest
+	nextID     int
+	accepted   map[string]int        // event ID for each idempotency key
+	stored     []mockActivity        // accepted time, served back by the data API
+	focus      map[bool][]FocusEvent // FocusTime started (true) and ended (false) feeds, newest first
+	alerts     []ServerAlert         // Alerts Feed, newest first
+	highlights []Highlight           // posted highlights, newest first
+	record     *json.Encoder         // optional JSON Lines log of requests
+}
+
+// NewMockServer creates a mock server, filling in keys that were not configured
+func NewMockServer(config MockServerConfig) *MockServer {
+	if config.APIKey == "" {
+		config.APIKey = "mock-api-key"
+	}
+	if config.AccountKey == "" {
+		config.AccountKey = randomHex(16)
+	}
+	if config.DataKey == "" {
+		config.DataKey = randomHex(22)
+	}
+	if config.Delay == 0 {
+		config.Delay = jsonDuration(30 * time.Second)
+	}
+	return &MockServer{config: config, accepted: make(map[string]int), focus: make(map[bool][]FocusEvent)}
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Config returns the current configuration
+func (s *MockServer) Config() MockServerConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+// SetConfig replaces the configuration and resets the failure counter
+func (s *MockServer) SetConfig(config MockServerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+	s.failures = 0
+}
+
+// Requests returns a copy of every request received so far
+func (s *MockServer) Requests() []MockRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MockRequest(nil), s.requests...)
+}
+
+// RequestsTo returns the received requests for one endpoint (e.g. "offline_time_post")
+func (s *MockServer) RequestsTo(endpoint string) []MockRequest {
+	var matched []MockRequest
+	for _, req := range s.Requests() {
+		if req.Endpoint == endpoint {
+			matched = append(matched, req)
+		}
+	}
+	return matched
+}
+
+// Reset forgets all recorded requests and injected failures
+func (s *MockServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.failures = 0
+}
+
+// mockEndpoint maps request paths to endpoint names
+func mockEndpoint(path string) string {
+	switch path {
+	case "/activate":
+		return "activate"
+	case "/api/resource/user_client_events":
+		return "user_client_events"
+	case "/anapi/offline_time_post":
+		return "offline_time_post"
+	case "/anapi/data":
+		return "data"
+	case "/anapi/daily_summary_feed":
+		return "daily_summary_feed"
+	case "/api/block_list":
+		return "block_list"
+	case "/config":
+		return "config"
+	case "/anapi/highlights_feed":
+		return "highlights_feed"
+	case "/anapi/highlights_post":
+		return "highlights_post"
+	case "/anapi/alerts_feed":
+		return "alerts_feed"
+	case "/anapi/start_focustime":
+		return "start_focustime"
+	case "/anapi/end_focustime":
+		return "end_focustime"
+	case "/anapi/focustime_started_feed":
+		return "focustime_started_feed"
+	case "/anapi/focustime_ended_feed":
+		return "focustime_ended_feed"
+	}
+	return ""
+}
+
+// mockMethod is the HTTP method an endpoint accepts
+func mockMethod(endpoint string) string {
+	switch endpoint {
+	case "data", "daily_summary_feed", "focustime_started_feed", "focustime_ended_feed", "block_list", "config", "alerts_feed", "highlights_feed":
+		return http.MethodGet
+	}
+	return http.MethodPost
+}
+
+// statusWriter captures the status code written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/_mock/") {
+		s.serveControl(w, r)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	endpoint := mockEndpoint(r.URL.Path)
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+	switch {
+	case endpoint == "":
+		http.NotFound(sw, r)
+	case r.Method != mockMethod(endpoint):
+		http.Error(sw, "method not allowed", http.StatusMethodNotAllowed)
+	case s.injectFailure(sw, r):
+	case endpoint == "activate":
+		s.handleActivate(sw, r, body)
+	case endpoint == "user_client_events":
+		s.handleUserClientEvent(sw, r, body)
+	case endpoint == "offline_time_post":
+		s.handleOfflineTimePost(sw, r, body)
+	case endpoint == "data":
+		s.handleAnalyticData(sw, r)
+	case endpoint == "daily_summary_feed":
+		s.handleDailySummaryFeed(sw, r)
+	case endpoint == "block_list":
+		s.handleBlockList(sw, r)
+	case endpoint == "config":
+		s.handleConfig(sw, r)
+	case endpoint == "alerts_feed":
+		s.handleAlertsFeed(sw, r)
+	case endpoint == "highlights_post":
+		s.handleHighlightsPost(sw, r)
+	case endpoint == "highlights_feed":
+		s.handleHighlightsFeed(sw, r)
+	case endpoint == "start_focustime" || endpoint == "end_focustime":
+		s.handleFocusTrigger(sw, r, endpoint == "start_focustime")
+	case endpoint == "focustime_started_feed" || endpoint == "focustime_ended_feed":
+		s.handleFocusFeed(sw, r, endpoint == "focustime_started_feed")
+	}
+
+	s.recordRequest(endpoint, r, body, sw.status)
+}
+
+// recordRequest stores a received request
+func (s *MockServer) recordRequest(endpoint string, r *http.Request, body []byte, status int) {
+	header := make(map[string]string)
+	for _, name := range []string{"Authorization", "Content-Type", "User-Agent", "Accept", "Idempotency-Key"} {
+		if v := r.Header.Get(name); v != "" {
+			header[name] = v
+		}
+	}
+	if endpoint == "" {
+		endpoint = "unknown"
+	}
+	req := MockRequest{
+		Time:     time.Now(),
+		Endpoint: endpoint,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Header:   header,
+		Body:     string(body),
+		Status:   status,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if s.record != nil {
+		s.record.Encode(req)
+	}
+	slog.Info("mock request", "endpoint", endpoint, "method", r.Method, "status", status)
+}
+
+// injectFailure applies the configured failure mode, reporting whether it answered the request
+func (s *MockServer) injectFailure(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	config := s.config
+	if config.Fail == MockFailNone || (config.FailCount > 0 && s.failures >= config.FailCount) {
+		s.mu.Unlock()
+		return false
+	}
+	s.failures++
+	s.mu.Unlock()
+
+	retryAfter := func() {
+		if config.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(config.RetryAfter))
+		}
+	}
+
+	switch config.Fail {
+	case MockFailUnauthorized:
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	case MockFailRateLimit:
+		retryAfter()
+		writeMockJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+	case MockFailServerError:
+		writeMockJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+	case MockFailUnavailable:
+		retryAfter()
+		writeMockJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "service unavailable"})
+	case MockFailTimeout:
+		// Stall until the client gives up or the delay passes
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Duration(config.Delay)):
+		}
+		writeMockJSON(w, http.StatusGatewayTimeout, map[string]string{"error": "gateway timeout"})
+	default:
+		writeMockJSON(w, http.StatusInternalServerError, map[string]string{"error": "unknown failure mode " + config.Fail})
+	}
+	return true
+}
+
+// handleActivate emulates /activate. Form-encoded requests get the YAML-like
+// reply the desktop client parses; JSON requests get JSON.
+func (s *MockServer) handleActivate(w http.ResponseWriter, r *http.Request, body []byte) {
+	config := s.Config()
+
+	var username, password string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		json.Unmarshal(body, &req)
+		username, password = req.Username, req.Password
+	} else {
+		form, _ := url.ParseQuery(string(body))
+		username, password = form.Get("username"), form.Get("password")
+	}
+
+	ok := username != "" && password != "" &&
+		(config.Email == "" || username == config.Email) &&
+		(config.Password == "" || password == config.Password)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if !ok {
+			writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid username or password"})
+			return
+		}
+		writeMockJSON(w, http.StatusOK, map[string]string{
+			"account_key": config.AccountKey,
+			"data_key":    config.DataKey,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	if !ok {
+		fmt.Fprint(w, "c:\n- 1\n- RT:error\nmessage: invalid username or password\n")
+		return
+	}
+	fmt.Fprintf(w, "c:\n- 0\n- RT:ok\naccount_key: %s\nkey: %s\n", config.AccountKey, config.DataKey)
+}
+
+// handleUserClientEvent emulates the native event endpoint, which accepts
+// Bearer data_key auth and optionally ?key=account_key
+func (s *MockServer) handleUserClientEvent(w http.ResponseWriter, r *http.Request, body []byte) {
+	config := s.Config()
+
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	authorized := bearer == config.DataKey ||
+		(config.QueryAuth && r.URL.Query().Get("key") == config.AccountKey)
+	if !authorized {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var payload struct {
+		UserClientEventPayload
+		UserClientEventsPayload
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+
+	if config.BulkEvents && payload.UserClientEvents != nil {
+		// Each event is validated and stored on its own, like a multi-status response
+		results := make([]map[string]any, len(payload.UserClientEvents))
+		for i, event := range payload.UserClientEvents {
+			if problem := validateMockEvent(event); problem != "" {
+				results[i] = map[string]any{"status": http.StatusBadRequest, "error": problem}
+				continue
+			}
+			id, duplicate := s.acceptEvent(event.ClientEventID, eventActivity(event))
+			results[i] = map[string]any{"status": eventStatus(duplicate), "id": id, "duplicate": duplicate}
+		}
+		writeMockJSON(w, http.StatusOK, map[string]any{"results": results})
+		return
+	}
+
+	event := payload.UserClientEvent
+	if problem := validateMockEvent(event); problem != "" {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": problem})
+		return
+	}
+
+	key := event.ClientEventID
+	if key == "" {
+		key = r.Header.Get("Idempotency-Key")
+	}
+	id, duplicate := s.acceptEvent(key, eventActivity(event))
+	writeMockJSON(w, eventStatus(duplicate), map[string]any{"id": id, "duplicate": duplicate, "user_client_event": event})
+}
+
+// validateMockEvent returns why the API would reject event, or "" if it is valid
+func validateMockEvent(event UserClientEvent) string {
+	start, startErr := time.Parse(time.RFC3339, event.StartTime)
+	end, endErr := time.Parse(time.RFC3339, event.EndTime)
+	switch {
+	case event.Application == "":
+		return "application is required"
+	case startErr != nil || endErr != nil:
+		return "start_time and end_time must be RFC 3339"
+	case !end.After(start):
+		return "end_time must be after start_time"
+	}
+	return ""
+}
+
+// mockActivity is time the mock accepted for an activity
+type mockActivity struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// eventActivity returns the time a validated native event covers
+func eventActivity(event UserClientEvent) mockActivity {
+	start, _ := time.Parse(time.RFC3339, event.StartTime)
+	end, _ := time.Parse(time.RFC3339, event.EndTime)
+	return mockActivity{Name: event.Application, Start: start, End: end}
+}
+
+// acceptEvent stores an event, returning the ID it got the first time if key
+// was seen before, the way an idempotent API answers a resend
+func (s *MockServer) acceptEvent(key string, activity mockActivity) (id int, duplicate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.accepted[key]; ok && key != "" {
+		return id, true
+	}
+	s.nextID++
+	if key != "" {
+		s.accepted[key] = s.nextID
+	}
+	s.stored = append(s.stored, activity)
+	return s.nextID, false
+}
+
+// Accepted returns how many distinct events were stored
+func (s *MockServer) Accepted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextID
+}
+
+// eventStatus is 201 for a new event and 200 for a resend
+func eventStatus(duplicate bool) int {
+	if duplicate {
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// handleOfflineTimePost emulates the Offline Time POST API, including its
+// validation rules (4 hour maximum, no future entries, 255 character fields)
+func (s *MockServer) handleOfflineTimePost(w http.ResponseWriter, r *http.Request, body []byte) {
+	config := s.Config()
+
+	if r.URL.Query().Get("key") != config.APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+
+	var payload struct {
+		StartTime       string `json:"start_time"`
+		Duration        int    `json:"duration"`
+		EndTime         string `json:"end_time"`
+		ActivityName    string `json:"activity_name"`
+		ActivityDetails string `json:"activity_details"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+
+	start, err := time.ParseInLocation("2006-01-02 15:04:05", payload.StartTime, time.Local)
+	if err != nil {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "start_time must be YYYY-MM-DD HH:MM:SS"})
+		return
+	}
+	duration := time.Duration(payload.Duration) * time.Minute
+	if payload.EndTime != "" {
+		end, err := time.ParseInLocation("2006-01-02 15:04:05", payload.EndTime, time.Local)
+		if err != nil {
+			writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "end_time must be YYYY-MM-DD HH:MM:SS"})
+			return
+		}
+		duration = end.Sub(start)
+	}
+
+	switch {
+	case payload.ActivityName == "" || len(payload.ActivityName) > 255:
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "activity_name must be 1-255 characters"})
+	case len(payload.ActivityDetails) > 255:
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "activity_details must be at most 255 characters"})
+	case duration <= 0 || duration > 4*time.Hour:
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "duration must be between 1 minute and 4 hours"})
+	case start.After(time.Now()):
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "offline time can not be created for future dates"})
+	default:
+		_, duplicate := s.acceptEvent(r.Header.Get("Idempotency-Key"), mockActivity{Name: payload.ActivityName, Start: start, End: start.Add(duration)})
+		writeMockJSON(w, http.StatusOK, map[string]bool{"success": true, "duplicate": duplicate})
+	}
+}
+
+// handleAnalyticData emulates the Analytic Data API for the report the
+// tracker uses (perspective=interval, restrict_kind=activity, hourly), built
+// from the time accepted so far
+func (s *MockServer) handleAnalyticData(w http.ResponseWriter, r *http.Request) {
+	config := s.Config()
+	query := r.URL.Query()
+
+	if query.Get("key") != config.APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+	if query.Get("format") != "json" || query.Get("perspective") != "interval" ||
+		query.Get("restrict_kind") != "activity" || query.Get("resolution_time") != "hour" {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "the mock only serves format=json&perspective=interval&restrict_kind=activity&resolution_time=hour"})
+		return
+	}
+	begin, beginErr := time.ParseInLocation(storeDateFormat, query.Get("restrict_begin"), time.Local)
+	end, endErr := time.ParseInLocation(storeDateFormat, query.Get("restrict_end"), time.Local)
+	if beginErr != nil || endErr != nil {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "restrict_begin and restrict_end must be YYYY-MM-DD"})
+		return
+	}
+	end = end.AddDate(0, 0, 1)
+
+	type bucket struct {
+		hour time.Time
+		name string
+	}
+	seconds := make(map[bucket]float64)
+	s.mu.Lock()
+	for _, activity := range s.stored {
+		splitByHour(activity.Start, activity.End, func(hour time.Time, d time.Duration) {
+			if !hour.Before(begin) && hour.Before(end) {
+				seconds[bucket{hour, activity.Name}] += d.Seconds()
+			}
+		})
+	}
+	s.mu.Unlock()
+
+	rows := [][]any{}
+	for b, secs := range seconds {
+		rows = append(rows, []any{b.hour.Format(analyticDateFormat), int(secs), 1, b.name, "Uncategorized", 0})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i][0] != rows[j][0] {
+			return rows[i][0].(string) < rows[j][0].(string)
+		}
+		return rows[i][3].(string) < rows[j][3].(string)
+	})
+	writeMockJSON(w, http.StatusOK, AnalyticData{
+		Notes:      "data is an array of arrays (rows), column names for rows in row_headers",
+		RowHeaders: []string{"Date", "Time Spent (seconds)", "Number of People", "Activity", "Category", "Productivity"},
+		Rows:       rows,
+	})
+}
+
+// handleDailySummaryFeed emulates the Daily Summary Feed for the two weeks
+// before today. The mock doesn't categorize, so all time is neutral and
+// uncategorized and the pulse is 50.
+func (s *MockServer) handleDailySummaryFeed(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("key") != s.Config().APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	hours := make(map[time.Time]float64)
+	s.mu.Lock()
+	for _, activity := range s.stored {
+		splitByHour(activity.Start, activity.End, func(hour time.Time, d time.Duration) {
+			day := time.Date(hour.Year(), hour.Month(), hour.Day(), 0, 0, 0, 0, time.Local)
+			if day.Before(today) && !day.Before(today.AddDate(0, 0, -14)) {
+				hours[day] += d.Hours()
+			}
+		})
+	}
+	s.mu.Unlock()
+
+	summaries := []DailySummary{}
+	for day, total := range hours {
+		summaries = append(summaries, DailySummary{
+			ID:                      day.Unix(),
+			Date:                    day.Format(storeDateFormat),
+			ProductivityPulse:       50,
+			NeutralPercentage:       100,
+			UncategorizedPercentage: 100,
+			TotalHours:              math.Round(total*100) / 100,
+			TotalDurationFormatted:  time.Duration(total * float64(time.Hour)).Round(time.Second).String(),
+		})
+	}
+	// Newest first, like the real feed
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Date > summaries[j].Date })
+	writeMockJSON(w, http.StatusOK, summaries)
+}
+
+// handleBlockList serves the configured block list with Bearer data_key auth
+func (s *MockServer) handleBlockList(w http.ResponseWriter, r *http.Request) {
+	config := s.Config()
+	if strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") != config.DataKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	entries := []map[string]string{}
+	for _, pattern := range config.BlockList {
+		entries = append(entries, map[string]string{"url": pattern})
+	}
+	writeMockJSON(w, http.StatusOK, map[string]any{"block_list": entries})
+}
+
+// handleConfig serves the client config with Bearer data_key auth
+func (s *MockServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	config := s.Config()
+	if strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") != config.DataKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	features := config.Features
+	if features == nil {
+		features = map[string]bool{}
+	}
+	response := map[string]any{"plan": config.Plan, "features": features}
+	if config.SyncInterval > 0 {
+		response["sync_interval"] = config.SyncInterval
+	}
+	writeMockJSON(w, http.StatusOK, response)
+}
+
+// handleFocusTrigger emulates the FocusTime Trigger API, logging the session
+// to the feeds right away instead of on the next desktop app sync
+func (s *MockServer) handleFocusTrigger(w http.ResponseWriter, r *http.Request, start bool) {
+	query := r.URL.Query()
+	if query.Get("key") != s.Config().APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+
+	event := FocusEvent{}
+	if start {
+		duration, err := strconv.Atoi(query.Get("duration"))
+		if err != nil || (duration != focusUntilEndOfDay && (duration <= 0 || duration%5 != 0)) {
+			writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "duration must be a multiple of 5 or -1"})
+			return
+		}
+		event.Duration = duration
+	}
+	s.AddFocusEvent(start, time.Now(), event.Duration)
+	writeMockJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// AddFocusEvent logs a FocusTime session started or ended at, as if it had
+// been triggered from the web or phone
+func (s *MockServer) AddFocusEvent(started bool, at time.Time, duration int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event := FocusEvent{
+		ID:        float64(at.UnixNano()) / 1e9,
+		Duration:  duration,
+		CreatedAt: at.Local().Format(analyticDateFormat),
+	}
+	s.focus[started] = append([]FocusEvent{event}, s.focus[started]...)
+}
+
+// handleFocusFeed emulates the FocusTime Feed API
+func (s *MockServer) handleFocusFeed(w http.ResponseWriter, r *http.Request, started bool) {
+	if r.URL.Query().Get("key") != s.Config().APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+	s.mu.Lock()
+	events := append([]FocusEvent{}, s.focus[started]...)
+	s.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, events)
+}
+
+// AddAlert logs a triggered alert to the Alerts Feed
+func (s *MockServer) AddAlert(alertID int64, description string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alert := ServerAlert{
+		ID:          int64(len(s.alerts) + 1),
+		AlertID:     alertID,
+		Description: description,
+		CreatedAt:   at.Local().Format(analyticDateFormat),
+	}
+	s.alerts = append([]ServerAlert{alert}, s.alerts...)
+}
+
+// handleAlertsFeed emulates the Alerts Feed API. op=list returns the alerts
+// that were triggered, as the mock has no alert definitions of its own.
+func (s *MockServer) handleAlertsFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("key") != s.Config().APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+	s.mu.Lock()
+	alerts := append([]ServerAlert{}, s.alerts...)
+	s.mu.Unlock()
+
+	switch query.Get("op") {
+	case "", "status":
+		writeMockJSON(w, http.StatusOK, alerts)
+	case "list":
+		definitions := []map[string]any{}
+		seen := make(map[int64]bool)
+		for _, alert := range alerts {
+			if !seen[alert.AlertID] {
+				seen[alert.AlertID] = true
+				definitions = append(definitions, map[string]any{"id": alert.AlertID, "description": alert.Description})
+			}
+		}
+		writeMockJSON(w, http.StatusOK, definitions)
+	default:
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "op must be status or list"})
+	}
+}
+
+// handleHighlightsPost emulates the Highlights POST API and its validation
+func (s *MockServer) handleHighlightsPost(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("key") != s.Config().APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+
+	date := query.Get("highlight_date")
+	if _, err := time.Parse(storeDateFormat, date); err != nil {
+		unix, err := strconv.ParseInt(date, 10, 64)
+		if err != nil {
+			writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "highlight_date must be YYYY-MM-DD or a unix timestamp"})
+			return
+		}
+		date = time.Unix(unix, 0).Format(storeDateFormat)
+	}
+	description := query.Get("description")
+	if description == "" || len(description) > highlightMaxLength {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "description must be 1 to 255 characters"})
+		return
+	}
+
+	s.mu.Lock()
+	s.highlights = append([]Highlight{{
+		ID:          int64(len(s.highlights) + 1),
+		Description: description,
+		Date:        date,
+		Source:      query.Get("source"),
+		CreatedAt:   time.Now().Format(analyticDateFormat),
+	}}, s.highlights...)
+	s.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// handleHighlightsFeed emulates the Highlights Feed API
+func (s *MockServer) handleHighlightsFeed(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("key") != s.Config().APIKey {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+		return
+	}
+	s.mu.Lock()
+	highlights := append([]Highlight{}, s.highlights...)
+	s.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, highlights)
+}
+
+// serveControl handles /_mock/requests and /_mock/config
+func (s *MockServer) serveControl(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/_mock/requests" && r.Method == http.MethodGet:
+		requests := s.Requests()
+		if endpoint := r.URL.Query().Get("endpoint"); endpoint != "" {
+			requests = s.RequestsTo(endpoint)
+		}
+		if requests == nil {
+			requests = []MockRequest{}
+		}
+		writeMockJSON(w, http.StatusOK, requests)
+	case r.URL.Path == "/_mock/requests" && r.Method == http.MethodDelete:
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/_mock/config" && r.Method == http.MethodGet:
+		writeMockJSON(w, http.StatusOK, s.Config())
+	case r.URL.Path == "/_mock/config" && r.Method == http.MethodPost:
+		// Start from the current config so partial updates like {"fail":"429"} work
+		config := s.Config()
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.SetConfig(config)
+		writeMockJSON(w, http.StatusOK, config)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeMockJSON writes v as a JSON response
+func writeMockJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// runMockServer implements the mock-server command
+func runMockServer(args []string) error {
+	fs := flag.NewFlagSet("mock-server", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8787", "Address to listen on")
+	recordPath := fs.String("record", "", "Append every received request to this JSON Lines file")
+	var config MockServerConfig
+	fs.StringVar(&config.APIKey, "api-key", "mock-api-key", "API key accepted by offline_time_post")
+	fs.StringVar(&config.AccountKey, "account-key", "", "account_key returned by activate (default random)")
+	fs.StringVar(&config.DataKey, "data-key", "", "data_key returned by activate (default random)")
+	fs.StringVar(&config.Email, "email", "", "Email required by activate (default any)")
+	fs.StringVar(&config.Password, "password", "", "Password required by activate (default any)")
+	fs.BoolVar(&config.BulkEvents, "bulk", false, "Accept batches of user_client_events in one request")
+	fs.BoolVar(&config.QueryAuth, "query-auth", false, "Accept ?key=account_key on user_client_events")
+	fs.StringVar(&config.Fail, "fail", "", "Failure mode for API requests: 401, 429, 500, 503 or timeout")
+	fs.IntVar(&config.FailCount, "fail-count", 0, "Only fail the first N API requests (0 = all)")
+	delay := fs.Duration("delay", 30*time.Second, "How long the timeout failure mode stalls")
+	fs.IntVar(&config.RetryAfter, "retry-after", 0, "Retry-After seconds sent with 429 and 503 responses")
+	blockList := fs.String("block-list", "", "Comma-separated sites and apps served by /api/block_list")
+	fs.StringVar(&config.Plan, "plan", planLite, "Account plan reported by /config: premium or lite")
+	fs.IntVar(&config.SyncInterval, "sync-interval", 0, "Upload interval in seconds reported by /config (0 = derive from plan)")
+	fs.Parse(args)
+
+	config.Delay = jsonDuration(*delay)
+	if *blockList != "" {
+		config.BlockList = strings.Split(*blockList, ",")
+	}
+	server := NewMockServer(config)
+
+	if *recordPath != "" {
+		f, err := os.OpenFile(*recordPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open record file: %v", err)
+		}
+		defer f.Close()
+		server.record = json.NewEncoder(f)
+	}
+
+	httpServer := &http.Server{Addr: *listen, Handler: server}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	config = server.Config()
+	base := "http://" + *listen
+	fmt.Printf("Mock RescueTime server listening on %s\n", base)
+	fmt.Printf("  RESCUE_TIME_API_KEY=%s\n", config.APIKey)
+	fmt.Printf("  RESCUE_TIME_ACCOUNT_KEY=%s\n", config.AccountKey)
+	fmt.Printf("  RESCUE_TIME_DATA_KEY=%s\n", config.DataKey)
+	fmt.Printf("Point the tracker at it with: -api-url %s -web-url %s\n", base, base)
+	fmt.Printf("Inspect requests at %s/_mock/requests, change behaviour via POST %s/_mock/config\n", base, base)
+
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("mock server failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startMockServer runs a MockServer and points endpoints at it for the duration of the test
+func startMockServer(t *testing.T, config MockServerConfig) *MockServer {
+	t.Helper()
+	mock := NewMockServer(config)
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	previous := endpoints
+	endpoints = Endpoints{API: server.URL, Web: server.URL}
+	t.Cleanup(func() { endpoints = previous })
+
+	return mock
+}
+
+// testClient creates a client for the mock server with fast retries
+func testClient(apiKey, accountKey, dataKey string) *RescueTimeClient {
+	client := NewRescueTimeClient(endpoints, apiKey, accountKey, dataKey)
+	client.Governor.BaseDelay = time.Millisecond
+	return client
+}
+
+func testPayload() RescueTimePayload {
+	return summaryToPayload(ActivitySummary{
+		AppClass:        "firefox",
+		ActivityDetails: "GitHub",
+		TotalDuration:   5 * time.Minute,
+		FirstSeen:       time.Now().Add(-10 * time.Minute),
+	})
+}
+
+func testEvent() UserClientEventPayload {
+	return summaryToUserClientEvent(ActivitySummary{
+		AppClass:        "firefox",
+		ActivityDetails: "GitHub",
+		TotalDuration:   5 * time.Minute,
+		FirstSeen:       time.Now().Add(-10 * time.Minute),
+	})
+}
+
+func TestMockOfflineTimePost(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key"})
+
+	if err := testClient("test-key", "", "").SubmitOfflineTime(context.Background(), testPayload()); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+
+	requests := mock.RequestsTo("offline_time_post")
+	if len(requests) != 1 {
+		t.Fatalf("mock received %d requests, want 1", len(requests))
+	}
+	var got RescueTimePayload
+	if err := json.Unmarshal([]byte(requests[0].Body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != testPayload() {
+		t.Errorf("mock received %+v, want %+v", got, testPayload())
+	}
+	if requests[0].Query != "key=test-key" || requests[0].Status != http.StatusOK {
+		t.Errorf("unexpected request: %+v", requests[0])
+	}
+}
+
+func TestMockOfflineTimePostRejectsBadKeyWithoutRetry(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key"})
+
+	err := testClient("wrong-key", "", "").SubmitOfflineTime(context.Background(), testPayload())
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("got %v, want 401 error", err)
+	}
+	if n := len(mock.Requests()); n != 1 {
+		t.Errorf("client errors were retried: %d requests", n)
+	}
+}
+
+func TestMockOfflineTimePostValidatesPayload(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key"})
+
+	payload := testPayload()
+	payload.Duration = 5 * 60 // over the 4 hour limit
+	if err := testClient("test-key", "", "").SubmitOfflineTime(context.Background(), payload); err == nil {
+		t.Fatal("expected 400 for a 5 hour entry")
+	}
+	if status := mock.Requests()[0].Status; status != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", status)
+	}
+}
+
+func TestMockRetriesServerErrors(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key", Fail: MockFailServerError, FailCount: 1})
+
+	if err := testClient("test-key", "", "").SubmitOfflineTime(context.Background(), testPayload()); err != nil {
+		t.Fatalf("submit failed after retry: %v", err)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 2 || requests[0].Status != 500 || requests[1].Status != 200 {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+}
+
+func TestAuthNegotiationIsRemembered(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{AccountKey: "acct", DataKey: "data"})
+	path := filepath.Join(t.TempDir(), "auth.json")
+
+	// A previous run found query auth working, but the server now only takes Bearer
+	stale, err := OpenAuthNegotiator(path, "", "acct", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Learn(AuthQuery)
+
+	client := testClient("", "acct", "data")
+	if client.Auth, err = OpenAuthNegotiator(path, "", "acct", "data"); err != nil {
+		t.Fatal(err)
+	}
+	summary := ActivitySummary{AppClass: "firefox", ActivityDetails: "GitHub", TotalDuration: 5 * time.Minute, FirstSeen: time.Now().Add(-10 * time.Minute)}
+	for i := 0; i < 3; i++ {
+		if _, err := client.SubmitSummary(context.Background(), summary); err != nil {
+			t.Fatalf("submit %d failed: %v", i, err)
+		}
+	}
+
+	requests := mock.RequestsTo("user_client_events")
+	if len(requests) != 4 {
+		t.Fatalf("mock received %d requests, want one rejected query attempt then one bearer request per event", len(requests))
+	}
+	if requests[0].Status != http.StatusUnauthorized || requests[0].Header["Authorization"] != "" {
+		t.Errorf("first request should be a rejected query-key attempt: %+v", requests[0])
+	}
+	for _, request := range requests[1:] {
+		if request.Status/100 != 2 || request.Header["Authorization"] != "Bearer data" {
+			t.Errorf("expected an accepted bearer request: %+v", request)
+		}
+	}
+
+	reopened, err := OpenAuthNegotiator(path, "", "acct", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheme := reopened.Scheme(); scheme != AuthBearer {
+		t.Errorf("persisted scheme = %q, want bearer", scheme)
+	}
+
+	// Different keys start negotiation from scratch
+	other, err := OpenAuthNegotiator(path, "", "acct", "new-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheme := other.Scheme(); scheme != "" {
+		t.Errorf("scheme for new keys = %q, want none", scheme)
+	}
+}
+
+func TestAuthRevokedWhenEverySchemeFails(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "new-key", AccountKey: "new-acct", DataKey: "new-data"})
+
+	client := testClient("old-key", "old-acct", "old-data")
+	summary := ActivitySummary{AppClass: "firefox", TotalDuration: 5 * time.Minute, FirstSeen: time.Now().Add(-10 * time.Minute)}
+
+	_, err := client.SubmitSummary(context.Background(), summary)
+	if !errors.Is(err, errCredentialsRevoked) {
+		t.Fatalf("got %v, want errCredentialsRevoked", err)
+	}
+	if n := len(mock.Requests()); n != 3 {
+		t.Errorf("mock received %d requests, want one per scheme", n)
+	}
+
+	// Revoked credentials aren't retried until the account is activated again
+	if _, err := client.SubmitSummary(context.Background(), summary); !errors.Is(err, errCredentialsRevoked) {
+		t.Errorf("got %v, want errCredentialsRevoked", err)
+	}
+	if n := len(mock.Requests()); n != 3 {
+		t.Errorf("revoked credentials were retried: %d requests", n)
+	}
+}
+
+func TestMockUserClientEventQueryAuth(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{AccountKey: "acct", DataKey: "data", QueryAuth: true})
+
+	if err := testClient("api-key", "acct", "").SubmitUserClientEvent(context.Background(), AuthQuery, testEvent()); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if n := len(mock.Requests()); n != 1 {
+		t.Errorf("mock received %d requests, want 1", n)
+	}
+}
+
+func TestMockActivate(t *testing.T) {
+	startMockServer(t, MockServerConfig{Email: "me@example.com", Password: "secret", AccountKey: "acct"})
+
+	client := testClient("", "", "")
+	response, err := client.Activate(context.Background(), "me@example.com", "secret")
+	if err != nil {
+		t.Fatalf("activate failed: %v", err)
+	}
+	if response.AccountKey != "acct" {
+		t.Errorf("account key = %q, want acct", response.AccountKey)
+	}
+
+	if _, err := client.Activate(context.Background(), "me@example.com", "wrong"); err == nil {
+		t.Error("activate succeeded with the wrong password")
+	}
+}
+
+func TestMockTimeoutAndRateLimitModes(t *testing.T) {
+	mock := NewMockServer(MockServerConfig{Fail: MockFailRateLimit, RetryAfter: 7})
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/anapi/offline_time_post", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "7" {
+		t.Errorf("got %d with Retry-After %q, want 429 with 7", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// Switch to the timeout mode through the control endpoint
+	resp, err = http.Post(server.URL+"/_mock/config", "application/json", strings.NewReader(`{"fail":"timeout","delay":"1s"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	if _, err := client.Post(server.URL+"/anapi/offline_time_post", "application/json", strings.NewReader("{}")); err == nil {
+		t.Error("expected the client to time out")
+	}
+}
+
+func TestCancellationAbortsBackoff(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key", Fail: MockFailServerError})
+
+	client := testClient("test-key", "", "")
+	client.Governor.BaseDelay = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := client.SubmitOfflineTime(ctx, testPayload())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled submission took %v", elapsed)
+	}
+	if n := len(mock.Requests()); n != 1 {
+		t.Errorf("mock received %d requests, want 1 before cancellation", n)
+	}
+}
+
+func TestMockRateLimitIsRetriedAfterRetryAfter(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key", Fail: MockFailRateLimit, FailCount: 1, RetryAfter: 1})
+
+	start := time.Now()
+	if err := testClient("test-key", "", "").SubmitOfflineTime(context.Background(), testPayload()); err != nil {
+		t.Fatalf("submit failed after 429: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, before the 1s Retry-After", elapsed)
+	}
+	requests := mock.Requests()
+	if len(requests) != 2 || requests[0].Status != http.StatusTooManyRequests || requests[1].Status != http.StatusOK {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+}
+
+func TestCircuitBreakerStopsOutageRetries(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key", Fail: MockFailUnavailable})
+	outbox, err := OpenOutbox("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := testClient("test-key", "", "")
+	client.Concurrency = 1
+	submitter := NewSubmitter(client, outbox, nil, NewManualClock(testStart))
+
+	summaries := map[string]ActivitySummary{}
+	for _, app := range []string{"firefox", "kitty", "code", "slack", "spotify"} {
+		summaries[app] = ActivitySummary{AppClass: app, TotalDuration: 5 * time.Minute, FirstSeen: testStart}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go submitter.Run(ctx)
+	submitter.Enqueue(summaries)
+	submitter.Close(5 * time.Second)
+
+	if n := len(mock.Requests()); n != client.Governor.FailureThreshold {
+		t.Errorf("mock received %d requests during the outage, want %d before the circuit opened", n, client.Governor.FailureThreshold)
+	}
+	if client.Governor.State() != circuitOpen {
+		t.Errorf("circuit %s, want open", client.Governor.State())
+	}
+	if n := outbox.Len(); n != len(summaries) {
+		t.Errorf("%d items queued, want all %d kept", n, len(summaries))
+	}
+}
+
+func TestLegacyFallbackIsIdempotent(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "key", DataKey: "data", Fail: MockFailServerError, FailCount: 1})
+	client := testClient("key", "", "data")
+	client.MaxRetries = 1
+	client.Auth.Learn(AuthBearer)
+
+	summary := ActivitySummary{AppClass: "firefox", TotalDuration: 5 * time.Minute, FirstSeen: time.Now().Add(-10 * time.Minute)}
+	usedFallback, err := client.SubmitSummary(context.Background(), summary)
+	if err != nil || !usedFallback {
+		t.Fatalf("SubmitSummary = %v, %v, want a successful fallback", usedFallback, err)
+	}
+	requests := mock.RequestsTo("offline_time_post")
+	if len(requests) != 1 || requests[0].Header["Idempotency-Key"] != summaryEventID(summary) {
+		t.Errorf("fallback requests = %+v, want one with the event ID as Idempotency-Key", requests)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// OutboxItem is one activity summary waiting to be submitted
+type OutboxItem struct {
+	ID       int64           `json:"id"`
+	Summary  ActivitySummary `json:"summary"`
+	QueuedAt time.Time       `json:"queued_at"`
+	Attempts int             `json:"attempts"`
+}
+
+// outboxFile is the on-disk format of the outbox
+type outboxFile struct {
+	NextID int64        `json:"next_id"`
+	Items  []OutboxItem `json:"items"`
+}
+
+// Outbox is a persistent queue of summaries that haven't reached RescueTime
+// yet. Every change is written to disk, so queued data survives restarts,
+// crashes and shutdowns that run out of time.
+type Outbox struct {
+	mu   sync.Mutex
+	path string // empty keeps the queue in memory only
+	data outboxFile
+}
+
+// OpenOutbox loads the outbox at path, starting empty if it doesn't exist
+func OpenOutbox(path string) (*Outbox, error) {
+	o := &Outbox{path: path, data: outboxFile{NextID: 1}}
+	if path == "" {
+		return o, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %v", err)
+	}
+	if err := json.Unmarshal(raw, &o.data); err != nil {
+		return nil, fmt.Errorf("failed to parse outbox %s: %v", path, err)
+	}
+	return o, nil
+}
+
+// Add queues summaries for submission and returns how many were added.
+// Summaries whose event is already queued are skipped, so backfilling a day
+// that is still waiting to be delivered doesn't send it twice.
+func (o *Outbox) Add(now time.Time, summaries ...ActivitySummary) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	queued := make(map[string]bool, len(o.data.Items))
+	for _, item := range o.data.Items {
+		queued[summaryEventID(item.Summary)] = true
+	}
+	added := 0
+	for _, summary := range summaries {
+		id := summaryEventID(summary)
+		if queued[id] {
+			continue
+		}
+		queued[id] = true
+		o.data.Items = append(o.data.Items, OutboxItem{ID: o.data.NextID, Summary: summary, QueuedAt: now})
+		o.data.NextID++
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, o.saveUnsafe()
+}
+
+// Pending returns a copy of the queued items, oldest first
+func (o *Outbox) Pending() []OutboxItem {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	items := make([]OutboxItem, len(o.data.Items))
+	copy(items, o.data.Items)
+	return items
+}
+
+// Len returns the number of queued items
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.data.Items)
+}
+
+// Remove drops a submitted (or permanently rejected) item
+func (o *Outbox) Remove(id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, item := range o.data.Items {
+		if item.ID == id {
+			o.data.Items = append(o.data.Items[:i], o.data.Items[i+1:]...)
+			return o.saveUnsafe()
+		}
+	}
+	return nil
+}
+
+// RecordAttempt counts a failed submission of an item
+func (o *Outbox) RecordAttempt(id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.data.Items {
+		if o.data.Items[i].ID == id {
+			o.data.Items[i].Attempts++
+			return o.saveUnsafe()
+		}
+	}
+	return nil
+}
+
+// saveUnsafe writes the outbox to disk (caller must hold lock)
+func (o *Outbox) saveUnsafe() error {
+	if o.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(o.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox: %v", err)
+	}
+	return writeFileAtomic(o.path, raw, 0600)
+}
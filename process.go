@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// subActivitySeparator joins a terminal and its foreground process, as in "kitty › nvim"
+const subActivitySeparator = " › "
+
+// baseClass strips the sub-activity from an application class, so
+// "kitty › nvim" is "kitty"
+func baseClass(appClass string) string {
+	class, _, _ := strings.Cut(appClass, subActivitySeparator)
+	return class
+}
+
+// terminalClasses are window classes, matched as substrings, of terminal
+// emulators whose foreground process is tracked as a sub-activity
+var terminalClasses = []string{
+	"kitty", "alacritty", "foot", "wezterm", "warp", "ghostty", "konsole",
+	"gnome-terminal", "xfce4-terminal", "terminator", "tilix", "xterm", "urxvt", "st-256color",
+}
+
+// shells are not reported as sub-activities: at a prompt the terminal
+// itself is what is being used
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "fish": true, "dash": true, "ksh": true,
+	"tcsh": true, "csh": true, "nu": true, "xonsh": true, "elvish": true,
+}
+
+// isTerminal reports whether a window class belongs to a terminal emulator
+func isTerminal(class string) bool {
+	class = strings.ToLower(class)
+	for _, name := range terminalClasses {
+		if strings.Contains(class, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// procStat holds the fields of /proc/<pid>/stat used to find the foreground process
+type procStat struct {
+	PID       int
+	Name      string
+	PPID      int
+	PGRP      int
+	TTY       int
+	TPGID     int    // foreground process group of the controlling terminal
+	StartTime uint64 // clock ticks after boot
+}
+
+// parseProcStat parses /proc/<pid>/stat. The name is in parentheses and may
+// itself contain spaces and parentheses, so fields are counted from the last ')'.
+func parseProcStat(line string) (procStat, error) {
+	open := strings.IndexByte(line, '(')
+	end := strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return procStat{}, fmt.Errorf("malformed stat %q", line)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return procStat{}, fmt.Errorf("malformed pid in stat: %v", err)
+	}
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 20 {
+		return procStat{}, fmt.Errorf("stat of %d has %d fields", pid, len(fields))
+	}
+
+	stat := procStat{PID: pid, Name: line[open+1 : end]}
+	for i, field := range []*int{&stat.PPID, &stat.PGRP, nil, &stat.TTY, &stat.TPGID} {
+		if field == nil {
+			continue
+		}
+		if *field, err = strconv.Atoi(fields[i+1]); err != nil {
+			return procStat{}, fmt.Errorf("malformed stat of %d: %v", pid, err)
+		}
+	}
+	if stat.StartTime, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("malformed start time of %d: %v", pid, err)
+	}
+	return stat, nil
+}
+
+// ProcessInfo is the process found in the foreground of a terminal
+type ProcessInfo struct {
+	PID       int
+	Name      string
+	Directory string // working directory, "" if it can't be read
+}
+
+// TerminalResolver finds the foreground process of terminal windows by
+// walking the process tree under the window's pid
+type TerminalResolver struct {
+	Root string // procfs mount, /proc if empty
+}
+
+func (r *TerminalResolver) path(pid int, name string) string {
+	root := r.Root
+	if root == "" {
+		root = "/proc"
+	}
+	return filepath.Join(root, strconv.Itoa(pid), name)
+}
+
+func (r *TerminalResolver) stat(pid int) (procStat, error) {
+	raw, err := os.ReadFile(r.path(pid, "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	return parseProcStat(strings.TrimSpace(string(raw)))
+}
+
+// children lists the child processes of pid from the children file of each
+// of its threads
+func (r *TerminalResolver) children(pid int) []int {
+	tasks, err := os.ReadDir(r.path(pid, "task"))
+	if err != nil {
+		return nil
+	}
+	var children []int
+	for _, task := range tasks {
+		raw, err := os.ReadFile(filepath.Join(r.path(pid, "task"), task.Name(), "children"))
+		if err != nil {
+			continue
+		}
+		for _, field := range strings.Fields(string(raw)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+// Foreground returns the foreground process of the terminal running as pid:
+// the leader of a process group that is in the foreground of its tty. A
+// terminal with several tabs has one per tab; the one whose name is in the
+// window title wins, otherwise the most recently started.
+func (r *TerminalResolver) Foreground(pid int, title string) (ProcessInfo, error) {
+	if pid <= 0 {
+		return ProcessInfo{}, errors.New("window has no pid")
+	}
+
+	var best *procStat
+	bestInTitle := false
+	title = strings.ToLower(title)
+	queue := r.children(pid)
+	for seen := 0; len(queue) > 0 && seen < 4096; seen++ {
+		child := queue[0]
+		queue = append(queue[1:], r.children(child)...)
+
+		stat, err := r.stat(child)
+		if err != nil || stat.TTY == 0 || stat.TPGID != stat.PGRP || stat.PID != stat.PGRP {
+			continue
+		}
+		inTitle := strings.Contains(title, strings.ToLower(stat.Name))
+		if best == nil || (inTitle && !bestInTitle) || (inTitle == bestInTitle && stat.StartTime > best.StartTime) {
+			best, bestInTitle = &stat, inTitle
+		}
+	}
+	if best == nil {
+		return ProcessInfo{}, fmt.Errorf("no foreground process under %d", pid)
+	}
+
+	info := ProcessInfo{PID: best.PID, Name: best.Name}
+	if dir, err := os.Readlink(r.path(best.PID, "cwd")); err == nil {
+		info.Directory = dir
+	}
+	return info, nil
+}
+
+// Context returns the session context of a window: for terminals, the
+// foreground process unless it is a shell, and its working directory
+func (r *TerminalResolver) Context(window *HyprlandWindow) SessionContext {
+	if r == nil || window == nil || !isTerminal(window.Class) {
+		return SessionContext{}
+	}
+	info, err := r.Foreground(window.Pid, window.Title)
+	if err != nil {
+		return SessionContext{}
+	}
+	sessionContext := SessionContext{Directory: info.Directory}
+	if !shells[info.Name] {
+		sessionContext.Process = info.Name
+	}
+	return sessionContext
+}
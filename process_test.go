@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProc builds a procfs tree under a temporary directory
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	return &fakeProc{t: t, root: t.TempDir()}
+}
+
+// add creates a process with its stat fields, children and working directory
+func (p *fakeProc) add(pid int, name string, ppid, pgrp, tty, tpgid int, start uint64, cwd string, children ...int) {
+	p.t.Helper()
+	dir := filepath.Join(p.root, fmt.Sprint(pid))
+	task := filepath.Join(dir, "task", fmt.Sprint(pid))
+	if err := os.MkdirAll(task, 0755); err != nil {
+		p.t.Fatal(err)
+	}
+	stat := fmt.Sprintf("%d (%s) S %d %d %d %d %d 4194304 0 0 0 0 0 0 0 0 20 0 1 0 %d 0 0\n", pid, name, ppid, pgrp, pgrp, tty, tpgid, start)
+	var list []string
+	for _, child := range children {
+		list = append(list, fmt.Sprint(child))
+	}
+	for name, content := range map[string]string{"stat": stat, "task/" + fmt.Sprint(pid) + "/children": strings.Join(list, " ")} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+	if cwd != "" {
+		if err := os.Symlink(cwd, filepath.Join(dir, "cwd")); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+}
+
+// kittyWithTwoTabs is a terminal with nvim running in one tab and a shell
+// at the prompt in the other, started later
+func kittyWithTwoTabs(t *testing.T) *fakeProc {
+	proc := newFakeProc(t)
+	proc.add(100, "kitty", 1, 100, 0, -1, 1000, "/home/me", 200, 300)
+	proc.add(200, "zsh", 100, 200, 34816, 250, 2000, "/home/me/src/widget", 250)
+	proc.add(250, "nvim", 200, 250, 34816, 250, 5000, "/home/me/src/widget", 251)
+	proc.add(251, "make", 250, 250, 34816, 250, 5100, "/home/me/src/widget")
+	proc.add(300, "zsh", 100, 300, 34817, 300, 6000, "/home/me/notes")
+	return proc
+}
+
+func TestParseProcStat(t *testing.T) {
+	stat, err := parseProcStat("42 (tmux: (server)) S 1 42 42 0 -1 4194304 0 0 0 0 0 0 0 0 20 0 1 0 777 0 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.PID != 42 || stat.Name != "tmux: (server)" || stat.PPID != 1 || stat.PGRP != 42 || stat.TTY != 0 || stat.TPGID != -1 || stat.StartTime != 777 {
+		t.Errorf("stat = %+v", stat)
+	}
+	for _, bad := range []string{"", "42 tmux S 1", "42 (sh) S 1 2 3"} {
+		if _, err := parseProcStat(bad); err == nil {
+			t.Errorf("stat %q accepted", bad)
+		}
+	}
+}
+
+func TestTerminalForeground(t *testing.T) {
+	resolver := &TerminalResolver{Root: kittyWithTwoTabs(t).root}
+	tests := []struct {
+		title   string
+		pid     int
+		wantDir string
+	}{
+		{"nvim main.go", 250, "/home/me/src/widget"},
+		{"~/notes", 300, "/home/me/notes"}, // neither in the title, the latest tab wins
+	}
+	for _, tt := range tests {
+		info, err := resolver.Foreground(100, tt.title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.PID != tt.pid || info.Directory != tt.wantDir {
+			t.Errorf("Foreground(%q) = %+v, want pid %d in %s", tt.title, info, tt.pid, tt.wantDir)
+		}
+	}
+	if _, err := resolver.Foreground(999, ""); err == nil {
+		t.Error("found a foreground process under a missing pid")
+	}
+}
+
+func TestTerminalContext(t *testing.T) {
+	resolver := &TerminalResolver{Root: kittyWithTwoTabs(t).root}
+	tests := []struct {
+		window *HyprlandWindow
+		want   SessionContext
+	}{
+		{&HyprlandWindow{Class: "kitty", Title: "nvim main.go", Pid: 100}, SessionContext{Process: "nvim", Directory: "/home/me/src/widget"}},
+		{&HyprlandWindow{Class: "kitty", Title: "zsh ~/notes", Pid: 100}, SessionContext{Directory: "/home/me/notes"}},
+		{&HyprlandWindow{Class: "firefox", Title: "nvim docs", Pid: 100}, SessionContext{}},
+	}
+	for _, tt := range tests {
+		if got := resolver.Context(tt.window); got != tt.want {
+			t.Errorf("Context(%s, %q) = %+v, want %+v", tt.window.Class, tt.window.Title, got, tt.want)
+		}
+	}
+	var none *TerminalResolver
+	if got := none.Context(tests[0].window); got != (SessionContext{}) {
+		t.Errorf("nil resolver = %+v", got)
+	}
+}
+
+func TestMonitorTracksTerminalSubActivities(t *testing.T) {
+	proc := kittyWithTwoTabs(t)
+	clock := NewManualClock(testStart)
+	monitor := &Monitor{Clock: clock, Out: io.Discard, Terminals: &TerminalResolver{Root: proc.root}}
+
+	monitor.HandleWindow(&HyprlandWindow{Class: "kitty", Title: "nvim main.go", Pid: 100})
+	clock.Advance(20 * time.Minute)
+	monitor.HandleWindow(&HyprlandWindow{Class: "kitty", Title: "zsh ~/notes", Pid: 100})
+	clock.Advance(5 * time.Minute)
+
+	summaries := monitor.Tracker.GetActivitySummaries()
+	nvim := summaries["kitty"+subActivitySeparator+"nvim"]
+	if nvim.TotalDuration != 20*time.Minute || nvim.Process != "nvim" || nvim.Directory != "/home/me/src/widget" {
+		t.Errorf("kitty › nvim = %+v (summaries %v)", nvim, summaries)
+	}
+	if shell := summaries["kitty"]; shell.TotalDuration != 5*time.Minute || shell.Directory != "/home/me/notes" {
+		t.Errorf("kitty = %+v", shell)
+	}
+}
+
+func TestClassifyByDirectory(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	rules, err := parseProductivityRules(strings.NewReader("dir:~/src very-productive Software Development\ndir:~/src/side-project -1 Entertainment\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	classes := &ProductivityMap{local: rules}
+	tests := []struct {
+		directory string
+		source    string
+		level     int
+	}{
+		{"/home/me/src/widget", productivitySourceLocal, levelVeryProductive},
+		{"/home/me/src/side-project/api", productivitySourceLocal, levelDistracting},
+		{"/home/me/srcs", productivitySourceDefault, levelVeryProductive}, // not under ~/src, classified as nvim
+	}
+	for _, tt := range tests {
+		rule, ok := classes.ClassifyContext("kitty › nvim", "main.go", SessionContext{Directory: tt.directory})
+		if !ok || rule.Level != tt.level || rule.Source != tt.source {
+			t.Errorf("ClassifyContext(%s) = %+v, %v, want level %d from %s", tt.directory, rule, ok, tt.level, tt.source)
+		}
+	}
+}
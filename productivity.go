@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// RescueTime's five productivity levels
+const (
+	levelVeryDistracting = -2
+	levelDistracting     = -1
+	levelNeutral         = 0
+	levelProductive      = 1
+	levelVeryProductive  = 2
+)
+
+// productivityLevelNames are the level names, as accepted in the local map
+var productivityLevelNames = map[int]string{
+	levelVeryDistracting: "very-distracting",
+	levelDistracting:     "distracting",
+	levelNeutral:         "neutral",
+	levelProductive:      "productive",
+	levelVeryProductive:  "very-productive",
+}
+
+// parseProductivityLevel accepts a level number (-2 to 2) or name
+func parseProductivityLevel(s string) (int, error) {
+	s = strings.ToLower(s)
+	for level, name := range productivityLevelNames {
+		if s == name || s == strings.ReplaceAll(name, "-", "_") {
+			return level, nil
+		}
+	}
+	level, err := strconv.Atoi(s)
+	if err != nil || level < levelVeryDistracting || level > levelVeryProductive {
+		return 0, fmt.Errorf("invalid productivity level %q (want -2 to 2 or a name such as very-productive)", s)
+	}
+	return level, nil
+}
+
+// productivityDirPrefix marks local rules matching a working directory and
+// everything below it, e.g. dir:~/src/work
+const productivityDirPrefix = "dir:"
+
+// productivityBackground is the level of local rules for windows that
+// aren't tracked at all while focused, e.g. "workspace:music background"
+const productivityBackground = "background"
+
+// Sources of productivity rules, in order of precedence
+const (
+	productivitySourceLocal      = "local"
+	productivitySourceRescueTime = "rescuetime"
+	productivitySourceDefault    = "default"
+)
+
+// ProductivityRule assigns a level and category to an application, title
+// text or site domain
+type ProductivityRule struct {
+	Pattern  string `json:"pattern"` // lower case, matched like a block list pattern
+	Level    int    `json:"level"`
+	Category string `json:"category,omitempty"`
+	Source   string `json:"source"`
+
+	Background bool `json:"background,omitempty"` // not tracked, rather than classified
+}
+
+// defaultProductivityRules is the bundled list the map falls back on, using
+// RescueTime's default categories
+var defaultProductivityRules = []ProductivityRule{
+	// Software development
+	{Pattern: "code", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "codium", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "jetbrains", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "nvim", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "emacs", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "zed", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "kitty", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "alacritty", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "foot", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "wezterm", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "github.com", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "gitlab.com", Level: levelVeryProductive, Category: "Software Development"},
+	{Pattern: "stackoverflow.com", Level: levelVeryProductive, Category: "Software Development"},
+	// Reference, learning and writing
+	{Pattern: "wikipedia.org", Level: levelProductive, Category: "Reference & Learning"},
+	{Pattern: "obsidian", Level: levelProductive, Category: "Reference & Learning"},
+	{Pattern: "libreoffice", Level: levelProductive, Category: "Business"},
+	{Pattern: "docs.google.com", Level: levelProductive, Category: "Business"},
+	{Pattern: "figma", Level: levelProductive, Category: "Design & Composition"},
+	{Pattern: "gimp", Level: levelProductive, Category: "Design & Composition"},
+	{Pattern: "inkscape", Level: levelProductive, Category: "Design & Composition"},
+	// Communication and scheduling
+	{Pattern: "slack", Level: levelNeutral, Category: "Communication & Scheduling"},
+	{Pattern: "thunderbird", Level: levelNeutral, Category: "Communication & Scheduling"},
+	{Pattern: "mail.google.com", Level: levelNeutral, Category: "Communication & Scheduling"},
+	{Pattern: "zoom", Level: levelNeutral, Category: "Communication & Scheduling"},
+	{Pattern: "teams", Level: levelNeutral, Category: "Communication & Scheduling"},
+	// Utilities
+	{Pattern: "nautilus", Level: levelNeutral, Category: "Utilities"},
+	{Pattern: "thunar", Level: levelNeutral, Category: "Utilities"},
+	{Pattern: "pavucontrol", Level: levelNeutral, Category: "Utilities"},
+	// Social networking, news and entertainment
+	{Pattern: "discord", Level: levelDistracting, Category: "Social Networking"},
+	{Pattern: "telegram", Level: levelDistracting, Category: "Social Networking"},
+	{Pattern: "news.ycombinator.com", Level: levelDistracting, Category: "News & Opinion"},
+	{Pattern: "reddit.com", Level: levelVeryDistracting, Category: "Social Networking"},
+	{Pattern: "twitter.com", Level: levelVeryDistracting, Category: "Social Networking"},
+	{Pattern: "facebook.com", Level: levelVeryDistracting, Category: "Social Networking"},
+	{Pattern: "instagram.com", Level: levelVeryDistracting, Category: "Social Networking"},
+	{Pattern: "youtube.com", Level: levelVeryDistracting, Category: "Entertainment"},
+	{Pattern: "netflix.com", Level: levelVeryDistracting, Category: "Entertainment"},
+	{Pattern: "twitch.tv", Level: levelVeryDistracting, Category: "Entertainment"},
+	{Pattern: "spotify", Level: levelDistracting, Category: "Entertainment"},
+	{Pattern: "steam", Level: levelVeryDistracting, Category: "Entertainment"},
+}
+
+// parseProductivityRules reads the local map: a pattern, a level or
+// "background" and an optional category per line. Blank lines and #
+// comments are ignored.
+func parseProductivityRules(r io.Reader) ([]ProductivityRule, error) {
+	var rules []ProductivityRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a pattern and a level", line)
+		}
+		background := strings.EqualFold(fields[1], productivityBackground)
+		level := levelNeutral
+		if !background {
+			var err error
+			if level, err = parseProductivityLevel(fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		pattern := normalizePattern(fields[0])
+		if dir, ok := strings.CutPrefix(pattern, productivityDirPrefix); ok {
+			pattern = productivityDirPrefix + expandHome(dir)
+		}
+		rules = append(rules, ProductivityRule{
+			Pattern:    pattern,
+			Level:      level,
+			Category:   strings.Join(fields[2:], " "),
+			Source:     productivitySourceLocal,
+			Background: background,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read productivity map: %v", err)
+	}
+	return rules, nil
+}
+
+// ProductivityMap classifies windows on RescueTime's productivity scale from
+// local overrides, rules synced from the Analytic Data API and the bundled
+// defaults, in that order. Browsers are classified by the site they show,
+// other windows by the application before their title, and sites never
+// match outside a browser. A nil map uses the defaults only.
+type ProductivityMap struct {
+	mu     sync.RWMutex
+	local  []ProductivityRule
+	synced []ProductivityRule
+}
+
+// LoadProductivityMap reads the local map and the synced rules; missing
+// files mean no rules from that source
+func LoadProductivityMap(localPath, syncedPath string) (*ProductivityMap, error) {
+	m := &ProductivityMap{}
+	if localPath != "" {
+		f, err := os.Open(localPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to open productivity map: %v", err)
+		}
+		if err == nil {
+			defer f.Close()
+			if m.local, err = parseProductivityRules(f); err != nil {
+				return nil, fmt.Errorf("%s: %v", localPath, err)
+			}
+		}
+	}
+	if syncedPath != "" {
+		raw, err := os.ReadFile(syncedPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read synced productivity map: %v", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(raw, &m.synced); err != nil {
+				return nil, fmt.Errorf("failed to parse synced productivity map %s: %v", syncedPath, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// rules returns every rule in order of precedence
+func (m *ProductivityMap) rules() []ProductivityRule {
+	var rules []ProductivityRule
+	if m != nil {
+		m.mu.RLock()
+		rules = append(rules, m.local...)
+		rules = append(rules, m.synced...)
+		m.mu.RUnlock()
+	}
+	for _, rule := range defaultProductivityRules {
+		rule.Source = productivitySourceDefault
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Classify returns the rule for a window, or false if no rule matches
+func (m *ProductivityMap) Classify(class, title string) (ProductivityRule, bool) {
+	return m.ClassifyContext(class, title, SessionContext{})
+}
+
+// ClassifyContext is Classify for activity with context: the workspace and
+// monitor it was shown on, and the working directory of a terminal's
+// foreground process. Workspace and monitor rules come first, in order, then
+// directory rules, the deepest directory winning.
+func (m *ProductivityMap) ClassifyContext(class, title string, sessionContext SessionContext) (ProductivityRule, bool) {
+	rules := m.rules()
+	for _, rule := range rules {
+		if isPlacementPattern(rule.Pattern) && matchPlacement(rule.Pattern, sessionContext) {
+			return rule, true
+		}
+	}
+	if directory := sessionContext.Directory; directory != "" {
+		var best ProductivityRule
+		for _, rule := range rules {
+			root, ok := strings.CutPrefix(rule.Pattern, productivityDirPrefix)
+			if ok && withinDir(strings.ToLower(directory), root) && len(rule.Pattern) > len(best.Pattern) {
+				best = rule
+			}
+		}
+		if best.Pattern != "" {
+			return best, true
+		}
+	}
+
+	window := &HyprlandWindow{Class: class, Title: title}
+	byTitle := func() (ProductivityRule, bool) {
+		lowerTitle := strings.ToLower(title)
+		for _, rule := range rules {
+			if strings.HasPrefix(rule.Pattern, productivityDirPrefix) || isPlacementPattern(rule.Pattern) {
+				continue
+			}
+			// Sites only match browsers; application names like "code" would
+			// match too many titles, so only local rules are looked up there
+			site := strings.Contains(rule.Pattern, ".")
+			if site && (BlockRule{Pattern: rule.Pattern}).Matches(window) ||
+				!site && rule.Source == productivitySourceLocal && strings.Contains(lowerTitle, rule.Pattern) {
+				return rule, true
+			}
+		}
+		return ProductivityRule{}, false
+	}
+	byClass := func() (ProductivityRule, bool) {
+		lowerClass := strings.ToLower(class)
+		for _, rule := range rules {
+			if strings.Contains(lowerClass, rule.Pattern) && !strings.HasPrefix(rule.Pattern, productivityDirPrefix) && !isPlacementPattern(rule.Pattern) {
+				return rule, true
+			}
+		}
+		return ProductivityRule{}, false
+	}
+
+	// A browser is classified by the site it shows, anything else by the
+	// application first: a terminal titled "youtube-dl" is still a terminal
+	first, second := byClass, byTitle
+	if browserFamily(class) != "" {
+		first, second = byTitle, byClass
+	}
+	if rule, ok := first(); ok {
+		return rule, true
+	}
+	return second()
+}
+
+// Background reports whether a window is matched by a background rule
+func (m *ProductivityMap) Background(class, title string, sessionContext SessionContext) bool {
+	if m == nil {
+		return false // the bundled rules have none
+	}
+	rule, ok := m.ClassifyContext(class, title, sessionContext)
+	return ok && rule.Background
+}
+
+// syncedProductivityRules derives rules from categorized rows of the
+// Analytic Data API. Uncategorized activities carry no information and are
+// skipped; for activities seen more than once, the latest row wins.
+func syncedProductivityRules(activities []AnalyticActivity) []ProductivityRule {
+	byPattern := make(map[string]ProductivityRule)
+	for _, activity := range activities {
+		pattern := normalizePattern(activity.Activity)
+		if pattern == "" || activity.Category == "" || strings.EqualFold(activity.Category, "Uncategorized") {
+			continue
+		}
+		byPattern[pattern] = ProductivityRule{
+			Pattern:  pattern,
+			Level:    max(levelVeryDistracting, min(levelVeryProductive, activity.Productivity)),
+			Category: activity.Category,
+			Source:   productivitySourceRescueTime,
+		}
+	}
+
+	rules := make([]ProductivityRule, 0, len(byPattern))
+	for _, rule := range byPattern {
+		rules = append(rules, rule)
+	}
+	// Longer patterns first, so "docs.google.com" is tried before "google.com"
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].Pattern) != len(rules[j].Pattern) {
+			return len(rules[i].Pattern) > len(rules[j].Pattern)
+		}
+		return rules[i].Pattern < rules[j].Pattern
+	})
+	return rules
+}
+
+// SetSynced replaces the rules synced from RescueTime and saves them to path
+func (m *ProductivityMap) SetSynced(rules []ProductivityRule, path string) error {
+	m.mu.Lock()
+	m.synced = rules
+	m.mu.Unlock()
+	if path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode productivity map: %v", err)
+	}
+	return writeFileAtomic(path, raw, 0600)
+}
+
+// ProductivityBreakdown is tracked time by productivity level and category
+type ProductivityBreakdown struct {
+	VeryProductive  time.Duration            `json:"very_productive"`
+	Productive      time.Duration            `json:"productive"`
+	Neutral         time.Duration            `json:"neutral"`
+	Distracting     time.Duration            `json:"distracting"`
+	VeryDistracting time.Duration            `json:"very_distracting"`
+	Unclassified    time.Duration            `json:"unclassified"`
+	Categories      map[string]time.Duration `json:"categories,omitempty"`
+}
+
+// Breakdown classifies summaries by application and latest window title
+func (m *ProductivityMap) Breakdown(summaries []ActivitySummary) ProductivityBreakdown {
+	breakdown := ProductivityBreakdown{Categories: make(map[string]time.Duration)}
+	for _, summary := range summaries {
+		rule, ok := m.ClassifyContext(summary.AppClass, summary.ActivityDetails, summary.SessionContext)
+		if !ok {
+			breakdown.Unclassified += summary.TotalDuration
+			breakdown.Categories["Uncategorized"] += summary.TotalDuration
+			continue
+		}
+		*breakdown.level(rule.Level) += summary.TotalDuration
+		if rule.Category != "" {
+			breakdown.Categories[rule.Category] += summary.TotalDuration
+		}
+	}
+	return breakdown
+}
+
+// level returns the field holding time at a productivity level
+func (b *ProductivityBreakdown) level(level int) *time.Duration {
+	switch {
+	case level >= levelVeryProductive:
+		return &b.VeryProductive
+	case level == levelProductive:
+		return &b.Productive
+	case level == levelDistracting:
+		return &b.Distracting
+	case level <= levelVeryDistracting:
+		return &b.VeryDistracting
+	}
+	return &b.Neutral
+}
+
+// ProductiveTime is the time spent productive or very productive
+func (b ProductivityBreakdown) ProductiveTime() time.Duration {
+	return b.VeryProductive + b.Productive
+}
+
+// DistractingTime is the time spent distracting or very distracting
+func (b ProductivityBreakdown) DistractingTime() time.Duration {
+	return b.Distracting + b.VeryDistracting
+}
+
+// Total is all classified and unclassified time
+func (b ProductivityBreakdown) Total() time.Duration {
+	return b.ProductiveTime() + b.Neutral + b.Unclassified + b.DistractingTime()
+}
+
+// Pulse is RescueTime's productivity pulse (0-100), counting unclassified
+// time as neutral like RescueTime does
+func (b ProductivityBreakdown) Pulse() int {
+	total := b.Total()
+	if total == 0 {
+		return 0
+	}
+	weighted := 100*b.VeryProductive + 75*b.Productive + 50*(b.Neutral+b.Unclassified) + 25*b.Distracting
+	return int((weighted + total/2) / total)
+}
+
+// writeBreakdown prints time per level and per category
+func writeBreakdown(out io.Writer, breakdown ProductivityBreakdown) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, row := range []struct {
+		label string
+		value time.Duration
+	}{
+		{"Very productive", breakdown.VeryProductive},
+		{"Productive", breakdown.Productive},
+		{"Neutral", breakdown.Neutral},
+		{"Distracting", breakdown.Distracting},
+		{"Very distracting", breakdown.VeryDistracting},
+		{"Unclassified", breakdown.Unclassified},
+	} {
+		fmt.Fprintf(w, "%s\t%v\n", row.label, row.value.Round(time.Minute))
+	}
+	w.Flush()
+
+	categories := make([]string, 0, len(breakdown.Categories))
+	for category := range breakdown.Categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return breakdown.Categories[categories[i]] > breakdown.Categories[categories[j]]
+	})
+	if len(categories) > 0 {
+		fmt.Fprintln(out)
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, category := range categories {
+			fmt.Fprintf(w, "%s\t%v\n", category, breakdown.Categories[category].Round(time.Minute))
+		}
+		w.Flush()
+	}
+}
+
+// runProductivity implements the productivity command
+func runProductivity(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: productivity show [-date YYYY-MM-DD] | productivity sync [-days 7]")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("productivity "+action, flag.ExitOnError)
+	date := fs.String("date", time.Now().Format(storeDateFormat), "Day to show (show only)")
+	days := fs.Int("days", 7, "Days of the Analytic Data API to learn from (sync only)")
+	localPath := fs.String("map", configPath("productivity"), "Local productivity map, one pattern, level and category per line")
+	fs.Parse(args[1:])
+
+	syncedPath := statePath("productivity.json")
+	classes, err := LoadProductivityMap(*localPath, syncedPath)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "show":
+		day, err := time.ParseInLocation(storeDateFormat, *date, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid -date: %v", err)
+		}
+		storeDir := statePath("activity")
+		if storeDir == "" {
+			return fmt.Errorf("no state directory to read tracked activity from")
+		}
+		summaries, err := NewActivityStore(storeDir).Day(day)
+		if err != nil {
+			return err
+		}
+		breakdown := classes.Breakdown(summaries)
+		fmt.Printf("%s: %v tracked, pulse %d\n\n", *date, breakdown.Total().Round(time.Minute), breakdown.Pulse())
+		writeBreakdown(os.Stdout, breakdown)
+		return nil
+
+	case "sync":
+		if *days < 1 {
+			return fmt.Errorf("-days must be at least 1")
+		}
+		client, err := newPublicAPIClient()
+		if err != nil {
+			return err
+		}
+		cacheDir := statePath("analytics")
+		if cacheDir == "" || syncedPath == "" {
+			return fmt.Errorf("no state directory for the analytics cache")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		today := time.Now()
+		to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+		from := to.AddDate(0, 0, 1-*days)
+		cache := NewAnalyticsCache(cacheDir)
+		if err := fetchAnalytics(ctx, client, cache, from, to, false); err != nil {
+			return err
+		}
+
+		var activities []AnalyticActivity
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			cached, err := cache.Load(day)
+			if err != nil {
+				return err
+			}
+			dayActivities, err := cached.Data.HourlyActivities()
+			if err != nil {
+				return fmt.Errorf("%s: %v", day.Format(storeDateFormat), err)
+			}
+			activities = append(activities, dayActivities...)
+		}
+
+		rules := syncedProductivityRules(activities)
+		if err := classes.SetSynced(rules, syncedPath); err != nil {
+			return err
+		}
+		fmt.Printf("Learned %d activities from RescueTime\n", len(rules))
+		return nil
+	}
+	return fmt.Errorf("unknown productivity action %q, use show or sync", action)
+}
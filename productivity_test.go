@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProductivityRules(t *testing.T) {
+	rules, err := parseProductivityRules(strings.NewReader(`
+# overrides
+Slack          productive  Communication & Scheduling
+news.ycombinator.com  -2
+jira.example.com very_productive Project Management
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProductivityRule{
+		{Pattern: "slack", Level: levelProductive, Category: "Communication & Scheduling", Source: productivitySourceLocal},
+		{Pattern: "news.ycombinator.com", Level: levelVeryDistracting, Source: productivitySourceLocal},
+		{Pattern: "jira.example.com", Level: levelVeryProductive, Category: "Project Management", Source: productivitySourceLocal},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("rules = %+v, want %+v", rules, want)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"slack", "slack 3", "slack sometimes"} {
+		if _, err := parseProductivityRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("rule %q accepted", bad)
+		}
+	}
+}
+
+func TestProductivityMapClassify(t *testing.T) {
+	classes := &ProductivityMap{
+		local:  []ProductivityRule{{Pattern: "slack", Level: levelProductive, Source: productivitySourceLocal}},
+		synced: []ProductivityRule{{Pattern: "linear.app", Level: levelVeryProductive, Category: "Project Management", Source: productivitySourceRescueTime}},
+	}
+	tests := []struct {
+		class, title string
+		level        int
+		source       string
+	}{
+		{"Slack", "general", levelProductive, productivitySourceLocal},                    // local overrides the default
+		{"firefox", "r/golang - Reddit", levelVeryDistracting, productivitySourceDefault}, // the site wins over the browser
+		{"firefox", "Learn to code - YouTube", levelVeryDistracting, productivitySourceDefault},
+		{"firefox", "Issue ENG-12 - linear.app", levelVeryProductive, productivitySourceRescueTime},
+		{"code-oss", "main.go - widget", levelVeryProductive, productivitySourceDefault},
+		{"kitty", "youtube-dl https://youtube.com/watch", levelVeryProductive, productivitySourceDefault}, // a terminal, not the site
+		{"code", "reddit-scraper – main.go", levelVeryProductive, productivitySourceDefault},
+		{"reddit.com", "r/golang - old.reddit.com", levelVeryDistracting, productivitySourceDefault}, // a tab tracked by domain
+	}
+	for _, tt := range tests {
+		rule, ok := classes.Classify(tt.class, tt.title)
+		if !ok || rule.Level != tt.level || rule.Source != tt.source {
+			t.Errorf("Classify(%q, %q) = %+v, %v, want level %d from %s", tt.class, tt.title, rule, ok, tt.level, tt.source)
+		}
+	}
+	if rule, ok := classes.Classify("firefox", "Example Domain"); ok {
+		t.Errorf("unknown window classified as %+v", rule)
+	}
+}
+
+func TestSyncedProductivityRulesRoundTrip(t *testing.T) {
+	rules := syncedProductivityRules([]AnalyticActivity{
+		{Activity: "google.com", Category: "Search", Productivity: 0},
+		{Activity: "docs.google.com", Category: "Writing", Productivity: 2},
+		{Activity: "mystery-app", Category: "Uncategorized", Productivity: 0},
+		{Activity: "Steam", Category: "Games", Productivity: -5},
+	})
+	if len(rules) != 3 || rules[0].Pattern != "docs.google.com" || rules[2] != (ProductivityRule{Pattern: "steam", Level: levelVeryDistracting, Category: "Games", Source: productivitySourceRescueTime}) {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	path := filepath.Join(t.TempDir(), "productivity.json")
+	classes := &ProductivityMap{}
+	if err := classes.SetSynced(rules, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadProductivityMap(filepath.Join(t.TempDir(), "missing"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule, ok := loaded.Classify("firefox", "Spec draft - docs.google.com"); !ok || rule.Category != "Writing" {
+		t.Errorf("synced rule not applied after reload: %+v, %v", rule, ok)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProductivityMap("", path); err == nil {
+		t.Error("corrupt synced map accepted")
+	}
+}
+
+func TestProductivityBreakdown(t *testing.T) {
+	breakdown := (*ProductivityMap)(nil).Breakdown([]ActivitySummary{
+		{AppClass: "kitty", TotalDuration: 3 * time.Hour},
+		{AppClass: "firefox", ActivityDetails: "Wikipedia", TotalDuration: time.Hour},
+		{AppClass: "discord", TotalDuration: 30 * time.Minute},
+		{AppClass: "firefox", ActivityDetails: "YouTube", TotalDuration: 30 * time.Minute},
+		{AppClass: "mystery", TotalDuration: time.Hour},
+	})
+	if breakdown.ProductiveTime() != 4*time.Hour || breakdown.DistractingTime() != time.Hour ||
+		breakdown.Unclassified != time.Hour || breakdown.Total() != 6*time.Hour {
+		t.Errorf("breakdown = %+v", breakdown)
+	}
+	if got := breakdown.Categories["Software Development"]; got != 3*time.Hour {
+		t.Errorf("Software Development = %v, want 3h", got)
+	}
+	// (3h*100 + 1h*75 + 1h*50 + 30m*25 + 30m*0) / 6h
+	if got := breakdown.Pulse(); got != 73 {
+		t.Errorf("pulse = %d, want 73", got)
+	}
+}
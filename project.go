@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// projectRepoCacheSize bounds the directories whose git repository is remembered
+const projectRepoCacheSize = 1024
+
+// vimTitlePattern matches the default Vim and Neovim titlestring,
+// "file.go + (~/src/widget/cmd) - NVIM"
+var vimTitlePattern = regexp.MustCompile(`^.* \(([~/][^()]*)\)(?: \(\d+ of \d+\))? - (?i:n?vim)$`)
+
+// jetbrainsPathPattern matches the project path older JetBrains IDEs show,
+// "billing [~/src/billing] – Main.kt"
+var jetbrainsPathPattern = regexp.MustCompile(`^[^[]* \[([~/][^\]]*)\] – `)
+
+// vscodeApps are the application names VS Code and its forks end titles with
+var vscodeApps = []string{"Visual Studio Code", "Code - OSS", "VSCodium", "Cursor", "Windsurf"}
+
+// editorClasses are window classes, matched as prefixes, of editors whose
+// process working directory is looked up when the title names no directory
+var editorClasses = []string{
+	"jetbrains-", "code", "vscodium", "cursor", "windsurf", "emacs", "neovide", "gvim", "dev.zed.zed", "sublime_text", "org.kde.kate",
+}
+
+// isEditor reports whether a window class belongs to an editor or IDE
+func isEditor(class string) bool {
+	class = strings.ToLower(class)
+	for _, name := range editorClasses {
+		if strings.HasPrefix(class, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProjectResolver names the project a window belongs to from editor title
+// conventions and working directories, and the git repository, branch and
+// ticket worked on
+type ProjectResolver struct {
+	ProcRoot string // procfs mount for editor working directories, /proc if empty
+
+	mu    sync.Mutex
+	repos map[string]gitWorkTree // directory to its repository, zero if there is none
+}
+
+// Resolve returns the project of a window, or "" if it can't be told
+func (r *ProjectResolver) Resolve(class, title string, sessionContext SessionContext) string {
+	return r.Context(&HyprlandWindow{Class: class, Title: title}, sessionContext).Project
+}
+
+// Context adds the project and git details of window to sessionContext.
+// Editors name their project in the title; otherwise the project is the
+// nearest git repository above the directory worked in: the one shown in a
+// Vim or JetBrains title, the terminal's or else the editor process's
+// working directory. A project named in the title may differ from the
+// repository's directory name, as with .idea/.name or VS Code workspaces;
+// the repository still counts unless it was only guessed from the editor
+// process. The branch is read from HEAD on every call, so switching
+// branches is noticed without running git.
+func (r *ProjectResolver) Context(window *HyprlandWindow, sessionContext SessionContext) SessionContext {
+	if r == nil || window == nil {
+		return sessionContext
+	}
+
+	dir := sessionContext.Directory
+	fromProcess := false
+	if match := vimTitlePattern.FindStringSubmatch(window.Title); match != nil {
+		dir = expandHome(match[1])
+	} else if match := jetbrainsPathPattern.FindStringSubmatch(window.Title); match != nil && strings.HasPrefix(strings.ToLower(window.Class), "jetbrains-") {
+		dir = expandHome(match[1])
+	} else if dir == "" && window.Pid > 0 && isEditor(window.Class) {
+		root := r.ProcRoot
+		if root == "" {
+			root = "/proc"
+		}
+		dir, _ = os.Readlink(filepath.Join(root, strconv.Itoa(window.Pid), "cwd"))
+		fromProcess = true
+	}
+	repo, ok := r.repo(dir)
+
+	sessionContext.Project = projectFromTitle(window.Class, window.Title)
+	if !ok {
+		return sessionContext
+	}
+	name := filepath.Base(repo.Root)
+	if sessionContext.Project == "" {
+		sessionContext.Project = name
+	} else if fromProcess && sessionContext.Project != name {
+		return sessionContext // the editor has another project open than it was started in
+	}
+	sessionContext.Repo = name
+	sessionContext.Branch = repo.Branch()
+	sessionContext.Ticket = ticketFromBranch(sessionContext.Branch)
+	return sessionContext
+}
+
+// projectFromTitle reads the project name of JetBrains IDEs ("project –
+// file", older versions "project [~/path] – file") and VS Code ("file -
+// project - Visual Studio Code") from a window title
+func projectFromTitle(class, title string) string {
+	class = strings.ToLower(class)
+	switch {
+	case strings.HasPrefix(class, "jetbrains-"):
+		project, _, ok := strings.Cut(title, " – ")
+		if !ok {
+			return ""
+		}
+		if open := strings.Index(project, " ["); open > 0 && strings.HasSuffix(project, "]") {
+			project = project[:open]
+		}
+		return strings.TrimSpace(project)
+
+	case strings.Contains(class, "code") || strings.Contains(class, "codium") || class == "cursor" || class == "windsurf":
+		for _, app := range vscodeApps {
+			rest, ok := strings.CutSuffix(title, " - "+app)
+			if !ok {
+				continue
+			}
+			parts := strings.Split(rest, " - ")
+			if len(parts) < 2 {
+				return "" // no folder open, or only the workspace name
+			}
+			return strings.TrimSuffix(strings.TrimSpace(parts[len(parts)-1]), " (Workspace)")
+		}
+	}
+	return ""
+}
+
+// repo returns the git repository dir is in
+func (r *ProjectResolver) repo(dir string) (gitWorkTree, bool) {
+	if dir == "" || !filepath.IsAbs(dir) {
+		return gitWorkTree{}, false
+	}
+	dir = filepath.Clean(dir)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	repo, ok := r.repos[dir]
+	if !ok {
+		if r.repos == nil || len(r.repos) >= projectRepoCacheSize {
+			r.repos = make(map[string]gitWorkTree)
+		}
+		repo, _ = findGitWorkTree(dir)
+		r.repos[dir] = repo
+	}
+	return repo, repo.Root != ""
+}
+
+// summaryGroups are the fields the projects command can total time by
+var summaryGroups = map[string]func(ActivitySummary) string{
+	"project": func(s ActivitySummary) string { return s.Project },
+	"repo":    func(s ActivitySummary) string { return s.Repo },
+	"branch": func(s ActivitySummary) string {
+		if s.Branch == "" {
+			return ""
+		}
+		return s.Repo + "@" + s.Branch
+	},
+	"ticket":    func(s ActivitySummary) string { return s.Ticket },
+	"workspace": func(s ActivitySummary) string { return s.Workspace },
+	"monitor": func(s ActivitySummary) string {
+		if s.Workspace == "" {
+			return "" // tracked without window placement
+		}
+		return strconv.Itoa(s.Monitor)
+	},
+}
+
+// GroupTime is the time tracked on one project, repository, branch, ticket,
+// workspace or monitor
+type GroupTime struct {
+	Name  string
+	Total time.Duration
+	Focus time.Duration
+	Apps  map[string]time.Duration
+}
+
+// groupSummaries totals summaries by the name group returns, largest
+// first; time without one is left out
+func groupSummaries(summaries []ActivitySummary, group func(ActivitySummary) string) []GroupTime {
+	byName := make(map[string]*GroupTime)
+	for _, summary := range summaries {
+		name := group(summary)
+		if name == "" {
+			continue
+		}
+		total, ok := byName[name]
+		if !ok {
+			total = &GroupTime{Name: name, Apps: make(map[string]time.Duration)}
+			byName[name] = total
+		}
+		total.Total += summary.TotalDuration
+		total.Focus += summary.FocusDuration
+		total.Apps[summary.AppClass] += summary.TotalDuration
+	}
+
+	groups := make([]GroupTime, 0, len(byName))
+	for _, total := range byName {
+		groups = append(groups, *total)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Total != groups[j].Total {
+			return groups[i].Total > groups[j].Total
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// groupByProject totals summaries per project
+func groupByProject(summaries []ActivitySummary) []GroupTime {
+	return groupSummaries(summaries, summaryGroups["project"])
+}
+
+// writeGroups prints the time per group with its applications under the
+// heading given
+func writeGroups(out io.Writer, heading string, groups []GroupTime) {
+	if len(groups) == 0 {
+		fmt.Fprintf(out, "No time tracked by %s.\n", strings.ToLower(heading))
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tTime\tFocus\tApplications\n", heading)
+	for _, group := range groups {
+		apps := make([]string, 0, len(group.Apps))
+		for app := range group.Apps {
+			apps = append(apps, app)
+		}
+		sort.Slice(apps, func(i, j int) bool { return group.Apps[apps[i]] > group.Apps[apps[j]] })
+		fmt.Fprintf(w, "%s\t%v\t%v\t%s\n", group.Name, group.Total.Round(time.Minute),
+			group.Focus.Round(time.Minute), strings.Join(apps, ", "))
+	}
+	w.Flush()
+}
+
+// withProjectDetails replaces the activity details of summaries that have
+// a project with the project name, so RescueTime reports group by project
+func withProjectDetails(summaries map[string]ActivitySummary) map[string]ActivitySummary {
+	for key, summary := range summaries {
+		if summary.Project != "" {
+			summary.ActivityDetails = summary.Project
+			summaries[key] = summary
+		}
+	}
+	return summaries
+}
+
+// storedSummaries reads the local activity store for each day from from to
+// to (inclusive)
+func storedSummaries(from, to time.Time) ([]ActivitySummary, error) {
+	storeDir := statePath("activity")
+	if storeDir == "" {
+		return nil, fmt.Errorf("no state directory to read tracked activity from")
+	}
+	store := NewActivityStore(storeDir)
+
+	var summaries []ActivitySummary
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		daySummaries, err := store.Day(day)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, daySummaries...)
+	}
+	return summaries, nil
+}
+
+// runProjects implements the projects command: time per project, repository,
+// branch, ticket, workspace or monitor from the local activity store
+func runProjects(args []string) error {
+	fs := flag.NewFlagSet("projects", flag.ExitOnError)
+	today := time.Now().Format(storeDateFormat)
+	fromFlag := fs.String("from", today, "First day to report (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Last day to report (YYYY-MM-DD, default -from)")
+	by := fs.String("by", "project", "Total time by project, repo, branch, ticket, workspace or monitor")
+	fs.Parse(args)
+
+	group, ok := summaryGroups[*by]
+	if !ok {
+		return fmt.Errorf("invalid -by %q, use project, repo, branch, ticket, workspace or monitor", *by)
+	}
+	from, to, err := parseDateRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+	summaries, err := storedSummaries(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Time per %s, %s to %s\n", *by, from.Format(storeDateFormat), to.Format(storeDateFormat))
+	writeGroups(os.Stdout, strings.ToUpper((*by)[:1])+(*by)[1:], groupSummaries(summaries, group))
+	return nil
+}
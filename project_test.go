@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProjectFromTitle(t *testing.T) {
+	tests := []struct {
+		class, title string
+		want         string
+	}{
+		{"jetbrains-phpstorm", "can-eye-budget – README.md", "can-eye-budget"},
+		{"jetbrains-idea", "billing [~/src/billing] – …/src/Main.kt", "billing"},
+		{"jetbrains-goland", "Welcome to GoLand", ""},
+		{"code", "● main.go - widget - Visual Studio Code", "widget"},
+		{"Code", "settings.json - infra (Workspace) - Visual Studio Code", "infra"},
+		{"VSCodium", "README.md - notes - VSCodium", "notes"},
+		{"code", "Welcome - Visual Studio Code", ""},
+		{"firefox", "widget – README.md", ""},
+	}
+	for _, tt := range tests {
+		if got := projectFromTitle(tt.class, tt.title); got != tt.want {
+			t.Errorf("projectFromTitle(%s, %q) = %q, want %q", tt.class, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestProjectResolverUsesGitRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	widget := filepath.Join(home, "src", "widget")
+	for _, dir := range []string{filepath.Join(widget, ".git"), filepath.Join(widget, "cmd", "server"), filepath.Join(home, "notes")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolver := &ProjectResolver{}
+	tests := []struct {
+		class, title string
+		context      SessionContext
+		want         string
+	}{
+		{"kitty", "make test", SessionContext{Process: "make", Directory: filepath.Join(widget, "cmd", "server")}, "widget"},
+		{"kitty", "main.go + (~/src/widget/cmd) - NVIM", SessionContext{Process: "nvim", Directory: home}, "widget"},
+		{"foot", "main.go (~/src/widget/cmd) (2 of 3) - VIM", SessionContext{}, "widget"},
+		{"kitty", "zsh", SessionContext{Directory: filepath.Join(home, "notes")}, ""},
+		{"jetbrains-goland", "billing – main.go", SessionContext{Directory: widget}, "billing"},
+	}
+	for _, tt := range tests {
+		if got := resolver.Resolve(tt.class, tt.title, tt.context); got != tt.want {
+			t.Errorf("Resolve(%s, %q, %+v) = %q, want %q", tt.class, tt.title, tt.context, got, tt.want)
+		}
+	}
+
+	// Roots are cached per directory
+	if err := os.RemoveAll(filepath.Join(widget, ".git")); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolver.Resolve("kitty", "make", SessionContext{Directory: filepath.Join(widget, "cmd", "server")}); got != "widget" {
+		t.Errorf("cached root = %q", got)
+	}
+	var none *ProjectResolver
+	if got := none.Resolve("jetbrains-goland", "billing – main.go", SessionContext{}); got != "" {
+		t.Errorf("nil resolver = %q", got)
+	}
+}
+
+func TestProjectContextKeepsRenamedRepos(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	widget := fakeCheckout(t, filepath.Join(home, "src"), "widget", "feature/abc-12-login")
+	proc := newFakeProc(t)
+	proc.add(400, "code", 1, 400, 0, 0, 1, widget)
+	resolver := &ProjectResolver{ProcRoot: proc.root}
+
+	tests := []struct {
+		window  *HyprlandWindow
+		context SessionContext
+		want    SessionContext
+	}{
+		// .idea/.name renames the project shown in the title
+		{&HyprlandWindow{Class: "jetbrains-goland", Title: "Widget Service [~/src/widget] – main.go"}, SessionContext{},
+			SessionContext{Project: "Widget Service", Repo: "widget", Branch: "feature/abc-12-login", Ticket: "ABC-12"}},
+		{&HyprlandWindow{Class: "jetbrains-goland", Title: "Widget Service – main.go"}, SessionContext{Directory: widget},
+			SessionContext{Directory: widget, Project: "Widget Service", Repo: "widget", Branch: "feature/abc-12-login", Ticket: "ABC-12"}},
+		// The editor may have opened another folder than it was started in
+		{&HyprlandWindow{Class: "code", Title: "main.go - billing - Visual Studio Code", Pid: 400}, SessionContext{},
+			SessionContext{Project: "billing"}},
+		{&HyprlandWindow{Class: "code", Title: "main.go - widget - Visual Studio Code", Pid: 400}, SessionContext{},
+			SessionContext{Project: "widget", Repo: "widget", Branch: "feature/abc-12-login", Ticket: "ABC-12"}},
+	}
+	for _, tt := range tests {
+		if got := resolver.Context(tt.window, tt.context); got != tt.want {
+			t.Errorf("Context(%q) = %+v, want %+v", tt.window.Title, got, tt.want)
+		}
+	}
+}
+
+func TestTrackerSplitsSummariesByProject(t *testing.T) {
+	clock := NewManualClock(testStart)
+	tracker := NewActivityTrackerWithClock(clock)
+	tracker.StartSessionWith("jetbrains-goland", "billing – main.go", SessionContext{Project: "billing"})
+	clock.Advance(30 * time.Minute)
+	tracker.StartSessionWith("jetbrains-goland", "widget – main.go", SessionContext{Project: "widget"})
+	clock.Advance(20 * time.Minute)
+	// Back within the merge threshold, but on another project
+	tracker.StartSessionWith("jetbrains-goland", "billing – api.go", SessionContext{Project: "billing"})
+	clock.Advance(10 * time.Minute)
+	tracker.StartSession("firefox", "Docs")
+	clock.Advance(5 * time.Minute)
+	tracker.EndCurrentSession()
+
+	summaries := tracker.GetActivitySummaries()
+	if got := summaries["jetbrains-goland [billing]"]; got.TotalDuration != 40*time.Minute || got.SessionCount != 2 || got.ActivityDetails != "billing – api.go" {
+		t.Errorf("billing = %+v", got)
+	}
+	if got := summaries["jetbrains-goland [widget]"]; got.TotalDuration != 20*time.Minute {
+		t.Errorf("widget = %+v", got)
+	}
+
+	var all []ActivitySummary
+	for _, summary := range summaries {
+		all = append(all, summary)
+	}
+	projects := groupByProject(all)
+	if len(projects) != 2 || projects[0].Name != "billing" || projects[0].Total != 40*time.Minute || projects[1].Name != "widget" {
+		t.Errorf("projects = %+v", projects)
+	}
+	var out bytes.Buffer
+	writeGroups(&out, "Project", projects)
+	if !strings.Contains(out.String(), "billing  40m0s") {
+		t.Errorf("report:\n%s", out.String())
+	}
+
+	submitted := withProjectDetails(summaries)
+	if submitted["jetbrains-goland [widget]"].ActivityDetails != "widget" || submitted["firefox"].ActivityDetails != "Docs" {
+		t.Errorf("project details = %+v", submitted)
+	}
+}
+
+func TestMonitorRecordsProject(t *testing.T) {
+	clock := NewManualClock(testStart)
+	monitor := &Monitor{Clock: clock, Out: io.Discard, Projects: &ProjectResolver{}}
+	monitor.HandleWindow(&HyprlandWindow{Class: "jetbrains-phpstorm", Title: "can-eye-budget – README.md"})
+	if session, ok := monitor.Tracker.Current(); !ok || session.Project != "can-eye-budget" {
+		t.Errorf("current session = %+v", session)
+	}
+}
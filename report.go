@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// splitByHour calls fn with each clock hour (in local time) the range from
+// start to end overlaps and the time spent in it
+func splitByHour(start, end time.Time, fn func(hour time.Time, d time.Duration)) {
+	for start.Before(end) {
+		local := start.Local()
+		hour := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, time.Local)
+		next := hour.Add(time.Hour)
+		if next.After(end) {
+			next = end
+		}
+		fn(hour, next.Sub(start))
+		start = next
+	}
+}
+
+// activityKey matches local application names with RescueTime activity
+// names, which differ in case
+func activityKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Discrepancy compares local and RescueTime time for an application or an hour
+type Discrepancy struct {
+	Label      string
+	Local      time.Duration
+	RescueTime time.Duration
+}
+
+// Diff is how much more RescueTime recorded than was tracked locally
+func (d Discrepancy) Diff() time.Duration {
+	return d.RescueTime - d.Local
+}
+
+// Comparison is local tracking and RescueTime data for a date range, per
+// application and per hour
+type Comparison struct {
+	Apps  []Discrepancy // sorted by the larger of the two times
+	Hours []Discrepancy // in order, only hours with data
+}
+
+// compareActivity builds a comparison from the submittable local summaries
+// and the hourly RescueTime report for the same days. Terminal sub-activities
+// count towards the terminal, which is all RescueTime knows of them.
+func compareActivity(local []ActivitySummary, remote []AnalyticActivity) Comparison {
+	apps := make(map[string]*Discrepancy)
+	hours := make(map[time.Time]*Discrepancy)
+	app := func(name string) *Discrepancy {
+		key := activityKey(name)
+		if apps[key] == nil {
+			apps[key] = &Discrepancy{Label: name}
+		}
+		return apps[key]
+	}
+	hour := func(t time.Time) *Discrepancy {
+		if hours[t] == nil {
+			hours[t] = &Discrepancy{Label: t.Format("2006-01-02 15:04")}
+		}
+		return hours[t]
+	}
+
+	for _, summary := range local {
+		if !shouldSubmit(summary) {
+			continue // never sent, RescueTime can't have it
+		}
+		app(baseClass(summary.AppClass)).Local += summary.TotalDuration
+		splitByHour(summary.FirstSeen, summary.FirstSeen.Add(summary.TotalDuration), func(t time.Time, d time.Duration) {
+			hour(t).Local += d
+		})
+	}
+	for _, activity := range remote {
+		app(activity.Activity).RescueTime += activity.Duration
+		hour(activity.Hour).RescueTime += activity.Duration
+	}
+
+	var comparison Comparison
+	for _, d := range apps {
+		comparison.Apps = append(comparison.Apps, *d)
+	}
+	sort.Slice(comparison.Apps, func(i, j int) bool {
+		a, b := comparison.Apps[i], comparison.Apps[j]
+		if max(a.Local, a.RescueTime) != max(b.Local, b.RescueTime) {
+			return max(a.Local, a.RescueTime) > max(b.Local, b.RescueTime)
+		}
+		return a.Label < b.Label
+	})
+
+	times := make([]time.Time, 0, len(hours))
+	for t := range hours {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for _, t := range times {
+		comparison.Hours = append(comparison.Hours, *hours[t])
+	}
+	return comparison
+}
+
+// writeDiscrepancies prints a table, marking rows that differ by at least threshold
+func writeDiscrepancies(out io.Writer, title string, rows []Discrepancy, threshold time.Duration) {
+	fmt.Fprintf(out, "\n%s\n", title)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tLOCAL\tRESCUETIME\tDIFF\t")
+	for _, row := range rows {
+		mark := ""
+		if diff := row.Diff(); diff >= threshold || -diff >= threshold {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%s\t%v\t%v\t%+.0fm\t%s\n", row.Label,
+			row.Local.Round(time.Minute), row.RescueTime.Round(time.Minute), row.Diff().Minutes(), mark)
+	}
+	w.Flush()
+}
+
+// runReport implements the report command: it compares the local activity
+// store with the RescueTime data cached by fetch
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	today := time.Now().Format(storeDateFormat)
+	fromFlag := fs.String("from", today, "First day to report (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Last day to report (YYYY-MM-DD, default -from)")
+	threshold := fs.Duration("threshold", 5*time.Minute, "Mark applications and hours that differ by at least this much")
+	fs.Parse(args)
+
+	from, to, err := parseDateRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+	storeDir, cacheDir := statePath("activity"), statePath("analytics")
+	if storeDir == "" || cacheDir == "" {
+		return fmt.Errorf("no state directory")
+	}
+
+	comparison, err := loadComparison(NewActivityStore(storeDir), NewAnalyticsCache(cacheDir), from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Local tracking vs RescueTime, %s to %s (! differs by %v or more)\n",
+		from.Format(storeDateFormat), to.Format(storeDateFormat), *threshold)
+	writeDiscrepancies(os.Stdout, "Per application", comparison.Apps, *threshold)
+	writeDiscrepancies(os.Stdout, "Per hour", comparison.Hours, *threshold)
+	return nil
+}
+
+// loadComparison reads the local store and the cached RescueTime data for
+// each day from from to to (inclusive)
+func loadComparison(store *ActivityStore, cache *AnalyticsCache, from, to time.Time) (Comparison, error) {
+	var local []ActivitySummary
+	var remote []AnalyticActivity
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		summaries, err := store.Day(day)
+		if err != nil {
+			return Comparison{}, err
+		}
+		local = append(local, summaries...)
+
+		cached, err := cache.Load(day)
+		if errors.Is(err, os.ErrNotExist) {
+			return Comparison{}, fmt.Errorf("no RescueTime data for %s, run: active-window fetch -from %s -to %s",
+				day.Format(storeDateFormat), from.Format(storeDateFormat), to.Format(storeDateFormat))
+		}
+		if err != nil {
+			return Comparison{}, err
+		}
+		activities, err := cached.Data.HourlyActivities()
+		if err != nil {
+			return Comparison{}, fmt.Errorf("%s: %v", day.Format(storeDateFormat), err)
+		}
+		remote = append(remote, activities...)
+	}
+	return compareActivity(local, remote), nil
+}
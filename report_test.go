@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareActivityUsesBaseClass(t *testing.T) {
+	hour := time.Date(2025, 10, 2, 9, 0, 0, 0, time.Local)
+	local := []ActivitySummary{
+		{AppClass: "kitty" + subActivitySeparator + "nvim", FirstSeen: hour, TotalDuration: 30 * time.Minute},
+		{AppClass: "kitty", FirstSeen: hour.Add(30 * time.Minute), TotalDuration: 10 * time.Minute},
+		{AppClass: "firefox", FirstSeen: hour.Add(40 * time.Minute), TotalDuration: 20 * time.Minute},
+		{AppClass: "kitty" + subActivitySeparator + "htop", FirstSeen: hour, TotalDuration: 30 * time.Second},
+	}
+	remote := []AnalyticActivity{
+		{Hour: hour, Activity: "Kitty", Duration: 40 * time.Minute},
+		{Hour: hour, Activity: "firefox", Duration: 20 * time.Minute},
+	}
+
+	comparison := compareActivity(local, remote)
+	if len(comparison.Apps) != 2 {
+		t.Fatalf("apps = %+v", comparison.Apps)
+	}
+	for _, app := range comparison.Apps {
+		if app.Diff() != 0 {
+			t.Errorf("%s differs by %v", app.Label, app.Diff())
+		}
+	}
+	if len(comparison.Hours) != 1 || comparison.Hours[0].Diff() != 0 {
+		t.Errorf("hours = %+v", comparison.Hours)
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// userAgent matches the official desktop client
+const userAgent = "RescueTime/2.16.5.1 (Linux)"
+
+// RescueTimeClient talks to the RescueTime APIs over a single tuned HTTP
+// client. Every call takes a context so shutdown can abort requests and backoff.
+type RescueTimeClient struct {
+	HTTP        *http.Client
+	Endpoints   Endpoints
+	APIKey      string // public API key (offline_time_post)
+	AccountKey  string // from activation, native API query auth
+	DataKey     string // from activation, native API Bearer auth
+	MaxRetries  int
+	MaxBatch    int             // events per bulk user_client_events request, lowered by Auth after a 413
+	Concurrency int             // requests in flight when events are posted individually
+	Governor    *Governor       // rate limit, backoff and circuit breaker shared by all requests
+	Auth        *AuthNegotiator // remembers the working auth scheme and bulk support
+}
+
+// NewRescueTimeClient creates a client with a shared, keep-alive transport
+func NewRescueTimeClient(endpoints Endpoints, apiKey, accountKey, dataKey string) *RescueTimeClient {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &RescueTimeClient{
+		HTTP:        &http.Client{Transport: transport, Timeout: 10 * time.Second},
+		Endpoints:   endpoints,
+		APIKey:      apiKey,
+		AccountKey:  accountKey,
+		DataKey:     dataKey,
+		MaxRetries:  3,
+		MaxBatch:    50,
+		Concurrency: 4,
+		Governor:    NewGovernor(realClock{}),
+		Auth:        &AuthNegotiator{},
+	}
+}
+
+// HasNativeCredentials reports whether activation keys are available for the native API
+func (c *RescueTimeClient) HasNativeCredentials() bool {
+	return c.DataKey != "" || c.AccountKey != ""
+}
+
+// APIError is a non-2xx response from RescueTime
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// isPermanent reports whether retrying the request later can't succeed.
+// Authentication and rate-limit errors are not permanent: credentials can be fixed
+// and limits reset.
+func (e *APIError) isPermanent() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// retryable reports whether the same request may succeed if sent again shortly
+func (e *APIError) retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// isRetryable reports whether a failed request should be retried: network
+// errors and overloaded or failing servers, but not other client errors
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.retryable()
+	}
+	return !errors.Is(err, errSubmissionsPaused)
+}
+
+// isPermanentError reports whether err is a client error that will never succeed on retry
+func isPermanentError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.isPermanent()
+}
+
+// sleepContext waits for d, returning early with the context's error if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff waits before retry attempt n with jittered exponential delay,
+// aborting on cancellation. When the server sent Retry-After the governor
+// already enforces that pause, so no extra delay is added.
+func (c *RescueTimeClient) backoff(ctx context.Context, api string, attempt int, lastErr error) error {
+	var apiErr *APIError
+	if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 {
+		slog.Info("retrying submission", "api", api, "retry_after", apiErr.RetryAfter, "attempt", attempt+1, "max_attempts", c.MaxRetries)
+		return nil
+	}
+	delay := c.Governor.Backoff(attempt)
+	slog.Info("retrying submission", "api", api, "delay", delay, "attempt", attempt+1, "max_attempts", c.MaxRetries)
+	return sleepContext(ctx, delay)
+}
+
+// send waits for the governor, sends the request and reports the outcome back
+// to it. Only failures that indicate the API is unavailable count towards
+// the circuit breaker.
+func (c *RescueTimeClient) send(ctx context.Context, req *http.Request) ([]byte, error) {
+	if err := c.Governor.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	body, err := c.do(req)
+
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		c.Governor.Success()
+	case errors.As(err, &apiErr):
+		if apiErr.RetryAfter > 0 {
+			c.Governor.RetryAfter(apiErr.RetryAfter)
+		}
+		if apiErr.retryable() {
+			c.Governor.Failure()
+		} else {
+			c.Governor.Success() // the server is up, it just didn't like this request
+		}
+	case ctx.Err() != nil:
+		c.Governor.Abandon()
+	default:
+		c.Governor.Failure()
+	}
+	return body, err
+}
+
+// do sends a request and returns the response body, turning non-2xx statuses into *APIError
+func (c *RescueTimeClient) do(req *http.Request) ([]byte, error) {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return body, nil
+}
+
+// Activate authenticates with RescueTime and retrieves account keys
+func (c *RescueTimeClient) Activate(ctx context.Context, email, password string) (*ActivationResponse, error) {
+	// Discovered through testing: endpoint uses form-encoded data with username/password fields
+	url := c.Endpoints.Activate()
+
+	// Create form-encoded payload
+	formData := fmt.Sprintf("username=%s&password=%s",
+		strings.ReplaceAll(email, "@", "%40"), // URL encode @ sign
+		password)
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(formData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	// Send request
+	body, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for error in response
+	// Response format is YAML-like: "c:\n- 0\n- RT:ok\naccount_key: xxx\nkey: xxx"
+	bodyStr := string(body)
+	if strings.Contains(bodyStr, "RT:error") {
+		return nil, fmt.Errorf("activation failed: %s", bodyStr)
+	}
+
+	// Parse response to extract account_key
+	// TODO: The response only contains account_key, not data_key
+	// We need to discover how to obtain the data_key (separate endpoint? different auth flow?)
+	var accountKey string
+	for _, line := range strings.Split(bodyStr, "\n") {
+		if strings.HasPrefix(line, "account_key:") {
+			accountKey = strings.TrimSpace(strings.TrimPrefix(line, "account_key:"))
+			break
+		}
+	}
+
+	if accountKey == "" {
+		return nil, fmt.Errorf("no account_key in response: %s", bodyStr)
+	}
+
+	// Return response with account_key
+	// Note: data_key is empty - needs further investigation
+	return &ActivationResponse{
+		AccountKey: accountKey,
+		DataKey:    "", // TODO: Discover how to obtain data_key
+		ApiURL:     "api.rescuetime.com",
+		URL:        "www.rescuetime.com",
+	}, nil
+}
+
+// sendWithRetry sends the request built by newRequest, retrying network
+// errors, 5xx, 408 and 429 with backoff, and returns the response body
+func (c *RescueTimeClient) sendWithRetry(ctx context.Context, api string, newRequest func() (*http.Request, error)) ([]byte, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := c.backoff(ctx, api, attempt, lastErr); err != nil {
+				return nil, fmt.Errorf("submission cancelled: %w (last error: %v)", err, lastErr)
+			}
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := c.send(ctx, req)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		// Don't retry on client errors (4xx other than 408/429), while paused or once we're shutting down
+		if !isRetryable(err) || ctx.Err() != nil {
+			return nil, lastErr
+		}
+	}
+
+	return nil, fmt.Errorf("failed after %d attempts: %w", c.MaxRetries, lastErr)
+}
+
+// SubmitOfflineTime submits activity data to RescueTime API with retry logic (legacy offline time API)
+func (c *RescueTimeClient) SubmitOfflineTime(ctx context.Context, payload RescueTimePayload) error {
+	return c.submitOfflineTime(ctx, payload, "")
+}
+
+// submitOfflineTime is SubmitOfflineTime with an optional Idempotency-Key,
+// so a retried request can't be counted twice
+func (c *RescueTimeClient) submitOfflineTime(ctx context.Context, payload RescueTimePayload, idempotencyKey string) error {
+	// Convert payload to JSON
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	_, err = c.sendWithRetry(ctx, "offline_time_post", func() (*http.Request, error) {
+		url := fmt.Sprintf("%s?key=%s", c.Endpoints.OfflineTimePost(), c.APIKey)
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("submitted activity", "api", "offline_time_post", "activity", payload.ActivityName, "duration_min", payload.Duration)
+	return nil
+}
+
+// newEventRequest builds a POST to user_client_events authenticated with scheme
+func (c *RescueTimeClient) newEventRequest(ctx context.Context, scheme AuthScheme, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoints.UserClientEvents(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	switch scheme {
+	case AuthBearer:
+		// The desktop app uses the data_key as the Bearer token, with
+		// the account_key as a query parameter alongside it
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.DataKey))
+		if c.AccountKey != "" {
+			req.URL.RawQuery = fmt.Sprintf("key=%s", c.AccountKey)
+		}
+	case AuthQuery:
+		req.URL.RawQuery = fmt.Sprintf("key=%s", c.AccountKey)
+	default:
+		return nil, fmt.Errorf("unsupported auth scheme for user_client_events: %q", scheme)
+	}
+
+	// Set headers matching the official app
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", userAgent)
+	return req, nil
+}
+
+// SubmitUserClientEvent submits activity data to native RescueTime user_client_events API
+// using the given authentication scheme (AuthBearer or AuthQuery)
+func (c *RescueTimeClient) SubmitUserClientEvent(ctx context.Context, scheme AuthScheme, payload UserClientEventPayload) error {
+	// Convert payload to JSON
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	_, err = c.sendWithRetry(ctx, "user_client_events", func() (*http.Request, error) {
+		req, err := c.newEventRequest(ctx, scheme, jsonData)
+		if err == nil && payload.UserClientEvent.ClientEventID != "" {
+			req.Header.Set("Idempotency-Key", payload.UserClientEvent.ClientEventID)
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("submitted activity",
+		"api", "user_client_events",
+		"auth", scheme,
+		"activity", payload.UserClientEvent.Application,
+		"start", payload.UserClientEvent.StartTime,
+		"end", payload.UserClientEvent.EndTime)
+	return nil
+}
+
+// isUnauthorized reports whether err is a 401 response
+func isUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// AuthSchemes returns the schemes the configured keys allow, in negotiation order
+func (c *RescueTimeClient) AuthSchemes() []AuthScheme {
+	var schemes []AuthScheme
+	if c.DataKey != "" {
+		schemes = append(schemes, AuthBearer)
+	}
+	if c.AccountKey != "" {
+		schemes = append(schemes, AuthQuery)
+	}
+	if c.APIKey != "" {
+		schemes = append(schemes, AuthLegacy)
+	}
+	return schemes
+}
+
+// submitWith submits one summary with a single authentication scheme
+func (c *RescueTimeClient) submitWith(ctx context.Context, scheme AuthScheme, summary ActivitySummary) error {
+	if scheme == AuthLegacy {
+		return c.submitOfflineTime(ctx, summaryToPayload(summary), summaryEventID(summary))
+	}
+	return c.SubmitUserClientEvent(ctx, scheme, summaryToUserClientEvent(summary))
+}
+
+// SubmitSummary submits one activity summary with the negotiated
+// authentication scheme. A 401 moves on to the next scheme and the one that
+// works is remembered; when every scheme is rejected the credentials are
+// marked revoked. Other native API failures fall back to the offline_time_post
+// API without changing the negotiated scheme.
+func (c *RescueTimeClient) SubmitSummary(ctx context.Context, summary ActivitySummary) (usedFallback bool, err error) {
+	if c.Auth.Revoked() {
+		return false, errCredentialsRevoked
+	}
+
+	candidates := c.Auth.Candidates(c.AuthSchemes())
+	if len(candidates) == 0 {
+		return false, fmt.Errorf("no RescueTime credentials configured")
+	}
+
+	for _, scheme := range candidates {
+		slog.Debug("submitting with auth scheme", "activity", summary.AppClass, "scheme", scheme)
+		err = c.submitWith(ctx, scheme, summary)
+		if err == nil {
+			c.Auth.Learn(scheme)
+			return scheme == AuthLegacy && c.HasNativeCredentials(), nil
+		}
+
+		if isUnauthorized(err) {
+			slog.Warn("auth scheme rejected, renegotiating", "scheme", scheme)
+			continue
+		}
+
+		if scheme == AuthLegacy || c.APIKey == "" || ctx.Err() != nil || errors.Is(err, errSubmissionsPaused) {
+			return false, err
+		}
+
+		// Native API failed, log and try legacy fallback
+		slog.Warn("native API failed, falling back to legacy API", "activity", summary.AppClass, "error", err)
+		return true, c.submitWith(ctx, AuthLegacy, summary)
+	}
+
+	c.Auth.Revoke()
+	return false, fmt.Errorf("%w: %v", errCredentialsRevoked, err)
+}
+
+// newPublicAPIClient creates a client for the read-only public APIs from the
+// API key in the environment or .env
+func newPublicAPIClient() (*RescueTimeClient, error) {
+	apiKey := os.Getenv("RESCUE_TIME_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("RESCUE_TIME_API_KEY not found in .env file")
+	}
+	return NewRescueTimeClient(endpoints, apiKey, "", ""), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// TrackerStatus is what the running tracker reports to the status command
+type TrackerStatus struct {
+	Running        bool                   `json:"running"`
+	StartedAt      time.Time              `json:"started_at,omitzero"`
+	Tracking       string                 `json:"tracking,omitempty"`       // application in focus
+	Context        SessionContext         `json:"context,omitzero"`         // of the application in focus
+	Classification *ProductivityRule      `json:"classification,omitempty"` // of the window in focus, if known
+	Today          *ProductivityBreakdown `json:"today,omitempty"`          // time tracked today by productivity
+	Focus          FocusState             `json:"focus"`
+	Submission     *SubmissionStatus      `json:"submission,omitempty"` // nil unless submitting
+	Config         EffectiveConfig        `json:"config"`
+}
+
+// statusSources is what the running tracker's status is gathered from
+type statusSources struct {
+	StartedAt    time.Time
+	Tracker      *ActivityTracker
+	Focus        *FocusMonitor
+	Submitter    *Submitter // nil unless submitting
+	Config       *ConfigSync
+	Productivity *ProductivityMap
+	Today        func() []ActivitySummary // today's tracked activity
+}
+
+// handleStatus serves the status of the running tracker
+func handleStatus(sources statusSources) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := TrackerStatus{
+			Running:   true,
+			StartedAt: sources.StartedAt,
+			Focus:     sources.Focus.State(),
+			Config:    sources.Config.Effective(),
+		}
+		if session, ok := sources.Tracker.Current(); ok {
+			status.Tracking = session.AppClass
+			status.Context = session.SessionContext
+			if rule, ok := sources.Productivity.ClassifyContext(session.AppClass, session.WindowTitle, session.SessionContext); ok {
+				status.Classification = &rule
+			}
+		}
+		if sources.Today != nil {
+			today := sources.Productivity.Breakdown(sources.Today())
+			status.Today = &today
+		}
+		if sources.Submitter != nil {
+			submission := sources.Submitter.Status()
+			status.Submission = &submission
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// writeConfig prints the effective configuration
+func writeConfig(out io.Writer, config EffectiveConfig) {
+	fmt.Fprintf(out, "Submission interval: %v (%s)\n", config.SubmissionInterval, config.SubmissionIntervalSource)
+	if config.ServerConfigFetchedAt.IsZero() {
+		fmt.Fprintln(out, "Server config: not fetched")
+		return
+	}
+	plan := config.Plan
+	if plan == "" {
+		plan = "unknown"
+	}
+	fmt.Fprintf(out, "Plan: %s\n", plan)
+	if features := config.enabledFeatures(); len(features) > 0 {
+		fmt.Fprintf(out, "Features: %s\n", strings.Join(features, ", "))
+	}
+	fmt.Fprintf(out, "Server config fetched: %s\n", config.ServerConfigFetchedAt.Local().Format("2006-01-02 15:04"))
+}
+
+// writeStatus prints the status of the running tracker
+func writeStatus(out io.Writer, status TrackerStatus) {
+	fmt.Fprintf(out, "Tracker: running since %s", status.StartedAt.Local().Format("2006-01-02 15:04"))
+	if status.Tracking != "" {
+		fmt.Fprintf(out, ", tracking %s", status.Tracking)
+		if status.Context.Project != "" {
+			fmt.Fprintf(out, " on %s", status.Context.Project)
+			if status.Context.Branch != "" {
+				fmt.Fprintf(out, "@%s", status.Context.Branch)
+			}
+		}
+		if status.Context.Directory != "" {
+			fmt.Fprintf(out, " in %s", status.Context.Directory)
+		}
+		if status.Context.Workspace != "" {
+			fmt.Fprintf(out, " on workspace %s", status.Context.Workspace)
+		}
+		if c := status.Classification; c != nil {
+			fmt.Fprintf(out, " (%s", productivityLevelNames[c.Level])
+			if c.Category != "" {
+				fmt.Fprintf(out, ", %s", c.Category)
+			}
+			fmt.Fprint(out, ")")
+		}
+	}
+	fmt.Fprintln(out)
+	if today := status.Today; today != nil {
+		fmt.Fprintf(out, "Today: %v productive, %v neutral, %v distracting, pulse %d\n",
+			today.ProductiveTime().Round(time.Minute), (today.Neutral + today.Unclassified).Round(time.Minute),
+			today.DistractingTime().Round(time.Minute), today.Pulse())
+	}
+
+	if status.Focus.Active {
+		fmt.Fprintf(out, "Focus: until %s (%s)\n", status.Focus.Until.Local().Format("15:04"), status.Focus.Source)
+	} else {
+		fmt.Fprintln(out, "Focus: off")
+	}
+
+	if s := status.Submission; s == nil {
+		fmt.Fprintln(out, "Submission: disabled")
+	} else {
+		fmt.Fprintf(out, "Submission: %d queued, %d acknowledged, circuit %s", s.Pending, s.Acked, s.Circuit)
+		if s.NextAttempt > 0 {
+			fmt.Fprintf(out, ", next attempt in %v", s.NextAttempt.Round(time.Second))
+		}
+		fmt.Fprintln(out)
+		switch {
+		case s.Revoked:
+			fmt.Fprintln(out, "Authentication: credentials revoked, run activate")
+		case s.AuthScheme != "":
+			fmt.Fprintf(out, "Authentication: %s\n", s.AuthScheme)
+		default:
+			fmt.Fprintln(out, "Authentication: not negotiated yet")
+		}
+	}
+
+	writeConfig(out, status.Config)
+}
+
+// runStatus implements the status command. Without a running tracker it
+// shows the configuration the next start would use.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	socket := fs.String("socket", controlSocketPath(), "Control socket of the running tracker")
+	asJSON := fs.Bool("json", false, "Print the status as JSON")
+	fs.Parse(args)
+
+	body, err := controlGet(*socket, "/status")
+	if err != nil {
+		if !errors.Is(err, errTrackerNotRunning) {
+			return err
+		}
+		config, err := OpenConfigSync(nil, statePath("config.json"), defaultSubmissionInterval, false)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return json.NewEncoder(os.Stdout).Encode(TrackerStatus{Config: config.Effective()})
+		}
+		fmt.Println("Tracker: not running")
+		writeConfig(os.Stdout, config.Effective())
+		return nil
+	}
+
+	if *asJSON {
+		_, err := os.Stdout.Write(body)
+		return err
+	}
+	var status TrackerStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("invalid status: %v", err)
+	}
+	writeStatus(os.Stdout, status)
+	return nil
+}
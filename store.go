@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// storeDateFormat names the daily files of the activity store
+const storeDateFormat = "2006-01-02"
+
+// ActivityStore keeps a local copy of everything tracked, one JSON Lines
+// file of summaries per day, independent of whether RescueTime accepted it.
+// It is what backfill compares against the ledger.
+type ActivityStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewActivityStore creates a store in dir
+func NewActivityStore(dir string) *ActivityStore {
+	return &ActivityStore{dir: dir}
+}
+
+// Record appends summaries to the file of the day each one started on
+func (s *ActivityStore) Record(summaries []ActivitySummary) error {
+	if s == nil || len(summaries) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create activity store: %v", err)
+	}
+
+	byDay := make(map[string][]ActivitySummary)
+	for _, summary := range summaries {
+		day := summary.FirstSeen.Local().Format(storeDateFormat)
+		byDay[day] = append(byDay[day], summary)
+	}
+
+	for day, daySummaries := range byDay {
+		f, err := os.OpenFile(filepath.Join(s.dir, day+".jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open activity store: %v", err)
+		}
+		encoder := json.NewEncoder(f)
+		for _, summary := range daySummaries {
+			if err := encoder.Encode(summary); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to write activity store: %v", err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write activity store: %v", err)
+		}
+	}
+	return nil
+}
+
+// Day returns the summaries recorded for a local calendar day, in order
+func (s *ActivityStore) Day(day time.Time) ([]ActivitySummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(s.dir, day.Format(storeDateFormat)+".jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open activity store: %v", err)
+	}
+	defer f.Close()
+
+	var summaries []ActivitySummary
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var summary ActivitySummary
+		if err := json.Unmarshal(scanner.Bytes(), &summary); err != nil {
+			// A torn final line from a crash shouldn't hide the rest of the day
+			return summaries, fmt.Errorf("%s line %d: %v", f.Name(), line, err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := scanner.Err(); err != nil {
+		return summaries, fmt.Errorf("failed to read activity store: %v", err)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].FirstSeen.Before(summaries[j].FirstSeen) })
+	return summaries, nil
+}
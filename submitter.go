@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Submitter delivers queued summaries to RescueTime in the background so the
+// monitor loop never blocks on the network. Summaries go through the Outbox,
+// so whatever can't be delivered before shutdown is kept for the next run.
+type Submitter struct {
+	// RetryInterval is how soon undelivered items are retried when the
+	// governor doesn't dictate a later time
+	RetryInterval time.Duration
+
+	// Store keeps a local copy of everything enqueued, for backfill (optional)
+	Store *ActivityStore
+
+	// ProjectDetails submits the project, when known, as activity details
+	ProjectDetails bool
+
+	client *RescueTimeClient
+	outbox *Outbox
+	ledger *Ledger // acknowledged events; nil disables reconciliation
+	clock  Clock
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+// NewSubmitter creates a submitter; call Run to start delivering
+func NewSubmitter(client *RescueTimeClient, outbox *Outbox, ledger *Ledger, clock Clock) *Submitter {
+	return &Submitter{
+		RetryInterval: time.Minute,
+		client:        client,
+		outbox:        outbox,
+		ledger:        ledger,
+		clock:         clock,
+		wake:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// Enqueue records summaries in the local store, persists the submittable ones
+// and wakes the worker. It has the signature of Monitor.Submit.
+func (s *Submitter) Enqueue(summaries map[string]ActivitySummary) {
+	if s.ProjectDetails {
+		summaries = withProjectDetails(summaries)
+	}
+	sorted := sortedSummaries(summaries)
+	if err := s.Store.Record(sorted); err != nil {
+		slog.Error("failed to record activity locally", "error", err)
+	}
+
+	if s.enqueue(sorted) == 0 {
+		slog.Info("no activities to submit")
+	}
+}
+
+// Backfill queues summaries recovered from the local store and wakes the
+// worker, returning how many were long enough to submit and not queued yet
+func (s *Submitter) Backfill(summaries []ActivitySummary) int {
+	return s.enqueue(summaries)
+}
+
+func (s *Submitter) enqueue(summaries []ActivitySummary) int {
+	var queued []ActivitySummary
+	for _, summary := range summaries {
+		// Skip activities with a very short duration (< 1 minute)
+		if shouldSubmit(summary) {
+			queued = append(queued, summary)
+		}
+	}
+	if len(queued) == 0 {
+		return 0
+	}
+
+	added, err := s.outbox.Add(s.clock.Now(), queued...)
+	if err != nil {
+		// The items are still queued in memory
+		slog.Error("failed to persist outbox", "error", err)
+	}
+	if added == 0 {
+		return 0
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default: // a flush is already pending
+	}
+	return added
+}
+
+// Run delivers queued summaries until ctx is cancelled. Items left over from
+// a previous run are flushed immediately, and undelivered items are retried
+// once the circuit breaker allows a probe or after RetryInterval.
+func (s *Submitter) Run(ctx context.Context) {
+	defer close(s.done)
+
+	retry := time.NewTimer(0)
+	defer retry.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-retry.C:
+		}
+
+		s.flush(ctx)
+
+		retry.Stop()
+		if s.outbox.Len() > 0 {
+			delay := s.client.Governor.NextAttempt()
+			if delay == 0 {
+				delay = s.RetryInterval
+			}
+			retry.Reset(delay)
+		}
+	}
+}
+
+// Close waits for Run to return, then makes a final delivery attempt bounded
+// by timeout. Anything still undelivered stays in the outbox.
+func (s *Submitter) Close(timeout time.Duration) {
+	<-s.done
+
+	if s.outbox.Len() == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s.flush(ctx)
+
+	if remaining := s.outbox.Len(); remaining > 0 {
+		slog.Warn("submissions left in outbox for the next run", "count", remaining, "path", s.outbox.path)
+	}
+}
+
+// Flush submits the queue once without a background worker and returns how
+// many items were delivered and how many remain queued
+func (s *Submitter) Flush(ctx context.Context) (submitted, remaining int) {
+	submitted = s.flush(ctx)
+	return submitted, s.outbox.Len()
+}
+
+// flush submits every queued item, oldest first, until the queue is empty or
+// ctx is done, and returns how many were delivered
+func (s *Submitter) flush(ctx context.Context) int {
+	items := s.reconcile(s.outbox.Pending())
+	if len(items) == 0 {
+		return 0
+	}
+
+	if s.client.HasNativeCredentials() {
+		slog.Info("submitting activities", "count", len(items), "api", "user_client_events", "fallback", "offline_time_post")
+	} else {
+		slog.Info("submitting activities", "count", len(items), "api", "offline_time_post")
+	}
+
+	summaries := make([]ActivitySummary, len(items))
+	for i, item := range items {
+		summaries[i] = item.Summary
+	}
+	results := s.client.SubmitSummaries(ctx, summaries)
+
+	successCount := 0
+	failCount := 0
+	nativeSuccessCount := 0
+	legacyFallbackCount := 0
+	var stopErr error
+	var acked []OutboxItem
+
+	// Only the items that failed stay queued
+	for i, result := range results {
+		item := items[i]
+		err := result.Err
+
+		if isStopping(ctx, err) {
+			// Not attempted, or abandoned: keep it queued without counting an attempt
+			stopErr = err
+			continue
+		}
+		if err != nil {
+			failCount++
+			if isPermanentError(err) {
+				// Retrying can never succeed, don't keep it around forever
+				slog.Error("dropping rejected activity", "activity", item.Summary.AppClass, "error", err)
+				s.remove(item)
+				continue
+			}
+			slog.Error("failed to submit activity", "activity", item.Summary.AppClass, "error", err)
+			if err := s.outbox.RecordAttempt(item.ID); err != nil {
+				slog.Error("failed to persist outbox", "error", err)
+			}
+			continue
+		}
+
+		successCount++
+		if result.UsedFallback {
+			legacyFallbackCount++
+		} else if s.client.HasNativeCredentials() {
+			nativeSuccessCount++
+		}
+		acked = append(acked, item)
+	}
+
+	// Record before dequeuing: if we die in between, the ledger skips the resend
+	ackedSummaries := make([]ActivitySummary, len(acked))
+	for i, item := range acked {
+		ackedSummaries[i] = item.Summary
+	}
+	if err := s.ledger.Record(s.clock.Now(), ackedSummaries...); err != nil {
+		slog.Error("failed to persist ledger", "error", err)
+	}
+	for _, item := range acked {
+		s.remove(item)
+	}
+
+	switch {
+	case errors.Is(stopErr, errSubmissionsPaused):
+		// Don't spend attempts on the rest of the queue during an outage
+		slog.Warn("submissions paused, keeping activities queued", "reason", stopErr, "retry_in", s.client.Governor.NextAttempt())
+	case errors.Is(stopErr, errCredentialsRevoked):
+		slog.Error("credentials revoked, keeping activities queued until activate is run", "pending", s.outbox.Len())
+	}
+
+	if s.client.HasNativeCredentials() {
+		slog.Info("submission complete",
+			"succeeded", successCount,
+			"failed", failCount,
+			"native", nativeSuccessCount,
+			"legacy_fallback", legacyFallbackCount,
+			"pending", s.outbox.Len())
+	} else {
+		slog.Info("submission complete", "succeeded", successCount, "failed", failCount, "pending", s.outbox.Len())
+	}
+	return successCount
+}
+
+// reconcile drops queued items the ledger shows were already submitted and
+// trims those overlapping submitted time ranges
+func (s *Submitter) reconcile(items []OutboxItem) []OutboxItem {
+	kept := items[:0]
+	for _, item := range items {
+		summary, ok := s.ledger.Reconcile(item.Summary)
+		if !ok {
+			slog.Info("skipping already submitted activity", "activity", item.Summary.AppClass, "start", item.Summary.FirstSeen, "duration", item.Summary.TotalDuration)
+			s.remove(item)
+			continue
+		}
+		if summary != item.Summary {
+			slog.Info("trimmed activity overlapping submitted time",
+				"activity", summary.AppClass,
+				"start", summary.FirstSeen,
+				"duration", summary.TotalDuration,
+				"original_duration", item.Summary.TotalDuration)
+			item.Summary = summary
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func (s *Submitter) remove(item OutboxItem) {
+	if err := s.outbox.Remove(item.ID); err != nil {
+		slog.Error("failed to persist outbox", "error", err)
+	}
+}
+
+// Preview writes the requests the next flush would make for the queued items
+// plus current, without sending anything or changing any state
+func (s *Submitter) Preview(ctx context.Context, current map[string]ActivitySummary, out io.Writer) {
+	var summaries []ActivitySummary
+	for _, item := range s.outbox.Pending() {
+		summaries = append(summaries, item.Summary)
+	}
+	if len(summaries) > 0 {
+		fmt.Fprintf(out, "# %d queued from earlier submissions\n", len(summaries))
+	}
+	if s.ProjectDetails {
+		current = withProjectDetails(current)
+	}
+	summaries = append(summaries, sortedSummaries(current)...)
+
+	previewSubmission(ctx, s.client, s.ledger, summaries, out)
+}
+
+// SubmissionStatus describes the submission pipeline for status
+type SubmissionStatus struct {
+	Pending     int           `json:"pending"`
+	AuthScheme  AuthScheme    `json:"auth_scheme,omitempty"`
+	Revoked     bool          `json:"revoked,omitempty"`
+	Circuit     string        `json:"circuit"`
+	NextAttempt time.Duration `json:"next_attempt,omitempty"`
+	Acked       int           `json:"acknowledged"`
+}
+
+// Status returns the state of the outbox, authentication and governor
+func (s *Submitter) Status() SubmissionStatus {
+	status := SubmissionStatus{
+		Pending:     s.outbox.Len(),
+		Circuit:     s.client.Governor.State(),
+		NextAttempt: s.client.Governor.NextAttempt(),
+		Acked:       s.ledger.Len(),
+	}
+	if s.client.Auth != nil {
+		status.AuthScheme = s.client.Auth.Scheme()
+		status.Revoked = s.client.Auth.Revoked()
+	}
+	return status
+}
+
+// setupSubmitter creates the submitter for -submit from the credentials
+// loaded from the environment and .env, and the state directory. With dryRun
+// set, requests are printed there instead of sent and no state is written.
+func setupSubmitter(outboxPath string, dryRun io.Writer) (*Submitter, error) {
+	// Get API key from environment
+	apiKey := os.Getenv("RESCUE_TIME_API_KEY")
+	if apiKey == "" {
+		if dryRun == nil {
+			return nil, fmt.Errorf("RESCUE_TIME_API_KEY not found in .env file")
+		}
+		apiKey = "dry-run-api-key"
+	}
+
+	accountKey, dataKey := os.Getenv("RESCUE_TIME_ACCOUNT_KEY"), os.Getenv("RESCUE_TIME_DATA_KEY")
+	client := NewRescueTimeClient(endpoints, apiKey, accountKey, dataKey)
+
+	var err error
+	client.Auth, err = OpenAuthNegotiator(statePath("auth.json"), apiKey, accountKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if client.Auth.Revoked() {
+		slog.Error("credentials were revoked, run activate; activities will be queued until then")
+	}
+
+	ledger, err := OpenLedger(statePath("ledger.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun != nil {
+		// Same pipeline, nothing sent and nothing persisted
+		outbox, _ := OpenOutbox("")
+		return NewSubmitter(client.dryRunClient(dryRun), outbox, ledger.snapshot(), realClock{}), nil
+	}
+
+	if outboxPath == "" {
+		outboxPath = statePath("outbox.json")
+	}
+	var store *ActivityStore
+	if dir := statePath("activity"); dir != "" {
+		store = NewActivityStore(dir)
+	}
+	outbox, err := OpenOutbox(outboxPath)
+	if err != nil {
+		return nil, err
+	}
+	if pending := outbox.Len(); pending > 0 {
+		slog.Info("resuming undelivered submissions", "count", pending, "path", outboxPath)
+	}
+
+	submitter := NewSubmitter(client, outbox, ledger, realClock{})
+	submitter.Store = store
+	return submitter, nil
+}
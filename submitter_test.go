@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSummaries() map[string]ActivitySummary {
+	return map[string]ActivitySummary{
+		"firefox|GitHub": {AppClass: "firefox", ActivityDetails: "GitHub", TotalDuration: 5 * time.Minute, FirstSeen: testStart},
+		"kitty|~":        {AppClass: "kitty", ActivityDetails: "~", TotalDuration: 2 * time.Minute, FirstSeen: testStart.Add(5 * time.Minute)},
+		"foot|~":         {AppClass: "foot", ActivityDetails: "~", TotalDuration: 30 * time.Second, FirstSeen: testStart.Add(7 * time.Minute)},
+	}
+}
+
+func TestSubmitterDeliversQueuedSummaries(t *testing.T) {
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key"})
+	outbox, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitter := NewSubmitter(testClient("test-key", "", ""), outbox, nil, NewManualClock(testStart))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go submitter.Run(ctx)
+	submitter.Enqueue(testSummaries())
+	cancel()
+	submitter.Close(5 * time.Second)
+
+	if n := outbox.Len(); n != 0 {
+		t.Errorf("%d items left in outbox", n)
+	}
+	// The 30 second foot session is below the submission minimum
+	if n := len(mock.RequestsTo("offline_time_post")); n != 2 {
+		t.Errorf("mock received %d submissions, want 2", n)
+	}
+}
+
+func TestSubmitterPersistsWhatShutdownCannotDeliver(t *testing.T) {
+	startMockServer(t, MockServerConfig{APIKey: "test-key", Fail: MockFailServerError})
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	outbox, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := testClient("test-key", "", "")
+	client.Governor.BaseDelay = time.Minute
+	submitter := NewSubmitter(client, outbox, nil, NewManualClock(testStart))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go submitter.Run(ctx)
+	submitter.Enqueue(testSummaries())
+
+	start := time.Now()
+	submitter.Close(100 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("shutdown flush took %v, want it bounded by the timeout", elapsed)
+	}
+
+	reopened, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := reopened.Pending()
+	if len(pending) != 2 || pending[0].Summary.AppClass != "firefox" || pending[1].Summary.AppClass != "kitty" {
+		t.Fatalf("persisted outbox = %+v, want firefox and kitty", pending)
+	}
+
+	// The next run delivers the leftovers once the API recovers
+	mock := startMockServer(t, MockServerConfig{APIKey: "test-key"})
+	next := NewSubmitter(testClient("test-key", "", ""), reopened, nil, NewManualClock(testStart))
+	ctx, cancel = context.WithCancel(context.Background())
+	go next.Run(ctx)
+	cancel()
+	next.Close(5 * time.Second)
+
+	if n := reopened.Len(); n != 0 {
+		t.Errorf("%d items left after recovery", n)
+	}
+	if n := len(mock.RequestsTo("offline_time_post")); n != 2 {
+		t.Errorf("mock received %d submissions, want 2", n)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// DailySummary is one day of the Daily Summary Feed: a rollup of the time
+// logged in the user's time zone. Percentages range from 0 to 100.
+type DailySummary struct {
+	ID                int64   `json:"id"`
+	Date              string  `json:"date"`
+	ProductivityPulse float64 `json:"productivity_pulse"`
+
+	VeryProductivePercentage      float64 `json:"very_productive_percentage"`
+	ProductivePercentage          float64 `json:"productive_percentage"`
+	NeutralPercentage             float64 `json:"neutral_percentage"`
+	DistractingPercentage         float64 `json:"distracting_percentage"`
+	VeryDistractingPercentage     float64 `json:"very_distracting_percentage"`
+	AllProductivePercentage       float64 `json:"all_productive_percentage"`
+	AllDistractingPercentage      float64 `json:"all_distracting_percentage"`
+	UncategorizedPercentage       float64 `json:"uncategorized_percentage"`
+	BusinessPercentage            float64 `json:"business_percentage"`
+	CommunicationPercentage       float64 `json:"communication_and_scheduling_percentage"`
+	SocialNetworkingPercentage    float64 `json:"social_networking_percentage"`
+	DesignPercentage              float64 `json:"design_and_composition_percentage"`
+	EntertainmentPercentage       float64 `json:"entertainment_percentage"`
+	NewsPercentage                float64 `json:"news_percentage"`
+	SoftwareDevelopmentPercentage float64 `json:"software_development_percentage"`
+	ReferencePercentage           float64 `json:"reference_and_learning_percentage"`
+	ShoppingPercentage            float64 `json:"shopping_percentage"`
+	UtilitiesPercentage           float64 `json:"utilities_percentage"`
+	TotalHours                    float64 `json:"total_hours"`
+	VeryProductiveHours           float64 `json:"very_productive_hours"`
+	AllProductiveHours            float64 `json:"all_productive_hours"`
+	AllDistractingHours           float64 `json:"all_distracting_hours"`
+	VeryDistractingHours          float64 `json:"very_distracting_hours"`
+	TotalDurationFormatted        string  `json:"total_duration_formatted"`
+}
+
+// categoryShare is the percentage of a day spent in a category
+type categoryShare struct {
+	Name    string
+	Percent float64
+}
+
+// categoryPercentages lists the category breakdown of a summary in display order
+func (s DailySummary) categoryPercentages() []categoryShare {
+	return []categoryShare{
+		{"Software Development", s.SoftwareDevelopmentPercentage},
+		{"Reference & Learning", s.ReferencePercentage},
+		{"Communication & Scheduling", s.CommunicationPercentage},
+		{"Business", s.BusinessPercentage},
+		{"Design & Composition", s.DesignPercentage},
+		{"Utilities", s.UtilitiesPercentage},
+		{"News & Opinion", s.NewsPercentage},
+		{"Social Networking", s.SocialNetworkingPercentage},
+		{"Entertainment", s.EntertainmentPercentage},
+		{"Shopping", s.ShoppingPercentage},
+		{"Uncategorized", s.UncategorizedPercentage},
+	}
+}
+
+// FetchDailySummaries returns the Daily Summary Feed: one summary per logged
+// day of the previous two weeks, not including today
+func (c *RescueTimeClient) FetchDailySummaries(ctx context.Context) ([]DailySummary, error) {
+	params := url.Values{"key": {c.APIKey}}
+	body, err := c.sendWithRetry(ctx, "daily_summary_feed", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoints.DailySummaryFeed()+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []DailySummary
+	if err := json.Unmarshal(body, &summaries); err != nil {
+		return nil, fmt.Errorf("failed to parse daily summaries: %v", err)
+	}
+	return summaries, nil
+}
+
+// recentSummaries keeps the summaries of the last days days before today,
+// oldest first
+func recentSummaries(summaries []DailySummary, today time.Time, days int) []DailySummary {
+	since := today.AddDate(0, 0, -days).Format(storeDateFormat)
+	var recent []DailySummary
+	for _, summary := range summaries {
+		if summary.Date >= since {
+			recent = append(recent, summary)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return recent[i].Date < recent[j].Date })
+	return recent
+}
+
+// writeSummaryTable prints the pulse and productive/distracting time per day,
+// then the category breakdown with one column per day
+func writeSummaryTable(out io.Writer, summaries []DailySummary) {
+	if len(summaries) == 0 {
+		fmt.Fprintln(out, "No daily summaries (RescueTime publishes each day shortly after midnight)")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DATE\tPULSE\tTOTAL\tVERY PRODUCTIVE\tVERY DISTRACTING\tPRODUCTIVE\tDISTRACTING\t")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%.0f\t%.1fh\t%.1fh\t%.1fh\t%.1f%%\t%.1f%%\t\n", s.Date, s.ProductivityPulse, s.TotalHours,
+			s.VeryProductiveHours, s.VeryDistractingHours, s.AllProductivePercentage, s.AllDistractingPercentage)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "CATEGORY\t")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t", s.Date)
+	}
+	fmt.Fprintln(w)
+	for i, category := range summaries[0].categoryPercentages() {
+		fmt.Fprintf(w, "%s\t", category.Name)
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%.1f%%\t", s.categoryPercentages()[i].Percent)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// runSummary implements the summary command: the productivity pulse and
+// category breakdown of the last days from the Daily Summary Feed
+func runSummary(args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	days := fs.Int("days", 1, "Number of days before today to show (the feed keeps 14)")
+	format := fs.String("format", "table", "Output format: table or json")
+	fs.Parse(args)
+
+	if *days < 1 {
+		return fmt.Errorf("-days must be at least 1")
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown -format %q, use table or json", *format)
+	}
+
+	client, err := newPublicAPIClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	summaries, err := client.FetchDailySummaries(ctx)
+	if err != nil {
+		return err
+	}
+	summaries = recentSummaries(summaries, time.Now(), *days)
+
+	if *format == "json" {
+		if summaries == nil {
+			summaries = []DailySummary{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+	writeSummaryTable(os.Stdout, summaries)
+	return nil
+}
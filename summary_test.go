@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailySummaryFeed(t *testing.T) {
+	startMockServer(t, MockServerConfig{APIKey: "test-key"})
+	client := testClient("test-key", "", "")
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	for _, daysAgo := range []int{1, 3} {
+		err := client.SubmitOfflineTime(context.Background(), summaryToPayload(ActivitySummary{
+			AppClass: "firefox", TotalDuration: 90 * time.Minute, FirstSeen: today.AddDate(0, 0, -daysAgo).Add(9 * time.Hour),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summaries, err := client.FetchDailySummaries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("feed has %d days, want 2", len(summaries))
+	}
+
+	yesterday := today.AddDate(0, 0, -1).Format(storeDateFormat)
+	recent := recentSummaries(summaries, today, 1)
+	if len(recent) != 1 || recent[0].Date != yesterday || recent[0].TotalHours != 1.5 {
+		t.Fatalf("last day = %+v, want 1.5h on %s", recent, yesterday)
+	}
+	if recent = recentSummaries(summaries, today, 7); len(recent) != 2 || recent[1].Date != yesterday {
+		t.Errorf("last week = %+v, want two days oldest first", recent)
+	}
+
+	var out bytes.Buffer
+	writeSummaryTable(&out, recent)
+	for _, want := range []string{"PULSE", yesterday, "1.5h", "Uncategorized", "100.0%"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("table is missing %q:\n%s", want, out.String())
+		}
+	}
+}
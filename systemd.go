@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// sdNotify sends a state string (e.g. "READY=1") to the service manager.
+// It is a no-op when not started by systemd with NOTIFY_SOCKET set.
+func sdNotify(state string) error {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return nil
+	}
+
+	// Abstract namespace sockets are announced with a leading '@'
+	if strings.HasPrefix(socketPath, "@") {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to write to notify socket: %v", err)
+	}
+	return nil
+}
+
+// notifyStatus updates the free-form status shown by systemctl status
+func notifyStatus(format string, args ...any) {
+	if err := sdNotify("STATUS=" + fmt.Sprintf(format, args...)); err != nil {
+		slog.Debug("sd_notify failed", "error", err)
+	}
+}
+
+// watchdogInterval returns the watchdog timeout requested by systemd (WatchdogSec=),
+// or zero if the watchdog is not enabled for this process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	// WATCHDOG_PID is set when the watchdog targets a specific process
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog pings the systemd watchdog from the main loop. Because pings are only
+// sent when the loop calls Ping, a stalled poll/event loop stops the pings and
+// systemd restarts the service once WatchdogSec elapses.
+type Watchdog struct {
+	interval time.Duration // minimum gap between pings (half the watchdog timeout)
+	lastPing time.Time
+}
+
+// NewWatchdog creates a watchdog from the environment, or nil if systemd did not request one
+func NewWatchdog() *Watchdog {
+	timeout := watchdogInterval()
+	if timeout == 0 {
+		return nil
+	}
+	slog.Info("systemd watchdog enabled", "timeout", timeout)
+	return &Watchdog{interval: timeout / 2}
+}
+
+// Ping notifies systemd that the loop is alive, at most once per interval
+func (w *Watchdog) Ping(now time.Time) {
+	if w == nil || now.Sub(w.lastPing) < w.interval {
+		return
+	}
+	w.lastPing = now
+	if err := sdNotify("WATCHDOG=1"); err != nil {
+		slog.Warn("failed to ping systemd watchdog", "error", err)
+	}
+}
+
+// serviceUnitTemplate is the user unit written by install-service
+const serviceUnitTemplate = `# Generated by active-window install-service
+[Unit]
+Description=RescueTime Activity Tracker
+Documentation=https://github.com/robwilde/rescuetime-linux
+PartOf=graphical-session.target
+After=graphical-session.target
+Requisite=graphical-session.target
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart={{.ExecStart}}
+WorkingDirectory={{.WorkingDirectory}}
+Restart=on-failure
+RestartSec=10s
+WatchdogSec={{.WatchdogSec}}
+TimeoutStopSec=60s
+# WAYLAND_DISPLAY, HYPRLAND_INSTANCE_SIGNATURE etc. are inherited from the
+# user manager; the compositor must export them, e.g. in hyprland.conf:
+#   exec-once = dbus-update-activation-environment --systemd --all
+
+[Install]
+WantedBy=graphical-session.target
+`
+
+// serviceUnit holds the values substituted into serviceUnitTemplate
+type serviceUnit struct {
+	ExecStart        string
+	WorkingDirectory string
+	WatchdogSec      string
+}
+
+// defaultUnitPath returns ~/.config/systemd/user/rescuetime.service, honouring XDG_CONFIG_HOME
+func defaultUnitPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "systemd", "user", "rescuetime.service"), nil
+}
+
+// systemdQuote quotes a single ExecStart argument if required
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%;") {
+		return arg
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`).Replace(arg)
+	return `"` + escaped + `"`
+}
+
+// systemdPath escapes an absolute path for settings like WorkingDirectory=,
+// which take the value as is apart from % specifiers
+func systemdPath(path string) string {
+	return strings.ReplaceAll(path, "%", "%%")
+}
+
+// writeServiceUnit renders serviceUnitTemplate
+func writeServiceUnit(w io.Writer, unit serviceUnit) error {
+	tmpl := template.Must(template.New("unit").Parse(serviceUnitTemplate))
+	return tmpl.Execute(w, unit)
+}
+
+// runInstallService implements the install-service command
+func runInstallService(args []string) error {
+	fs := flag.NewFlagSet("install-service", flag.ExitOnError)
+	output := fs.String("output", "", "Unit file path (default ~/.config/systemd/user/rescuetime.service)")
+	workDir := fs.String("workdir", "", "Working directory containing .env (default current directory)")
+	trackArgs := fs.String("args", "-track -submit", "Arguments passed to active-window by the service")
+	watchdog := fs.Duration("watchdog", 2*time.Minute, "Watchdog timeout (WatchdogSec)")
+	enable := fs.Bool("enable", false, "Reload systemd and enable --now the service")
+	force := fs.Bool("force", false, "Overwrite an existing unit file")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine executable path: %v", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to resolve executable path: %v", err)
+	}
+
+	if *workDir == "" {
+		if *workDir, err = os.Getwd(); err != nil {
+			return fmt.Errorf("failed to determine working directory: %v", err)
+		}
+	}
+	if *workDir, err = filepath.Abs(*workDir); err != nil {
+		return fmt.Errorf("invalid working directory: %v", err)
+	}
+
+	unitPath := *output
+	if unitPath == "" {
+		if unitPath, err = defaultUnitPath(); err != nil {
+			return fmt.Errorf("failed to determine unit path: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(unitPath); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", unitPath)
+	}
+
+	execStart := []string{systemdQuote(exe)}
+	for _, arg := range strings.Fields(*trackArgs) {
+		execStart = append(execStart, systemdQuote(arg))
+	}
+
+	unit := serviceUnit{
+		ExecStart:        strings.Join(execStart, " "),
+		WorkingDirectory: systemdPath(*workDir),
+		WatchdogSec:      fmt.Sprintf("%ds", int(watchdog.Seconds())),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return fmt.Errorf("failed to create unit directory: %v", err)
+	}
+
+	f, err := os.Create(unitPath)
+	if err != nil {
+		return fmt.Errorf("failed to create unit file: %v", err)
+	}
+	if err := writeServiceUnit(f, unit); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write unit file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write unit file: %v", err)
+	}
+
+	fmt.Printf("Wrote %s\n", unitPath)
+
+	unitName := filepath.Base(unitPath)
+	if !*enable {
+		fmt.Println("Enable it with:")
+		fmt.Println("  systemctl --user daemon-reload")
+		fmt.Printf("  systemctl --user enable --now %s\n", unitName)
+		return nil
+	}
+
+	for _, cmdArgs := range [][]string{
+		{"--user", "daemon-reload"},
+		{"--user", "enable", "--now", unitName},
+	} {
+		cmd := exec.Command("systemctl", cmdArgs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("systemctl %s failed: %v", strings.Join(cmdArgs, " "), err)
+		}
+	}
+	fmt.Printf("Enabled and started %s\n", unitName)
+	return nil
+}
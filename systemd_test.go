@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/active-window": "/usr/bin/active-window",
+		"-track":                 "-track",
+		"/home/me/my apps/aw":    `"/home/me/my apps/aw"`,
+		"50%":                    `"50%%"`,
+		"$HOME":                  `"$$HOME"`,
+		`say "hi"`:               `"say \"hi\""`,
+		"":                       `""`,
+	}
+	for arg, want := range tests {
+		if got := systemdQuote(arg); got != want {
+			t.Errorf("systemdQuote(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestWriteServiceUnit(t *testing.T) {
+	var out strings.Builder
+	err := writeServiceUnit(&out, serviceUnit{
+		ExecStart:        systemdQuote("/opt/rescue time/active-window") + " -track",
+		WorkingDirectory: systemdPath("/home/me/100% done/rescuetime"),
+		WatchdogSec:      "120s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"Type=notify",
+		`ExecStart="/opt/rescue time/active-window" -track`,
+		// Not unquoted by systemd, so written raw
+		"WorkingDirectory=/home/me/100%% done/rescuetime\n",
+		"WatchdogSec=120s",
+		"WantedBy=graphical-session.target",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("unit is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+// listenNotify stands in for systemd's notify socket
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "notify") // socket paths are limited to 108 bytes
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// received returns the notifications waiting on conn
+func received(conn *net.UnixConn) []string {
+	var messages []string
+	buf := make([]byte, 1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, string(buf[:n]))
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("without systemd: %v", err)
+	}
+
+	conn := listenNotify(t)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	notifyStatus("Tracking %s", "kitty")
+	if got := received(conn); len(got) != 2 || got[0] != "READY=1" || got[1] != "STATUS=Tracking kitty" {
+		t.Errorf("received %q", got)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+	}{
+		{"120000000", "", 2 * time.Minute},
+		{"120000000", strconv.Itoa(os.Getpid()), 2 * time.Minute},
+		{"120000000", "1", 0}, // meant for another process
+		{"", "", 0},
+		{"soon", "", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		if got := watchdogInterval(); got != tt.want {
+			t.Errorf("watchdogInterval(%q, %q) = %v, want %v", tt.usec, tt.pid, got, tt.want)
+		}
+	}
+
+	conn := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "60000000")
+	t.Setenv("WATCHDOG_PID", "")
+	watchdog := NewWatchdog()
+	watchdog.Ping(testStart)
+	watchdog.Ping(testStart.Add(10 * time.Second)) // within half the timeout
+	watchdog.Ping(testStart.Add(31 * time.Second))
+	if got := received(conn); len(got) != 2 || got[0] != "WATCHDOG=1" {
+		t.Errorf("received %q, want two pings", got)
+	}
+
+	var disabled *Watchdog
+	disabled.Ping(testStart)
+}
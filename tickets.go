@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ticketPattern matches issue keys such as PROJ-123
+var ticketPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]{1,9}-[0-9]+\b`)
+
+// versionKeyPattern matches version numbers that look like issue keys, such as V2-1
+var versionKeyPattern = regexp.MustCompile(`^V[0-9]+-`)
+
+// ticketWords look like issue keys but are branch kinds, standards, languages
+// and platforms followed by a number, as in release-2025, utf-8, sha-256 or
+// python-3
+var ticketWords = map[string]bool{
+	"RELEASE": true, "HOTFIX": true, "VERSION": true, "RC": true, "BUILD": true,
+	"UTF": true, "UCS": true, "ISO": true, "RFC": true, "PEP": true, "ECMA": true, "ES": true,
+	"SHA": true, "MD": true, "AES": true, "RSA": true, "TLS": true, "SSL": true, "HTTP": true,
+	"HTTPS": true, "OAUTH": true, "IPV": true, "CVE": true, "X86": true, "ARM": true,
+	"PYTHON": true, "PY": true, "NODE": true, "JAVA": true, "JDK": true, "GO": true, "PHP": true,
+	"RUBY": true, "PERL": true, "LUA": true, "GCC": true, "LLVM": true, "GPT": true,
+	"UBUNTU": true, "DEBIAN": true, "FEDORA": true, "CENTOS": true, "RHEL": true, "ALPINE": true,
+	"WIN": true, "WINDOWS": true, "MACOS": true, "IOS": true, "ANDROID": true, "POSTGRES": true,
+	"PG": true, "MYSQL": true, "REDIS": true, "REACT": true, "VUE": true, "ANGULAR": true,
+}
+
+// dependencyBranches are the branch prefixes of dependency update bots, whose
+// branches name packages and versions rather than tickets
+var dependencyBranches = []string{"renovate/", "dependabot/"}
+
+// findTickets returns the issue keys in s in order of appearance. Keys
+// continuing as a version, like NODE-20.x or LODASH-4.17.21, are left out.
+func findTickets(s string) []string {
+	var tickets []string
+	for _, match := range ticketPattern.FindAllStringIndex(s, -1) {
+		ticket := s[match[0]:match[1]]
+		if rest := s[match[1]:]; len(rest) > 1 && rest[0] == '.' && strings.ContainsRune("0123456789xX", rune(rest[1])) {
+			continue
+		}
+		key, _, _ := strings.Cut(ticket, "-")
+		if ticketWords[key] || versionKeyPattern.MatchString(ticket) {
+			continue
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets
+}
+
+// ticketFromBranch returns the first issue key in a branch name, such as
+// ABC-123 in feature/abc-123-login, upper-cased
+func ticketFromBranch(branch string) string {
+	for _, prefix := range dependencyBranches {
+		if strings.HasPrefix(branch, prefix) {
+			return ""
+		}
+	}
+	tickets := findTickets(strings.ToUpper(strings.ReplaceAll(branch, "_", "/")))
+	if len(tickets) == 0 {
+		return ""
+	}
+	return tickets[0]
+}
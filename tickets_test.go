@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTicketFromBranch(t *testing.T) {
+	tests := map[string]string{
+		"feature/ABC-123-login":      "ABC-123",
+		"abc-123_fix-typo":           "ABC-123",
+		"bugfix/ops-7":               "OPS-7",
+		"fix-login-PROJ-42":          "PROJ-42",
+		"release-2025/web-9-hero":    "WEB-9",
+		"b2b-17-checkout":            "B2B-17",
+		"release-2025":               "",
+		"main":                       "",
+		"v2-1":                       "",
+		"dependabot/npm/lodash-4":    "",
+		"renovate/node-20.x":         "",
+		"bump-lodash-4.17.21":        "",
+		"averyveryverylongword-1":    "",
+		"fix/utf-8-decoding":         "",
+		"feature/python-3-migration": "",
+		"feat/oauth-2-login":         "",
+		"feature/sha-256":            "",
+		"chore/iso-8601-dates":       "",
+		"docs/rfc-7231":              "",
+		"feat/gpt-4-summaries":       "",
+		"feat/http-2-push-WEB-31":    "WEB-31",
+	}
+	for branch, want := range tests {
+		if got := ticketFromBranch(branch); got != want {
+			t.Errorf("ticketFromBranch(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+func TestFindTickets(t *testing.T) {
+	tests := map[string][]string{
+		"PROJ-12 Login fails - Jira — Mozilla Firefox": {"PROJ-12"},
+		"Review OPS-7 and OPS-8.":                      {"OPS-7", "OPS-8"},
+		"Upgrade to NODE-20.x":                         nil,
+		"proj-12 in lower case":                        nil,
+		"RELEASE-2025 checklist":                       nil,
+		"UTF-8 encoding - SHA-256 - Wikipedia":         nil,
+	}
+	for s, want := range tests {
+		if got := findTickets(s); !slices.Equal(got, want) {
+			t.Errorf("findTickets(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Trace entry kinds
+const (
+	TraceWindow   = "window"   // a window observation from the WindowSource
+	TraceError    = "error"    // the WindowSource failed
+	TraceSubmit   = "submit"   // a submission boundary
+	TraceShutdown = "shutdown" // the monitor stopped
+)
+
+// TraceEntry is one line of a focus trace
+type TraceEntry struct {
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Window   *HyprlandWindow `json:"window,omitempty"`
+	Resolved *Resolution     `json:"resolved,omitempty"` // absent in traces recorded before resolvers
+	Error    string          `json:"error,omitempty"`
+}
+
+// Resolution is what the monitor made of a window: the application, title
+// and context its session is tracked under, after the browser, terminal,
+// project and productivity lookups
+type Resolution struct {
+	AppClass   string         `json:"app_class"`
+	Title      string         `json:"title"`
+	Context    SessionContext `json:"context,omitzero"`
+	Background bool           `json:"background,omitempty"`
+}
+
+// TraceRecorder appends window observations to a JSON Lines file.
+// Consecutive identical observations are collapsed into the first one, since
+// the tracker only reacts to changes; a nil recorder records nothing.
+type TraceRecorder struct {
+	mu      sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	last    *TraceEntry
+	lastErr error
+}
+
+// NewTraceRecorder opens (or appends to) the trace file at path
+func NewTraceRecorder(path string) (*TraceRecorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %v", err)
+	}
+	return &TraceRecorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Observe records the result of one WindowSource poll and what the window
+// resolved to
+func (r *TraceRecorder) Observe(now time.Time, window *HyprlandWindow, resolved *Resolution, err error) {
+	if r == nil {
+		return
+	}
+	entry := TraceEntry{Time: now, Kind: TraceWindow, Window: window, Resolved: resolved}
+	if err != nil {
+		entry = TraceEntry{Time: now, Kind: TraceError, Error: err.Error()}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last != nil && sameObservation(*r.last, entry) {
+		return
+	}
+	r.writeUnsafe(entry)
+	r.last = &entry
+}
+
+// Mark records a submission boundary or shutdown
+func (r *TraceRecorder) Mark(now time.Time, kind string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeUnsafe(TraceEntry{Time: now, Kind: kind})
+}
+
+// writeUnsafe encodes one entry (must be called with lock held). Write errors
+// are reported once rather than on every poll.
+func (r *TraceRecorder) writeUnsafe(entry TraceEntry) {
+	if err := r.enc.Encode(entry); err != nil && r.lastErr == nil {
+		r.lastErr = err
+		slog.Error("failed to write trace", "error", err)
+	}
+}
+
+// Close flushes and closes the trace file
+func (r *TraceRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// sameObservation reports whether two observations would look identical to
+// the tracker and in the trace
+func sameObservation(a, b TraceEntry) bool {
+	if a.Kind != b.Kind || a.Error != b.Error {
+		return false
+	}
+	if (a.Resolved == nil) != (b.Resolved == nil) || a.Resolved != nil && *a.Resolved != *b.Resolved {
+		return false
+	}
+	if a.Window == nil || b.Window == nil {
+		return a.Window == b.Window
+	}
+	return *a.Window == *b.Window
+}
+
+// readTrace parses a JSON Lines focus trace
+func readTrace(r io.Reader) ([]TraceEntry, error) {
+	var entries []TraceEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry TraceEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if entry.Kind == TraceWindow && entry.Window == nil {
+			return nil, fmt.Errorf("line %d: window entry without window", line)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace: %v", err)
+	}
+	return entries, nil
+}
+
+// ReplaySubmission is what would have been sent at one submission boundary
+type ReplaySubmission struct {
+	Time     time.Time                `json:"time"`
+	Payloads []RescueTimePayload      `json:"payloads"`
+	Events   []UserClientEventPayload `json:"events"`
+}
+
+// ReplayResult is the outcome of feeding a trace through ActivityTracker
+type ReplayResult struct {
+	Sessions    []ActivitySession  `json:"sessions"`
+	Submissions []ReplaySubmission `json:"submissions"`
+}
+
+// replayTrace feeds recorded observations through a Monitor driven by a
+// simulated clock, tracking each window as it was resolved when recorded.
+// With a zero submissionInterval the recorded submission boundaries are used;
+// otherwise boundaries are simulated every interval from the start of each
+// run. Window changes and final summaries are written to out.
+func replayTrace(entries []TraceEntry, submissionInterval time.Duration, out io.Writer) ReplayResult {
+	var result ReplayResult
+	if len(entries) == 0 {
+		return result
+	}
+
+	clock := NewManualClock(entries[0].Time)
+
+	// A trace file may hold several runs of the tracker, each ending in a shutdown entry
+	var monitor *Monitor
+	var nextBoundary time.Time
+	newMonitor := func() *Monitor {
+		m := &Monitor{Clock: clock, Out: out}
+		m.Submit = func(summaries map[string]ActivitySummary) {
+			result.Sessions = append(result.Sessions, m.Tracker.Sessions()...)
+			result.Submissions = append(result.Submissions, buildReplaySubmission(clock.Now(), summaries))
+		}
+		return m
+	}
+
+	for _, entry := range entries {
+		if monitor != nil && submissionInterval > 0 {
+			for nextBoundary.Before(entry.Time) {
+				clock.Set(nextBoundary)
+				monitor.SubmitNow()
+				nextBoundary = nextBoundary.Add(submissionInterval)
+			}
+		}
+		clock.Set(entry.Time)
+
+		switch entry.Kind {
+		case TraceWindow:
+			if monitor == nil {
+				monitor = newMonitor()
+				nextBoundary = entry.Time.Add(submissionInterval)
+			}
+			if entry.Resolved != nil {
+				monitor.track(*entry.Resolved)
+			} else {
+				monitor.HandleWindow(entry.Window)
+			}
+		case TraceSubmit:
+			if monitor != nil && submissionInterval == 0 {
+				monitor.SubmitNow()
+			}
+		case TraceShutdown:
+			if monitor != nil {
+				monitor.Shutdown()
+				monitor = nil
+			}
+		}
+	}
+
+	// Trace ended without a clean shutdown (e.g. the process was killed)
+	if monitor != nil {
+		monitor.Shutdown()
+	}
+	return result
+}
+
+// buildReplaySubmission converts summaries into the payloads
+// submitActivitiesToRescueTime would send
+func buildReplaySubmission(now time.Time, summaries map[string]ActivitySummary) ReplaySubmission {
+	submission := ReplaySubmission{
+		Time:     now,
+		Payloads: []RescueTimePayload{},
+		Events:   []UserClientEventPayload{},
+	}
+	for _, summary := range sortedSummaries(summaries) {
+		if !shouldSubmit(summary) {
+			continue
+		}
+		submission.Payloads = append(submission.Payloads, summaryToPayload(summary))
+		submission.Events = append(submission.Events, summaryToUserClientEvent(summary))
+	}
+	return submission
+}
+
+// sortedSummaries orders summaries by first appearance, then application
+func sortedSummaries(summaries map[string]ActivitySummary) []ActivitySummary {
+	sorted := make([]ActivitySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		sorted = append(sorted, summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].FirstSeen.Equal(sorted[j].FirstSeen) {
+			return sorted[i].FirstSeen.Before(sorted[j].FirstSeen)
+		}
+		return sorted[i].AppClass < sorted[j].AppClass
+	})
+	return sorted
+}
+
+// runReplay implements the replay command
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print sessions and payloads as JSON")
+	submissionInterval := fs.Duration("submission-interval", 0, "Simulate submissions at this interval instead of the recorded boundaries")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: active-window replay [flags] trace.jsonl")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("replay needs exactly one trace file")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open trace: %v", err)
+	}
+	defer f.Close()
+
+	entries, err := readTrace(f)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		result := replayTrace(entries, *submissionInterval, io.Discard)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	result := replayTrace(entries, *submissionInterval, os.Stdout)
+
+	fmt.Println("\n=== Sessions ===")
+	for _, session := range result.Sessions {
+		fmt.Printf("%s - %s  %-8v  %s\n",
+			session.StartTime.Format("15:04:05"),
+			session.EndTime.Format("15:04:05"),
+			session.Duration.Round(time.Second),
+			formatWindowOutput(session.WindowTitle, session.AppClass))
+	}
+
+	for _, submission := range result.Submissions {
+		fmt.Printf("\n=== Submission at %s ===\n", submission.Time.Format("2006-01-02 15:04:05"))
+		if len(submission.Payloads) == 0 {
+			fmt.Println("No activities to submit.")
+			continue
+		}
+		for i, payload := range submission.Payloads {
+			legacy, _ := json.Marshal(payload)
+			native, _ := json.Marshal(submission.Events[i])
+			fmt.Printf("offline_time_post:  %s\n", legacy)
+			fmt.Printf("user_client_events: %s\n", native)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func traceWindow(offset time.Duration, class, title string) TraceEntry {
+	return TraceEntry{
+		Time:   testStart.Add(offset),
+		Kind:   TraceWindow,
+		Window: &HyprlandWindow{Class: class, Title: title},
+	}
+}
+
+func TestReplayTraceUsesRecordedBoundaries(t *testing.T) {
+	entries := []TraceEntry{
+		traceWindow(0, "firefox", "GitHub"),
+		traceWindow(10*time.Minute, "kitty", "~"),
+		{Time: testStart.Add(15 * time.Minute), Kind: TraceSubmit},
+		traceWindow(20*time.Minute, "firefox", "Docs"),
+		{Time: testStart.Add(22 * time.Minute), Kind: TraceShutdown},
+	}
+
+	result := replayTrace(entries, 0, io.Discard)
+
+	if len(result.Submissions) != 2 {
+		t.Fatalf("got %d submissions, want 2", len(result.Submissions))
+	}
+
+	first := result.Submissions[0].Payloads
+	want := []RescueTimePayload{
+		{StartTime: "2025-10-02 09:00:00", Duration: 10, ActivityName: "firefox", ActivityDetails: "GitHub"},
+		{StartTime: "2025-10-02 09:10:00", Duration: 5, ActivityName: "kitty", ActivityDetails: "~"},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("first submission = %+v, want %+v", first, want)
+	}
+
+	final := result.Submissions[1].Payloads
+	want = []RescueTimePayload{
+		{StartTime: "2025-10-02 09:15:00", Duration: 5, ActivityName: "kitty", ActivityDetails: "~"},
+		{StartTime: "2025-10-02 09:20:00", Duration: 2, ActivityName: "firefox", ActivityDetails: "Docs"},
+	}
+	if !reflect.DeepEqual(final, want) {
+		t.Errorf("final submission = %+v, want %+v", final, want)
+	}
+
+	if len(result.Sessions) != 3 {
+		t.Errorf("got %d sessions, want 3", len(result.Sessions))
+	}
+}
+
+func TestReplayTraceSimulatesSubmissionInterval(t *testing.T) {
+	entries := []TraceEntry{
+		traceWindow(0, "firefox", "GitHub"),
+		{Time: testStart.Add(3 * time.Minute), Kind: TraceSubmit}, // ignored
+		traceWindow(25*time.Minute, "kitty", "~"),
+		{Time: testStart.Add(40 * time.Minute), Kind: TraceShutdown},
+	}
+
+	result := replayTrace(entries, 10*time.Minute, io.Discard)
+
+	var times []string
+	for _, submission := range result.Submissions {
+		times = append(times, submission.Time.Format("15:04"))
+	}
+	want := []string{"09:10", "09:20", "09:30", "09:40"}
+	if !reflect.DeepEqual(times, want) {
+		t.Errorf("submission times = %v, want %v", times, want)
+	}
+}
+
+func TestReplayTraceHandlesMultipleRunsAndErrors(t *testing.T) {
+	entries := []TraceEntry{
+		traceWindow(0, "firefox", "GitHub"),
+		{Time: testStart.Add(time.Minute), Kind: TraceError, Error: "hyprctl failed"},
+		traceWindow(2*time.Minute, "firefox", "GitHub"),
+		{Time: testStart.Add(5 * time.Minute), Kind: TraceShutdown},
+		traceWindow(time.Hour, "kitty", "~"),
+		// killed without a shutdown entry
+		traceWindow(time.Hour+3*time.Minute, "kitty", "vim"),
+	}
+
+	result := replayTrace(entries, 0, io.Discard)
+
+	if len(result.Submissions) != 2 {
+		t.Fatalf("got %d submissions, want one per run", len(result.Submissions))
+	}
+	if got := result.Submissions[0].Payloads; len(got) != 1 || got[0].Duration != 5 {
+		t.Errorf("first run = %+v, want firefox for 5 minutes", got)
+	}
+	if got := result.Submissions[1].Payloads; len(got) != 1 || got[0].ActivityName != "kitty" || got[0].Duration != 3 {
+		t.Errorf("second run = %+v, want kitty for 3 minutes", got)
+	}
+}
+
+func TestRecordedTraceReplaysLikeLiveRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	recorder, err := NewTraceRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := startMonitorWith(t, 5*time.Minute, func(m *Monitor) { m.Recorder = recorder })
+	h.stay(3 * time.Minute)
+	h.focus("kitty", "~", 4*time.Minute)
+	h.focus("firefox", "Docs", 2*time.Minute)
+	h.shutdown()
+	recorder.Close()
+
+	var live []ReplaySubmission
+	for len(h.submits) > 0 {
+		live = append(live, buildReplaySubmission(time.Time{}, <-h.submits))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := readTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Identical polls are collapsed: three windows plus one boundary and the shutdown
+	if len(entries) != 5 {
+		t.Errorf("trace has %d entries, want 5", len(entries))
+	}
+
+	var out strings.Builder
+	replayed := replayTrace(entries, 0, &out)
+	if len(replayed.Submissions) != len(live) {
+		t.Fatalf("replay produced %d submissions, live run %d", len(replayed.Submissions), len(live))
+	}
+	for i := range live {
+		if !reflect.DeepEqual(replayed.Submissions[i].Payloads, live[i].Payloads) {
+			t.Errorf("submission %d: replay %+v, live %+v", i, replayed.Submissions[i].Payloads, live[i].Payloads)
+		}
+	}
+	if !strings.Contains(out.String(), "Active Window: Docs (firefox) [09:07:01]") {
+		t.Errorf("replay output missing window change:\n%s", out.String())
+	}
+}
+
+func TestTraceReplaysResolvedTerminals(t *testing.T) {
+	proc := newFakeProc(t)
+	proc.add(100, "kitty", 1, 100, 0, -1, 1000, "/", 200)
+	proc.add(200, "nvim", 100, 200, 34816, 200, 2000, "/src/widget")
+
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	recorder, err := NewTraceRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewManualClock(testStart)
+	monitor := &Monitor{Clock: clock, Out: io.Discard, Recorder: recorder, Terminals: &TerminalResolver{Root: proc.root}}
+	terminal := &HyprlandWindow{Class: "kitty", Title: "main.go", Pid: 100}
+	terminal.Workspace.ID, terminal.Workspace.Name = 5, "5: dev"
+	monitor.HandleWindow(terminal)
+	clock.Advance(5 * time.Minute)
+	monitor.HandleWindow(terminal)
+	clock.Advance(5 * time.Minute)
+	monitor.HandleWindow(&HyprlandWindow{Class: "firefox", Title: "Docs"})
+	clock.Advance(5 * time.Minute)
+	monitor.Shutdown()
+	recorder.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := readTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Resolved == nil || entries[0].Resolved.AppClass != "kitty › nvim" || entries[0].Resolved.Context.Directory != "/src/widget" {
+		t.Fatalf("trace = %+v", entries)
+	}
+
+	// Replayed without a process table, the terminal is still tracked by its foreground process
+	result := replayTrace(entries, 0, io.Discard)
+	if len(result.Sessions) != 2 || result.Sessions[0].AppClass != "kitty › nvim" || result.Sessions[0].Workspace != "5: dev" {
+		t.Errorf("sessions = %+v", result.Sessions)
+	}
+	if got := result.Submissions; len(got) != 1 || len(got[0].Payloads) != 2 || got[0].Payloads[0].ActivityName != "kitty › nvim" || got[0].Payloads[0].Duration != 10 {
+		t.Errorf("submissions = %+v", got)
+	}
+}
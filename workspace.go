@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// hyprlandFullscreen is the bit of a window's fullscreen mode set when it is
+// fullscreen rather than only maximized
+const hyprlandFullscreen = 2
+
+// Prefixes of rule patterns matching where a window is shown rather than
+// what it shows, e.g. workspace:music or monitor:1
+const (
+	workspacePatternPrefix = "workspace:"
+	monitorPatternPrefix   = "monitor:"
+)
+
+// withPlacement adds the workspace, monitor and display state of window to
+// sessionContext
+func withPlacement(sessionContext SessionContext, window *HyprlandWindow) SessionContext {
+	sessionContext.Workspace = window.Workspace.Name
+	sessionContext.WorkspaceID = window.Workspace.ID
+	sessionContext.Monitor = window.Monitor
+	sessionContext.Fullscreen = window.Fullscreen&hyprlandFullscreen != 0
+	sessionContext.Xwayland = window.Xwayland
+	return sessionContext
+}
+
+// isPlacementPattern reports whether pattern matches a workspace or monitor
+func isPlacementPattern(pattern string) bool {
+	return strings.HasPrefix(pattern, workspacePatternPrefix) || strings.HasPrefix(pattern, monitorPatternPrefix)
+}
+
+// matchPlacement reports whether a workspace: or monitor: pattern matches
+// where a session was shown. Workspaces match by ID, by name or by a word of
+// the name, so workspace:dev matches "5: dev" and workspace:music the
+// special workspace "special:music".
+func matchPlacement(pattern string, sessionContext SessionContext) bool {
+	if sessionContext.Workspace == "" {
+		return false // not a Hyprland window, or tracked before workspaces were
+	}
+	if monitor, ok := strings.CutPrefix(pattern, monitorPatternPrefix); ok {
+		return monitor == strconv.Itoa(sessionContext.Monitor)
+	}
+	workspace, ok := strings.CutPrefix(pattern, workspacePatternPrefix)
+	if !ok || workspace == "" {
+		return false
+	}
+	name := strings.ToLower(sessionContext.Workspace)
+	if workspace == name || workspace == strconv.Itoa(sessionContext.WorkspaceID) {
+		return true
+	}
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for _, word := range words {
+		if word == workspace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchPlacement(t *testing.T) {
+	dev := SessionContext{Workspace: "5: dev", WorkspaceID: 5, Monitor: 1}
+	music := SessionContext{Workspace: "special:music", WorkspaceID: -98}
+	tests := []struct {
+		pattern string
+		context SessionContext
+		want    bool
+	}{
+		{"workspace:5: dev", dev, true},
+		{"workspace:dev", dev, true},
+		{"workspace:5", dev, true},
+		{"workspace:de", dev, false},
+		{"workspace:music", music, true},
+		{"workspace:-98", music, true},
+		{"monitor:1", dev, true},
+		{"monitor:0", dev, false},
+		{"monitor:0", music, true},
+		{"monitor:0", SessionContext{}, false},
+		{"workspace:", dev, false},
+	}
+	for _, tt := range tests {
+		if got := matchPlacement(tt.pattern, tt.context); got != tt.want {
+			t.Errorf("matchPlacement(%q, %q) = %v, want %v", tt.pattern, tt.context.Workspace, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyByWorkspace(t *testing.T) {
+	rules, err := parseProductivityRules(strings.NewReader("workspace:music background\nworkspace:comms distracting Communication\nmonitor:2 productive\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	classes := &ProductivityMap{local: rules}
+
+	comms := SessionContext{Workspace: "3: comms", WorkspaceID: 3, Monitor: 2}
+	if rule, ok := classes.ClassifyContext("firefox", "Inbox", comms); !ok || rule.Level != levelDistracting || rule.Category != "Communication" {
+		t.Errorf("comms = %+v, %v", rule, ok)
+	}
+	if rule, ok := classes.ClassifyContext("kitty", "make", SessionContext{Workspace: "1", WorkspaceID: 1, Monitor: 2}); !ok || rule.Level != levelProductive {
+		t.Errorf("monitor 2 = %+v, %v", rule, ok)
+	}
+	// Without placement, application rules apply as before
+	if rule, ok := classes.Classify("spotify", "Spotify"); !ok || rule.Source != productivitySourceDefault || rule.Background {
+		t.Errorf("spotify = %+v, %v", rule, ok)
+	}
+	if !classes.Background("spotify", "Spotify", SessionContext{Workspace: "special:music", WorkspaceID: -98}) {
+		t.Error("music workspace is not in the background")
+	}
+	if classes.Background("firefox", "Inbox", comms) {
+		t.Error("comms workspace is in the background")
+	}
+	var none *ProductivityMap
+	if none.Background("spotify", "Spotify", SessionContext{Workspace: "music"}) {
+		t.Error("nil map has background rules")
+	}
+}
+
+func TestMonitorSkipsBackgroundWorkspaces(t *testing.T) {
+	rules, err := parseProductivityRules(strings.NewReader("workspace:music background\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewManualClock(testStart)
+	var out bytes.Buffer
+	monitor := &Monitor{Clock: clock, Out: &out, Productivity: &ProductivityMap{local: rules}}
+
+	code := &HyprlandWindow{Class: "code", Title: "main.go", Monitor: 1, Xwayland: true}
+	code.Workspace.ID, code.Workspace.Name = 5, "5: dev"
+	spotify := &HyprlandWindow{Class: "spotify", Title: "Spotify", Fullscreen: 2}
+	spotify.Workspace.ID, spotify.Workspace.Name = -98, "special:music"
+
+	monitor.HandleWindow(code)
+	if session, ok := monitor.Tracker.Current(); !ok || session.Workspace != "5: dev" || session.WorkspaceID != 5 || session.Monitor != 1 || !session.Xwayland || session.Fullscreen {
+		t.Errorf("current session = %+v", session)
+	}
+	clock.Advance(10 * time.Minute)
+	monitor.HandleWindow(spotify)
+	clock.Advance(20 * time.Minute)
+	monitor.HandleWindow(spotify)
+	if _, ok := monitor.Tracker.Current(); ok {
+		t.Error("tracking a background window")
+	}
+	clock.Advance(5 * time.Minute)
+	monitor.HandleWindow(code)
+	clock.Advance(5 * time.Minute)
+
+	summaries := monitor.Tracker.GetActivitySummaries()
+	if len(summaries) != 1 || summaries["code on 5: dev"].TotalDuration != 15*time.Minute {
+		t.Errorf("summaries = %+v", summaries)
+	}
+	if strings.Count(out.String(), "not tracked") != 1 {
+		t.Errorf("output:\n%s", out.String())
+	}
+
+	var all []ActivitySummary
+	for _, summary := range summaries {
+		all = append(all, summary)
+	}
+	if groups := groupSummaries(all, summaryGroups["workspace"]); len(groups) != 1 || groups[0].Name != "5: dev" {
+		t.Errorf("workspaces = %+v", groups)
+	}
+	if groups := groupSummaries(all, summaryGroups["monitor"]); len(groups) != 1 || groups[0].Name != "1" {
+		t.Errorf("monitors = %+v", groups)
+	}
+}
+
+func TestAlertCountsWorkspace(t *testing.T) {
+	rules, err := parseAlertRules(strings.NewReader("over 1h workspace:comms\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries := []ActivitySummary{
+		{AppClass: "firefox", TotalDuration: 40 * time.Minute, SessionContext: SessionContext{Workspace: "3: comms", WorkspaceID: 3}},
+		{AppClass: "discord", TotalDuration: 30 * time.Minute, SessionContext: SessionContext{Workspace: "3: comms", WorkspaceID: 3}},
+		{AppClass: "firefox", TotalDuration: 2 * time.Hour, SessionContext: SessionContext{Workspace: "5: dev", WorkspaceID: 5}},
+	}
+	if counted, triggered := rules[0].Evaluate(summaries, nil); counted != 70*time.Minute || !triggered {
+		t.Errorf("Evaluate = %v, %v", counted, triggered)
+	}
+}